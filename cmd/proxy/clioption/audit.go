@@ -0,0 +1,47 @@
+package clioption
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/tingtt/oauth2rbac/internal/audit"
+)
+
+// auditLogger creates audit logger from `--audit-log` options.
+// (format: `file:<FilePath>` or `webhook:<URL>`, audit logs are disabled if empty)
+func auditLogger(options []string, maxSizeMB uint, maxBackups uint) (*audit.Logger, error) {
+	if len(options) == 0 {
+		return nil, nil
+	}
+	sinks := make([]audit.Sink, 0, len(options))
+	closeSinks := func() {
+		for _, sink := range sinks {
+			sink.Close(context.Background())
+		}
+	}
+	for _, option := range options {
+		sink, err := auditSink(option, maxSizeMB, maxBackups)
+		if err != nil {
+			closeSinks()
+			return nil, err
+		}
+		sinks = append(sinks, sink)
+	}
+	return audit.New(sinks...), nil
+}
+
+func auditSink(option string, maxSizeMB uint, maxBackups uint) (audit.Sink, error) {
+	switch {
+	case strings.HasPrefix(option, "file:"):
+		return audit.NewFileSink(strings.TrimPrefix(option, "file:"), int64(maxSizeMB)*1024*1024, int(maxBackups))
+	case strings.HasPrefix(option, "webhook:"):
+		webhookURL, err := url.Parse(strings.TrimPrefix(option, "webhook:"))
+		if err != nil || (webhookURL.Scheme != "http" && webhookURL.Scheme != "https") || webhookURL.Host == "" {
+			return nil, fmt.Errorf("invalid audit log webhook URL `%s`", strings.TrimPrefix(option, "webhook:"))
+		}
+		return audit.NewWebhookSink(webhookURL.String(), nil), nil
+	}
+	return nil, fmt.Errorf("invalid audit log `%s` (format: `file:<FilePath>` or `webhook:<URL>`)", option)
+}
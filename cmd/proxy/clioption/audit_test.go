@@ -0,0 +1,30 @@
+package clioption
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_auditLogger(t *testing.T) {
+	t.Run("may be disabled without options", func(t *testing.T) {
+		got, err := auditLogger(nil, 100, 0)
+		assert.NoError(t, err)
+		assert.Nil(t, got)
+	})
+
+	t.Run("may create file and webhook sinks", func(t *testing.T) {
+		got, err := auditLogger([]string{"file:" + t.TempDir() + "/audit.log", "webhook:https://siem.example.com/events"}, 100, 0)
+		assert.NoError(t, err)
+		assert.NotNil(t, got)
+		assert.NoError(t, got.Close(context.Background()))
+	})
+
+	t.Run("may reject invalid options", func(t *testing.T) {
+		for _, option := range []string{"syslog", "webhook:siem.example.com", "file:" + t.TempDir() + "/not-exist/audit.log"} {
+			_, err := auditLogger([]string{option}, 100, 0)
+			assert.Error(t, err, option)
+		}
+	})
+}
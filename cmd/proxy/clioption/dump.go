@@ -0,0 +1,96 @@
+package clioption
+
+import (
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/tingtt/oauth2rbac/internal/acl"
+	jwtmiddleware "github.com/tingtt/oauth2rbac/internal/api/middleware/jwt"
+	"github.com/tingtt/oauth2rbac/internal/oauth2"
+	"github.com/tingtt/oauth2rbac/internal/serviceaccount"
+)
+
+// Redacted replaces the secrets in the normalized manifest.
+const Redacted = "REDACTED"
+
+// Normalized returns the manifest as enforced by the proxy, to review the config.
+// The ACL is sanitized and the defaults are filled.
+// Secrets and values of `set_headers` (e.g. `Authorization` of upstreams) are redacted,
+// but values of a single `${ENV}` or `file:` reference are kept as they are.
+func (m RevProxyACLManifest) Normalized() RevProxyACLManifest {
+	normalized := RevProxyACLManifest{
+		Proxies:         make([]proxy, 0, len(m.Proxies)),
+		ACL:             m.ACL.Sanitized(),
+		ServiceAccounts: serviceaccount.Pool{},
+		OAuth2:          map[string]oauth2Client{},
+	}
+
+	for _, proxy := range m.Proxies {
+		proxy.SetHeaders = redactHeaders(proxy.SetHeaders)
+		normalized.Proxies = append(normalized.Proxies, proxy)
+	}
+
+	for origin, scope := range normalized.ACL {
+		if scope.JWTExpiryIn == nil {
+			scope.JWTExpiryIn = ptr(acl.JWTExpiryIn(jwtmiddleware.DefaultExpiry))
+		}
+		if scope.SkipRedirectAfterLoginPaths == nil {
+			scope.SkipRedirectAfterLoginPaths = slices.Clone(acl.DefaultSkipRedirectAfterLoginPaths)
+		}
+		normalized.ACL[origin] = scope
+	}
+
+	for clientID, serviceAccount := range m.ServiceAccounts {
+		serviceAccount.ClientSecretHash = serviceaccount.SecretHash(redact(string(serviceAccount.ClientSecretHash)))
+		normalized.ServiceAccounts[clientID] = serviceAccount
+	}
+
+	for name, client := range m.OAuth2 {
+		client.ClientSecret = redact(client.ClientSecret)
+		if provider, supported := oauth2.Providers[client.Type]; supported {
+			if len(client.Scopes) == 0 {
+				client.Scopes = slices.Clone(provider.Scopes)
+			}
+			if client.DisplayName == "" {
+				client.DisplayName = provider.DisplayName
+			}
+		}
+		if client.Icon == "" {
+			client.Icon = client.Type
+		}
+		normalized.OAuth2[name] = client
+	}
+
+	return normalized
+}
+
+// singleEnvReferenceRegex matches the value of a single `${ENV}` reference.
+var singleEnvReferenceRegex = regexp.MustCompile(`^\$\{[A-Za-z_][A-Za-z0-9_]*\}$`)
+
+// redact returns Redacted instead of the secret, except for the value of a single reference.
+// References mixed with literals are redacted too, as the literals may be secrets. (e.g. `Bearer sk-live-xyz${SUFFIX}`)
+func redact(secret string) string {
+	if secret == "" || strings.HasPrefix(secret, "file:") || singleEnvReferenceRegex.MatchString(secret) {
+		return secret
+	}
+	return Redacted
+}
+
+func redactHeaders(headers map[string][]string) map[string][]string {
+	if headers == nil {
+		return nil
+	}
+	redacted := make(map[string][]string, len(headers))
+	for key, values := range headers {
+		redacted[key] = make([]string, 0, len(values))
+		for _, value := range values {
+			redacted[key] = append(redacted[key], redact(value))
+		}
+	}
+	return redacted
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
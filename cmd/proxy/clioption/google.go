@@ -0,0 +1,38 @@
+package clioption
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/tingtt/oauth2rbac/internal/oauth2"
+	"github.com/tingtt/oauth2rbac/internal/oauth2/google"
+)
+
+type googleOption struct {
+	hostedDomains           []string
+	groupsServiceAccountKey string
+	groupsAdminEmail        string
+}
+
+// googleProvider applies the Google Workspace settings to the provider.
+func googleProvider(provider oauth2.Provider, o googleOption) (oauth2.Provider, error) {
+	option := google.Option{HostedDomains: o.hostedDomains}
+	if o.groupsServiceAccountKey != "" {
+		if o.groupsAdminEmail == "" {
+			return oauth2.Provider{}, errors.New("CLI option `--google-groups-admin` is required to resolve Google Groups")
+		}
+		key, err := os.ReadFile(o.groupsServiceAccountKey)
+		if err != nil {
+			return oauth2.Provider{}, fmt.Errorf("failed to read service account key: %w", err)
+		}
+		option.GroupResolver, err = google.NewDirectoryGroupResolver(context.Background(), key, o.groupsAdminEmail)
+		if err != nil {
+			return oauth2.Provider{}, err
+		}
+	}
+	provider.GetUserInfoFunc = google.NewGetUserInfoFunc(option)
+	provider.AuthCodeOptions = append(provider.AuthCodeOptions, option.AuthCodeOptions()...)
+	return provider, nil
+}
@@ -0,0 +1,37 @@
+package clioption
+
+import (
+	"github.com/tingtt/oauth2rbac/internal/acl"
+	reverseproxy "github.com/tingtt/oauth2rbac/internal/api/handler/reverse_proxy"
+	"github.com/tingtt/oauth2rbac/internal/ingress"
+)
+
+// ingressController returns the controller merging Ingresses with the manifest.
+// The set_headers references of the manifest are re-read on changes of Ingresses. (e.g. rotated secret files)
+func ingressController(ingressClass, namespace string, manifest *RevProxyACLManifest, revProxyConfig reverseproxy.Config) (*ingress.Controller, error) {
+	if ingressClass == "" {
+		return nil, nil
+	}
+	client, err := ingress.NewInClusterClient(namespace)
+	if err != nil {
+		return nil, err
+	}
+	return ingress.NewController(client, ingress.Option{
+		IngressClass: ingressClass,
+		Static:       ingress.Config{ReverseProxy: revProxyConfig, ACL: manifest.ACL},
+		ReloadStatic: func() (ingress.Config, error) {
+			revProxyConfig, err := resolveProxies(manifest.Proxies)
+			return ingress.Config{ReverseProxy: revProxyConfig, ACL: manifest.ACL}, err
+		},
+		Validate: validateConfig,
+	}), nil
+}
+
+// validateConfig checks the semantics of the config as the manifest, without the line numbers. (e.g. built from Ingresses)
+func validateConfig(revProxyConfig reverseproxy.Config, pool acl.Pool) []string {
+	manifest := &RevProxyACLManifest{ACL: pool}
+	for _, p := range revProxyConfig.Proxies {
+		manifest.Proxies = append(manifest.Proxies, proxy{ExternalURL: p.ExternalURL, Target: p.Target.URL, SetHeaders: p.SetHeaders})
+	}
+	return validateManifest([]manifestFile{{manifest: manifest}})
+}
@@ -4,7 +4,7 @@ import "errors"
 
 func checkJWTSignKey(jwtSignKey string) error {
 	if jwtSignKey == "" {
-		return errors.New("CLI option `--jwt-secret` cannot be empty")
+		return errors.New("CLI option `--jwt-secret` or `--jwt-secret-file` is required")
 	}
 	return nil
 }
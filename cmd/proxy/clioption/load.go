@@ -2,11 +2,21 @@ package clioption
 
 import (
 	"crypto/tls"
+	"errors"
 	"log/slog"
+	"time"
 
 	"github.com/tingtt/oauth2rbac/internal/acl"
+	"github.com/tingtt/oauth2rbac/internal/api/handler/oauth2/ui"
 	reverseproxy "github.com/tingtt/oauth2rbac/internal/api/handler/reverse_proxy"
+	"github.com/tingtt/oauth2rbac/internal/apitoken"
+	"github.com/tingtt/oauth2rbac/internal/audit"
+	"github.com/tingtt/oauth2rbac/internal/ingress"
 	"github.com/tingtt/oauth2rbac/internal/oauth2"
+	"github.com/tingtt/oauth2rbac/internal/oauth2/github"
+	"github.com/tingtt/oauth2rbac/internal/oauth2/gitlab"
+	"github.com/tingtt/oauth2rbac/internal/serviceaccount"
+	"github.com/tingtt/oauth2rbac/internal/session"
 
 	"github.com/spf13/pflag"
 )
@@ -19,32 +29,123 @@ type CLIOption struct {
 	ACL             acl.Pool
 	X509KeyPairs    []tls.Certificate
 	UseSecureCookie bool
+	ErrorPages      *ui.ErrorPages
+	APITokenStore   apitoken.Store
+	ServiceAccounts serviceaccount.Pool
+	SessionStore    session.Store
+	Admins          []acl.EmailRegex
+	// IdentityRecheckInterval is the interval to re-validate identities of sessions with OAuth2 providers. (disabled if zero)
+	IdentityRecheckInterval time.Duration
+	// IngressController builds RevProxyConfig and ACL from Kubernetes Ingresses. (disabled if nil)
+	IngressController *ingress.Controller
+	// ShutdownDelay is the time to keep serving after the health check fails on shutdown, for load balancers to stop sending requests.
+	ShutdownDelay time.Duration
+	// ShutdownTimeout is the time to drain connections on shutdown.
+	ShutdownTimeout time.Duration
+	// CheckUpstreams enables to check the connections to the targets of the proxies in `/readyz`.
+	CheckUpstreams bool
+	// AdminPort is the port of the admin listener serving `/metrics` and `/readyz` with the results of the checks. (disabled if zero)
+	AdminPort uint16
+	// Tracing enables to export traces via OTLP. (configured with `OTEL_EXPORTER_OTLP_*` environment variables)
+	Tracing bool
+	// TracingUserEmail records the emails of signed-in users in spans.
+	TracingUserEmail bool
+	// AuditLogger records authentication and authorization decisions, config loads and admin actions. (disabled if nil)
+	AuditLogger *audit.Logger
 }
 
 func Load() (CLIOption, error) {
 	// Options for key features
 	port := pflag.Uint16("port", 8080, "Port to listen")
-	jwtSignKey := pflag.String("jwt-secret", "", "JWT sign secret")
-	oauth2Clients := pflag.StringArray("oauth2-client", nil, "OAuth2 (format: `<ProviderName>;<ClientID>;<ClientSecret>`)")
+	adminPort := pflag.Uint16("admin-port", 0, "Port of the admin listener serving /metrics and /readyz with the results of the checks (disabled if zero)")
+	jwtSignKeyFlag := pflag.String("jwt-secret", "", "JWT sign secret (or environment variable `OAUTH2RBAC_JWT_SECRET`)")
+	jwtSignKeyFile := pflag.String("jwt-secret-file", "", "JWT sign secret file path (or environment variable `OAUTH2RBAC_JWT_SECRET_FILE`)")
+	oauth2CLIClients := pflag.StringArray("oauth2-client", nil, "OAuth2 (format: `<ProviderName>;<ClientID>;<ClientSecret>`)")
+	githubReadOrg := pflag.Bool("github-read-org", false, "Read GitHub organization and team memberships (requests `read:org` scope) to match `github:<org>` and `github:<org>/<team>` in ACL")
+	githubSecondaryEmails := pflag.Bool("github-secondary-emails", false, "Match verified secondary GitHub emails against ACL, if they are granted more access than the primary email")
+	googleHostedDomains := pflag.StringArray("google-hosted-domain", nil, "Google Workspace domain allowed to sign in with Google (all accounts are allowed if not set)")
+	googleGroupsServiceAccountKey := pflag.String("google-groups-service-account-key", "", "Service account key file to read Google Groups of users via Admin SDK (Google Groups are not resolved if empty)")
+	googleGroupsAdmin := pflag.String("google-groups-admin", "", "Workspace admin email impersonated by the service account to read Google Groups")
+	gitlabURL := pflag.String("gitlab-url", gitlab.DefaultBaseURL, "Base URL of GitLab (for self-hosted GitLab)")
+	entraTenantID := pflag.String("entra-tenant-id", "", "Microsoft Entra ID tenant ID allowed to sign in with Microsoft (required to use `entra` provider)")
+	entraReadGroups := pflag.Bool("entra-read-groups", false, "Read Microsoft Entra ID group memberships (requests `GroupMember.Read.All` scope) to match `entra:<group object id>` in ACL")
+	oauth2ApprovalForce := pflag.Bool("oauth2-approval-force", true, "Show the consent screen of OAuth2 providers on every login (to always receive refresh tokens)")
 	manifestFilePath := pflag.StringP("config.file", "f", "/etc/oauth2rbac/config.file", "Manifest file path")
+	manifestDirPath := pflag.String("config.dir", "", "Directory of manifest files (*.yaml, *.yml) merged with --config.file (if given explicitly)")
 	x509KeyPairs := pflag.StringArray("tls-cert", nil, "x509 key pair (format: `<CertFilePath>;<KeyFilePath>`)")
 	useSecureCookie := pflag.Bool("secure-cookie", false, "Use cookies with Secure attribute. If TLS certificate is set, it is always true.")
+	apiTokenStoreFilePath := pflag.String("api-token-store", "", "API token store file path (API tokens are disabled if empty)")
+	sessionStoreOption := pflag.String("session-store", "", "Session store (format: `memory` or `file:<FilePath>`, sessions are disabled if empty)")
+	admins := pflag.StringArray("admin", nil, "Email (pattern) of admins allowed to use admin APIs")
+	ingressClass := pflag.String("ingress-class", "", "Serve Kubernetes Ingresses of the ingress class, with the manifest (disabled if empty, requires running in the cluster)")
+	ingressNamespace := pflag.String("ingress-namespace", "", "Namespace of Kubernetes Ingresses to serve (all namespaces if empty)")
+	shutdownDelay := pflag.Duration("shutdown-delay", 5*time.Second, "Time to keep serving after the health check fails on SIGTERM/SIGINT, for load balancers to stop sending requests")
+	shutdownTimeout := pflag.Duration("shutdown-timeout", 20*time.Second, "Time to drain connections (including WebSocket) on SIGTERM/SIGINT, after the shutdown delay")
+	tracing := pflag.Bool("tracing", false, "Export traces via OTLP/HTTP (configured with OTEL_EXPORTER_OTLP_* environment variables)")
+	tracingUserEmail := pflag.Bool("tracing-user-email", false, "Record emails of signed-in users in spans as enduser.id")
+	checkUpstreams := pflag.Bool("readyz-upstreams", false, "Fail /readyz if all targets of the proxies are unreachable")
+	auditLogs := pflag.StringArray("audit-log", nil, "Audit log of logins, denials, config reloads and admin actions (format: `file:<FilePath>` or `webhook:<URL>`, disabled if not set)")
+	auditLogMaxSize := pflag.Uint("audit-log-max-size", 100, "Size in megabytes to rotate audit log files (not rotated if zero)")
+	auditLogMaxBackups := pflag.Uint("audit-log-max-backups", 0, "Number of rotated audit log files to keep (all kept if zero)")
+	identityRecheckInterval := pflag.Duration("identity-recheck-interval", 0, "Interval to re-validate identities of sessions with OAuth2 providers (disabled if zero, requires `--session-store`)")
 
 	// Options for developer
 	debugLogEnable := pflag.Bool("debug", false, "Enable debug logs")
 
 	pflag.Parse()
 
-	if err := checkJWTSignKey(*jwtSignKey); err != nil {
+	jwtSignKey, err := secretFromOptions("jwt-secret", *jwtSignKeyFlag, *jwtSignKeyFile)
+	if err != nil {
+		return CLIOption{}, err
+	}
+	if err := checkJWTSignKey(jwtSignKey); err != nil {
 		return CLIOption{}, err
 	}
 
-	oauth2Config, err := oauth2Config(oauth2Clients)
+	manifest, revProxyConfig, err := loadAndValidateManifest(ManifestFilePath(pflag.CommandLine, *manifestFilePath, *manifestDirPath), *manifestDirPath)
 	if err != nil {
 		return CLIOption{}, err
 	}
 
-	revProxyConfig, acl, err := loadAndValidateManifest(*manifestFilePath)
+	oauth2Config, err := oauth2Config(oauth2CLIClients, manifest.OAuth2, oauth2Option{
+		approvalForce: *oauth2ApprovalForce,
+		githubReadOrg: *githubReadOrg,
+		github:        github.Option{UseSecondaryEmails: *githubSecondaryEmails},
+		google: googleOption{
+			hostedDomains:           *googleHostedDomains,
+			groupsServiceAccountKey: *googleGroupsServiceAccountKey,
+			groupsAdminEmail:        *googleGroupsAdmin,
+		},
+		gitlab:          gitlab.Option{BaseURL: *gitlabURL},
+		entraTenantID:   *entraTenantID,
+		entraReadGroups: *entraReadGroups,
+	})
+	if err != nil {
+		return CLIOption{}, err
+	}
+
+	errorPages, err := ui.LoadErrorPages(manifest.ACL)
+	if err != nil {
+		return CLIOption{}, err
+	}
+
+	var apiTokenStore apitoken.Store
+	if *apiTokenStoreFilePath != "" {
+		apiTokenStore, err = apitoken.NewFileStore(*apiTokenStoreFilePath)
+		if err != nil {
+			return CLIOption{}, err
+		}
+	}
+
+	sessionStore, err := sessionStore(*sessionStoreOption)
+	if err != nil {
+		return CLIOption{}, err
+	}
+	if *identityRecheckInterval != 0 && sessionStore == nil {
+		return CLIOption{}, errors.New("CLI option `--identity-recheck-interval` requires `--session-store`")
+	}
+
+	ingressController, err := ingressController(*ingressClass, *ingressNamespace, manifest, revProxyConfig)
 	if err != nil {
 		return CLIOption{}, err
 	}
@@ -54,6 +155,19 @@ func Load() (CLIOption, error) {
 		return CLIOption{}, err
 	}
 
+	if *tracingUserEmail && !*tracing {
+		return CLIOption{}, errors.New("CLI option `--tracing-user-email` requires `--tracing`")
+	}
+
+	if *adminPort != 0 && *adminPort == *port {
+		return CLIOption{}, errors.New("CLI option `--admin-port` must be different from `--port`")
+	}
+
+	auditLogger, err := auditLogger(*auditLogs, *auditLogMaxSize, *auditLogMaxBackups)
+	if err != nil {
+		return CLIOption{}, err
+	}
+
 	if len(certs) != 0 && !*useSecureCookie {
 		*useSecureCookie = true
 	}
@@ -65,10 +179,42 @@ func Load() (CLIOption, error) {
 	return CLIOption{
 		Port:            *port,
 		OAuth2:          oauth2Config,
-		JWTSignKey:      *jwtSignKey,
+		JWTSignKey:      jwtSignKey,
 		RevProxyConfig:  revProxyConfig,
-		ACL:             acl,
+		ACL:             manifest.ACL,
 		X509KeyPairs:    certs,
 		UseSecureCookie: *useSecureCookie,
+		ErrorPages:      errorPages,
+		APITokenStore:   apiTokenStore,
+		ServiceAccounts: manifest.ServiceAccounts,
+		SessionStore:    sessionStore,
+		Admins:          adminEmails(*admins),
+
+		IdentityRecheckInterval: *identityRecheckInterval,
+		IngressController:       ingressController,
+		ShutdownDelay:           *shutdownDelay,
+		ShutdownTimeout:         *shutdownTimeout,
+		CheckUpstreams:          *checkUpstreams,
+		AdminPort:               *adminPort,
+		Tracing:                 *tracing,
+		TracingUserEmail:        *tracingUserEmail,
+		AuditLogger:             auditLogger,
 	}, nil
 }
+
+func adminEmails(admins []string) []acl.EmailRegex {
+	emails := make([]acl.EmailRegex, 0, len(admins))
+	for _, admin := range admins {
+		emails = append(emails, acl.EmailRegex(admin))
+	}
+	return emails
+}
+
+// ManifestFilePath returns the manifest file path, or empty if only `--config.dir` is given.
+// The default of `--config.file` is not loaded with `--config.dir`.
+func ManifestFilePath(flags *pflag.FlagSet, filePath, dirPath string) string {
+	if dirPath != "" && !flags.Changed("config.file") {
+		return ""
+	}
+	return filePath
+}
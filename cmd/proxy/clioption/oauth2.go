@@ -1,36 +1,200 @@
 package clioption
 
 import (
+	"cmp"
 	"errors"
 	"fmt"
+	"regexp"
+	"slices"
 	"strings"
 
+	"github.com/tingtt/oauth2rbac/internal/api/handler/oauth2/ui"
 	"github.com/tingtt/oauth2rbac/internal/oauth2"
+	"github.com/tingtt/oauth2rbac/internal/oauth2/entra"
+	"github.com/tingtt/oauth2rbac/internal/oauth2/github"
+	"github.com/tingtt/oauth2rbac/internal/oauth2/gitlab"
 )
 
-func oauth2Config(clients *[]string) (map[string]oauth2.Service, error) {
-	oauth2Config := map[string]oauth2.Service{}
-	for _, c := range *clients {
-		client := strings.Split(c, ";")
+type oauth2Option struct {
+	approvalForce   bool
+	githubReadOrg   bool
+	github          github.Option
+	google          googleOption
+	gitlab          gitlab.Option
+	entraTenantID   string
+	entraReadGroups bool
+}
+
+// oauth2Client is the OAuth2 client of a provider instance, declared in `oauth2` section of the manifest.
+type oauth2Client struct {
+	Type         string `yaml:"type"`
+	ClientID     string `yaml:"client_id"`
+	ClientSecret string `yaml:"client_secret"`
+	// ClientSecretFile is the file path of the client secret, instead of ClientSecret.
+	ClientSecretFile string `yaml:"client_secret_file"`
+	// Scopes replaces the default scopes of the provider type.
+	Scopes []string `yaml:"scopes"`
+	// Endpoint overrides the endpoint of the provider type.
+	Endpoint    oauth2Endpoint `yaml:"endpoint"`
+	DisplayName string         `yaml:"display_name"`
+	// Icon is the icon name of the provider types, or the image URL.
+	Icon string `yaml:"icon"`
+	// BaseURL is the base URL of self-hosted GitLab. (type `gitlab`, default: `--gitlab-url`)
+	BaseURL string `yaml:"base_url"`
+	// TenantID is the Microsoft Entra ID tenant ID. (type `entra`, default: `--entra-tenant-id`)
+	TenantID string `yaml:"tenant_id"`
+}
+
+type oauth2Endpoint struct {
+	AuthURL  string `yaml:"auth_url"`
+	TokenURL string `yaml:"token_url"`
+}
+
+// oauth2ClientNameRegex is the format of provider instance names, used in URL paths. (e.g. `/.auth/<name>/login`)
+var oauth2ClientNameRegex = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+// oauth2Config returns the services of the clients of `--oauth2-client` and `oauth2` section of the manifest.
+func oauth2Config(cliClients *[]string, manifestClients map[string]oauth2Client, option oauth2Option) (map[string]oauth2.Service, error) {
+	clients := map[string]oauth2Client{}
+	for _, c := range *cliClients {
+		// The client secret may contain `;`.
+		client := strings.SplitN(c, ";", 3)
 		if len(client) != 3 {
 			return nil, errors.New("invalid format CLI option `--oauth2-client` given")
 		}
 		providerName, clientId, clientSecret := client[0], client[1], client[2]
-
-		provider, supported := oauth2.Providers[providerName]
-		if !supported {
+		if _, supported := oauth2.Providers[providerName]; !supported {
 			return nil, fmt.Errorf("oauth2 provider `%s` is not supported", providerName)
 		}
+		clients[providerName] = oauth2Client{Type: providerName, ClientID: clientId, ClientSecret: clientSecret}
+	}
+	for name, client := range manifestClients {
+		if _, duplicated := clients[name]; duplicated {
+			return nil, fmt.Errorf("oauth2 client `%s` is declared in both CLI option `--oauth2-client` and manifest", name)
+		}
+		clients[name] = client
+	}
 
-		oauth2Config[providerName] = oauth2.New(&oauth2.Config{
-			ClientID:     clientId,
-			ClientSecret: clientSecret,
-			Scopes:       provider.Scopes,
-			Endpoint:     provider.Endpoint,
-		}, provider.GetUserInfoFunc)
+	oauth2Config := map[string]oauth2.Service{}
+	for name, client := range clients {
+		service, err := newOAuth2Service(name, client, option)
+		if err != nil {
+			return nil, fmt.Errorf("oauth2 client `%s`: %w", name, err)
+		}
+		oauth2Config[name] = service
 	}
 	if len(oauth2Config) == 0 {
-		return nil, errors.New("CLI option `--oauth2-client` is required")
+		return nil, errors.New("CLI option `--oauth2-client` or `oauth2` section of manifest is required")
 	}
 	return oauth2Config, nil
 }
+
+func newOAuth2Service(name string, client oauth2Client, option oauth2Option) (oauth2.Service, error) {
+	if !oauth2ClientNameRegex.MatchString(name) {
+		return nil, fmt.Errorf("name must match `%s`", oauth2ClientNameRegex)
+	}
+	provider, supported := oauth2.Providers[client.Type]
+	if !supported {
+		return nil, fmt.Errorf("oauth2 provider type `%s` is not supported", client.Type)
+	}
+	clientID, err := resolveSecretReference(client.ClientID)
+	if err != nil {
+		return nil, fmt.Errorf("client_id: %w", err)
+	}
+	if clientID == "" {
+		return nil, errors.New("client_id is required")
+	}
+	clientSecret, err := client.clientSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	scopes := slices.Clone(provider.Scopes)
+	if len(client.Scopes) != 0 {
+		scopes = slices.Clone(client.Scopes)
+	}
+	switch client.Type {
+	case "github":
+		if option.githubReadOrg && !slices.Contains(scopes, github.ScopeReadOrg) {
+			scopes = append(scopes, github.ScopeReadOrg)
+		}
+		provider.GetUserInfoFunc = github.NewGetUserInfoFunc(option.github)
+	case "google":
+		provider, err = googleProvider(provider, option.google)
+		if err != nil {
+			return nil, err
+		}
+	case "gitlab":
+		gitlabOption := option.gitlab
+		gitlabOption.BaseURL = cmp.Or(client.BaseURL, gitlabOption.BaseURL)
+		if gitlabOption.BaseURL != "" {
+			provider.Endpoint = gitlab.NewEndpoint(gitlabOption.BaseURL)
+			provider.DiscoveryURL = gitlab.NewDiscoveryURL(gitlabOption.BaseURL)
+		}
+		provider.GetUserInfoFunc = gitlab.NewGetUserInfoFunc(gitlabOption)
+	case "entra":
+		tenantID := cmp.Or(client.TenantID, option.entraTenantID)
+		if tenantID == "" {
+			return nil, errors.New("CLI option `--entra-tenant-id` (or tenant_id) is required to sign in with Microsoft Entra ID")
+		}
+		if slices.Contains(entra.MultiTenants, strings.ToLower(tenantID)) {
+			return nil, fmt.Errorf("multi-tenant `%s` is not allowed as tenant ID", tenantID)
+		}
+		provider.Endpoint = entra.NewEndpoint(tenantID)
+		provider.DiscoveryURL = entra.NewDiscoveryURL(tenantID)
+		if option.entraReadGroups && !slices.Contains(scopes, entra.ScopeGroupMemberRead) {
+			scopes = append(scopes, entra.ScopeGroupMemberRead)
+		}
+	}
+
+	if client.Endpoint.AuthURL != "" {
+		provider.Endpoint.AuthURL = client.Endpoint.AuthURL
+	}
+	if client.Endpoint.TokenURL != "" {
+		provider.Endpoint.TokenURL = client.Endpoint.TokenURL
+	}
+	if client.DisplayName != "" {
+		provider.DisplayName = client.DisplayName
+	}
+	if client.Icon != "" {
+		if err := validateIcon(client.Icon); err != nil {
+			return nil, err
+		}
+		provider.Icon = client.Icon
+	}
+
+	return oauth2.New(&oauth2.Config{
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		Scopes:       scopes,
+		Endpoint:     provider.Endpoint,
+	}, client.Type, provider, option.approvalForce), nil
+}
+
+// clientSecret returns the client secret, resolving `${ENV}` and `file:` references.
+func (c oauth2Client) clientSecret() (string, error) {
+	if c.ClientSecretFile == "" {
+		secret, err := resolveSecretReference(c.ClientSecret)
+		if err != nil {
+			return "", fmt.Errorf("client_secret: %w", err)
+		}
+		return secret, nil
+	}
+	if c.ClientSecret != "" {
+		return "", errors.New("client_secret and client_secret_file cannot be used together")
+	}
+	return readSecretFile(c.ClientSecretFile)
+}
+
+// validateIcon checks the icon is one of the provider types, or the image URL.
+func validateIcon(icon string) error {
+	if _, ok := ui.ProviderIcons[icon]; ok {
+		return nil
+	}
+	for _, prefix := range []string{"https://", "http://", "data:image/", "/"} {
+		if strings.HasPrefix(icon, prefix) {
+			return nil
+		}
+	}
+	return fmt.Errorf("icon `%s` must be one of the provider types or an image URL", icon)
+}
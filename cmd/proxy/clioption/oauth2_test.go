@@ -0,0 +1,116 @@
+package clioption
+
+import (
+	"os"
+	"testing"
+
+	"github.com/tingtt/oauth2rbac/internal/oauth2/gitlab"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_oauth2Config(t *testing.T) {
+	t.Parallel()
+
+	option := oauth2Option{gitlab: gitlab.Option{BaseURL: gitlab.DefaultBaseURL}}
+
+	t.Run("may allow `;` in client secret of CLI option", func(t *testing.T) {
+		t.Parallel()
+		got, err := oauth2Config(&[]string{"github;id;sec;ret"}, nil, option)
+
+		assert.NoError(t, err)
+		assert.Equal(t, "sec;ret", got["github"].Config().ClientSecret)
+	})
+
+	t.Run("may declare clients of the same provider type in manifest", func(t *testing.T) {
+		t.Parallel()
+		secretFile := t.TempDir() + "/secret"
+		os.WriteFile(secretFile, []byte("secret;from;file\n"), 0600)
+
+		got, err := oauth2Config(&[]string{"gitlab;id;secret"}, map[string]oauth2Client{
+			"corp-gitlab": {
+				Type:             "gitlab",
+				ClientID:         "corp-id",
+				ClientSecretFile: secretFile,
+				Scopes:           []string{"openid", "email"},
+				BaseURL:          "https://gitlab.example.com",
+				DisplayName:      "Corp GitLab",
+				Icon:             "https://gitlab.example.com/icon.png",
+			},
+		}, option)
+
+		assert.NoError(t, err)
+		assert.Len(t, got, 2)
+		assert.Equal(t, "https://gitlab.com/oauth/authorize", got["gitlab"].Config().Endpoint.AuthURL)
+		assert.Equal(t, "GitLab", got["gitlab"].DisplayName())
+		assert.Equal(t, "gitlab", got["gitlab"].Icon())
+
+		corp := got["corp-gitlab"]
+		assert.Equal(t, "gitlab", corp.Type())
+		assert.Equal(t, "secret;from;file", corp.Config().ClientSecret)
+		assert.Equal(t, []string{"openid", "email"}, corp.Config().Scopes)
+		assert.Equal(t, "https://gitlab.example.com/oauth/authorize", corp.Config().Endpoint.AuthURL)
+		assert.Equal(t, "Corp GitLab", corp.DisplayName())
+		assert.Equal(t, "https://gitlab.example.com/icon.png", corp.Icon())
+	})
+
+	t.Run("may override endpoint", func(t *testing.T) {
+		t.Parallel()
+		got, err := oauth2Config(&[]string{}, map[string]oauth2Client{
+			"github-enterprise": {
+				Type:         "github",
+				ClientID:     "id",
+				ClientSecret: "secret",
+				Endpoint:     oauth2Endpoint{AuthURL: "https://github.example.com/login/oauth/authorize"},
+			},
+		}, option)
+
+		assert.NoError(t, err)
+		assert.Equal(t, "https://github.example.com/login/oauth/authorize", got["github-enterprise"].Config().Endpoint.AuthURL)
+		assert.Equal(t, "https://github.com/login/oauth/access_token", got["github-enterprise"].Config().Endpoint.TokenURL)
+	})
+
+	tests := []struct {
+		name            string
+		cliClients      []string
+		manifestClients map[string]oauth2Client
+	}{
+		{
+			name: "may reject no clients",
+		},
+		{
+			name:            "may reject duplicated names",
+			cliClients:      []string{"github;id;secret"},
+			manifestClients: map[string]oauth2Client{"github": {Type: "github", ClientID: "id", ClientSecret: "secret"}},
+		},
+		{
+			name:            "may reject unsupported type",
+			manifestClients: map[string]oauth2Client{"unknown": {Type: "unknown", ClientID: "id", ClientSecret: "secret"}},
+		},
+		{
+			name:            "may reject invalid name",
+			manifestClients: map[string]oauth2Client{"Corp GitHub": {Type: "github", ClientID: "id", ClientSecret: "secret"}},
+		},
+		{
+			name: "may reject both client_secret and client_secret_file",
+			manifestClients: map[string]oauth2Client{
+				"github": {Type: "github", ClientID: "id", ClientSecret: "secret", ClientSecretFile: "/run/secrets/github"},
+			},
+		},
+		{
+			name:            "may reject multi-tenant of entra",
+			manifestClients: map[string]oauth2Client{"entra": {Type: "entra", ClientID: "id", ClientSecret: "secret", TenantID: "common"}},
+		},
+		{
+			name:            "may reject unknown icon",
+			manifestClients: map[string]oauth2Client{"github": {Type: "github", ClientID: "id", ClientSecret: "secret", Icon: "unknown"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			_, err := oauth2Config(&tt.cliClients, tt.manifestClients, option)
+			assert.Error(t, err)
+		})
+	}
+}
@@ -1,20 +1,32 @@
 package clioption
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
-	"net/url"
+	"io"
+	"maps"
 	"os"
+	"path/filepath"
+	"strings"
 
 	"github.com/tingtt/oauth2rbac/internal/acl"
 	reverseproxy "github.com/tingtt/oauth2rbac/internal/api/handler/reverse_proxy"
+	"github.com/tingtt/oauth2rbac/internal/serviceaccount"
 	"github.com/tingtt/oauth2rbac/internal/util/slices"
 
+	"github.com/BurntSushi/toml"
 	"gopkg.in/yaml.v3"
 )
 
 type RevProxyACLManifest struct {
-	Proxies []proxy  `yaml:"proxies"`
-	ACL     acl.Pool `yaml:"acl"`
+	// Include is the manifest files merged into this manifest. (file paths or glob patterns, relative to this manifest)
+	Include         []string            `yaml:"include,omitempty"`
+	Proxies         []proxy             `yaml:"proxies"`
+	ACL             acl.Pool            `yaml:"acl"`
+	ServiceAccounts serviceaccount.Pool `yaml:"service_accounts"`
+	// OAuth2 is the OAuth2 clients by provider instance name.
+	OAuth2 map[string]oauth2Client `yaml:"oauth2"`
 }
 
 type proxy struct {
@@ -23,44 +35,229 @@ type proxy struct {
 	SetHeaders  map[string][]string `yaml:"set_headers"`
 }
 
-func loadAndValidateManifest(yamlFilePath string) (reverseproxy.Config, acl.Pool, error) {
-	manifest, err := loadRevProxyACLManifest(yamlFilePath)
+// manifestFile is a loaded manifest file, before merged.
+type manifestFile struct {
+	path     string
+	manifest *RevProxyACLManifest
+	root     *yaml.Node
+	// typeErrs is the errors of unknown fields and types reported by the decoder. (e.g. "line 3: field mothods not found")
+	typeErrs []string
+}
+
+// LoadManifest loads the manifest file and the manifest files (`*.yaml`, `*.yml`, `*.json`, `*.toml`) in the directory with their includes,
+// validates them, and merges them into one manifest, without resolving secret references.
+// Either of the paths may be empty. All errors of the manifests are reported at once with the line numbers.
+func LoadManifest(filePath, dirPath string) (*RevProxyACLManifest, error) {
+	var paths []string
+	if filePath != "" {
+		paths = append(paths, filePath)
+	}
+	if dirPath != "" {
+		dirPaths, err := manifestDirFilePaths(dirPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load manifest: %w", err)
+		}
+		paths = append(paths, dirPaths...)
+	}
+	if len(paths) == 0 {
+		return nil, errors.New("CLI option `--config.file` or `--config.dir` is required")
+	}
+
+	files, err := loadManifestFiles(paths)
 	if err != nil {
-		return reverseproxy.Config{}, nil, fmt.Errorf("failed to load manifest: %w", err)
+		return nil, fmt.Errorf("failed to load manifest: %w", err)
+	}
+	if errs := validateManifest(files); len(errs) != 0 {
+		if len(files) == 1 {
+			return nil, fmt.Errorf("invalid manifest `%s`:\n  %s", files[0].path, strings.Join(errs, "\n  "))
+		}
+		return nil, fmt.Errorf("invalid manifests:\n  %s", strings.Join(errs, "\n  "))
 	}
+	return mergeManifests(files), nil
+}
 
-	proxies, err := slices.MapE(manifest.Proxies, func(proxy proxy) (reverseproxy.Proxy, error) {
-		err := validateURLformats(proxy.ExternalURL, proxy.Target)
+// loadAndValidateManifest loads the manifest, and resolves the secret references of the service accounts and the proxies.
+// The references of the proxies are kept in the manifest, to be re-read on reloads. (see resolveProxies)
+func loadAndValidateManifest(filePath, dirPath string) (*RevProxyACLManifest, reverseproxy.Config, error) {
+	manifest, err := LoadManifest(filePath, dirPath)
+	if err != nil {
+		return nil, reverseproxy.Config{}, err
+	}
+
+	revProxyConfig, err := resolveProxies(manifest.Proxies)
+	if err != nil {
+		return nil, reverseproxy.Config{}, fmt.Errorf("failed to load manifest: %w", err)
+	}
+
+	for clientID, serviceAccount := range manifest.ServiceAccounts {
+		secretHash, err := resolveSecretReference(string(serviceAccount.ClientSecretHash))
 		if err != nil {
-			return reverseproxy.Proxy{}, err
+			return nil, reverseproxy.Config{}, fmt.Errorf("failed to load manifest: service account `%s`: %w", clientID, err)
+		}
+		serviceAccount.ClientSecretHash = serviceaccount.SecretHash(secretHash)
+		manifest.ServiceAccounts[clientID] = serviceAccount
+	}
+	if /* resolved hashes */ err := manifest.ServiceAccounts.Validate(); err != nil {
+		return nil, reverseproxy.Config{}, fmt.Errorf("failed to load manifest: %w", err)
+	}
+
+	return manifest, revProxyConfig, nil
+}
+
+// resolveProxies returns the proxies with `${ENV}` and `file:` references in set_headers resolved.
+func resolveProxies(proxies []proxy) (reverseproxy.Config, error) {
+	resolved, err := slices.MapE(proxies, func(proxy proxy) (reverseproxy.Proxy, error) {
+		setHeaders, err := resolveHeaderReferences(proxy.SetHeaders)
+		if err != nil {
+			return reverseproxy.Proxy{}, fmt.Errorf("set_headers of `%s`: %w", proxy.ExternalURL, err)
 		}
 
 		return reverseproxy.Proxy{
 			ExternalURL: proxy.ExternalURL,
 			Target:      reverseproxy.Target{URL: proxy.Target},
-			SetHeaders:  proxy.SetHeaders,
+			SetHeaders:  setHeaders,
 		}, nil
 	})
 	if err != nil {
-		return reverseproxy.Config{}, nil, fmt.Errorf("failed to load manifest: %w", err)
+		return reverseproxy.Config{}, err
+	}
+	return reverseproxy.Config{Proxies: resolved}, nil
+}
+
+// resolveHeaderReferences resolves `${ENV}` and `file:` references in the header values.
+func resolveHeaderReferences(headers map[string][]string) (map[string][]string, error) {
+	if headers == nil {
+		return nil, nil
+	}
+	resolved := make(map[string][]string, len(headers))
+	for key, values := range headers {
+		resolved[key] = make([]string, 0, len(values))
+		for _, value := range values {
+			value, err := resolveSecretReference(value)
+			if err != nil {
+				return nil, fmt.Errorf("header `%s`: %w", key, err)
+			}
+			resolved[key] = append(resolved[key], value)
+		}
 	}
+	return resolved, nil
+}
+
+// manifestFileExts is the extensions of manifest files loaded from directories.
+var manifestFileExts = []string{".yaml", ".yml", ".json", ".toml"}
 
-	return reverseproxy.Config{Proxies: proxies}, manifest.ACL, nil
+// manifestDirFilePaths returns the manifest files (`*.yaml`, `*.yml`, `*.json`, `*.toml`) in the directory, in lexical order.
+// Hidden files are skipped. (e.g. `..data` of Kubernetes ConfigMap volumes)
+func manifestDirFilePaths(dirPath string) ([]string, error) {
+	entries, err := os.ReadDir(dirPath)
+	if err != nil {
+		return nil, err
+	}
+	var paths []string
+	for _, entry := range entries {
+		name := entry.Name()
+		isManifest := slices.Some(manifestFileExts, func(ext string) bool { return filepath.Ext(name) == ext })
+		if strings.HasPrefix(name, ".") || !isManifest {
+			continue
+		}
+		path := filepath.Join(dirPath, name)
+		if /* follow symlinks */ info, err := os.Stat(path); err != nil || info.IsDir() {
+			continue
+		}
+		paths = append(paths, path)
+	}
+	return paths, nil
 }
 
-func validateURLformats(urls ...string) error {
-	for _, u := range urls {
-		_, err := url.Parse(u)
+// loadManifestFiles loads the manifest files and the included files, depth-first in order.
+// Files loaded already (e.g. included twice, or circular includes) are skipped.
+func loadManifestFiles(paths []string) ([]manifestFile, error) {
+	var files []manifestFile
+	loaded := map[string]bool{}
+
+	var load func(path string) error
+	load = func(path string) error {
+		absPath, err := filepath.Abs(path)
 		if err != nil {
 			return err
 		}
+		if loaded[absPath] {
+			return nil
+		}
+		loaded[absPath] = true
+
+		manifest, root, err := loadRevProxyACLManifest(path)
+		var typeErr *yaml.TypeError
+		if err != nil && !errors.As(err, &typeErr) {
+			return fmt.Errorf("`%s`: %w", path, err)
+		}
+		file := manifestFile{path: path, manifest: manifest, root: root}
+		if typeErr != nil {
+			file.typeErrs = typeErr.Errors
+		}
+		files = append(files, file)
+
+		for _, pattern := range manifest.Include {
+			includePaths, err := includeFilePaths(path, pattern)
+			if err != nil {
+				return fmt.Errorf("`%s`: include `%s`: %w", path, pattern, err)
+			}
+			for _, includePath := range includePaths {
+				if err := load(includePath); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+
+	for _, path := range paths {
+		if err := load(path); err != nil {
+			return nil, err
+		}
 	}
-	return nil
+	return files, nil
+}
+
+// includeFilePaths returns the files of the include pattern, relative to the including manifest file.
+// A glob pattern may match no files, but a file path must exist.
+func includeFilePaths(manifestFilePath, pattern string) ([]string, error) {
+	if !filepath.IsAbs(pattern) {
+		pattern = filepath.Join(filepath.Dir(manifestFilePath), pattern)
+	}
+	if !strings.ContainsAny(pattern, "*?[") {
+		return []string{pattern}, nil
+	}
+	return filepath.Glob(pattern)
+}
+
+// mergeManifests merges the manifests validated, in order.
+func mergeManifests(files []manifestFile) *RevProxyACLManifest {
+	merged := &RevProxyACLManifest{}
+	for _, file := range files {
+		merged.Proxies = append(merged.Proxies, file.manifest.Proxies...)
+		mergeInto(&merged.ACL, file.manifest.ACL)
+		mergeInto(&merged.ServiceAccounts, file.manifest.ServiceAccounts)
+		mergeInto(&merged.OAuth2, file.manifest.OAuth2)
+	}
+	return merged
+}
+
+func mergeInto[M ~map[K]V, K comparable, V any](dst *M, src M) {
+	if src == nil {
+		return
+	}
+	if *dst == nil {
+		*dst = make(M, len(src))
+	}
+	maps.Copy(*dst, src)
 }
 
 // Example usage:
 //
 //	```yaml
+//	include:
+//	  - "teams/*.yaml"                      # merge manifests of teams (relative to this file)
 //	proxies:
 //	  - external_url: "http://www.example.com/"
 //	    target: "http://www:80/"
@@ -99,17 +296,60 @@ func validateURLformats(urls ...string) error {
 //	          emails: ["admin@example.com"] # allow specified email user
 //	    roles:
 //	      "admin@example.com": ["admin"]
+//	service_accounts:
+//	  "ci-deployer":                        # client id
+//	    client_secret_hash: "sha256:..."    # (or bcrypt hash)
+//	oauth2:
+//	  "corp-gitlab":                        # provider instance name (used in `/.auth/corp-gitlab/login`)
+//	    type: gitlab                        # provider type
+//	    client_id: "..."
+//	    client_secret_file: /run/secrets/gitlab
+//	    base_url: "https://gitlab.example.com"
+//	    display_name: "Corp GitLab"
 //	```
-func loadRevProxyACLManifest(yamlFilePath string) (*RevProxyACLManifest, error) {
-	data, err := os.ReadFile(yamlFilePath)
+//
+// Unknown fields are reported as *yaml.TypeError with the line numbers, with the rest of the manifest decoded.
+// The root node is returned to locate the lines of semantic errors. (nil for TOML, as the lines are not of the file)
+//
+// JSON (`.json`) manifests are loaded as YAML, as JSON is YAML. TOML (`.toml`) manifests are converted to YAML with the same fields.
+func loadRevProxyACLManifest(filePath string) (*RevProxyACLManifest, *yaml.Node, error) {
+	data, err := os.ReadFile(filePath)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
+	}
+	if filepath.Ext(filePath) == ".toml" {
+		data, err = tomlToYAML(data)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return nil, nil, err
 	}
 
 	var manifest RevProxyACLManifest
-	if err := yaml.Unmarshal(data, &manifest); err != nil {
-		return nil, err
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&manifest); err != nil && !errors.Is(err, io.EOF) {
+		return &manifest, lineNode(filePath, &root), err
 	}
 
-	return &manifest, nil
+	return &manifest, lineNode(filePath, &root), nil
+}
+
+func lineNode(filePath string, root *yaml.Node) *yaml.Node {
+	if filepath.Ext(filePath) == ".toml" {
+		return nil
+	}
+	return root
+}
+
+func tomlToYAML(data []byte) ([]byte, error) {
+	var v map[string]any
+	if err := toml.Unmarshal(data, &v); err != nil {
+		return nil, err
+	}
+	return yaml.Marshal(v)
 }
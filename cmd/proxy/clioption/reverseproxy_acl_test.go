@@ -2,6 +2,7 @@ package clioption
 
 import (
 	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
@@ -10,10 +11,6 @@ import (
 	"github.com/tingtt/oauth2rbac/internal/acl"
 )
 
-func ptr[T any](v T) *T {
-	return &v
-}
-
 type loadRevProxyACLManifestTest struct {
 	name    string
 	rawYAML string
@@ -138,9 +135,237 @@ func Test_loadRevProxyACLManifest(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			tmpdir := t.TempDir()
 			os.WriteFile(tmpdir+"/manifest.yaml", []byte(tt.rawYAML), 0644)
-			got, err := loadRevProxyACLManifest(tmpdir + "/manifest.yaml")
+			got, _, err := loadRevProxyACLManifest(tmpdir + "/manifest.yaml")
 			assert.NoError(t, err)
 			assert.Equal(t, tt.want, got)
 		})
 	}
 }
+
+func writeManifests(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, rawYAML := range files {
+		os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), 0755)
+		os.WriteFile(filepath.Join(dir, name), []byte(dedent.Dedent(rawYAML)), 0644)
+	}
+	return dir
+}
+
+func TestLoadManifest(t *testing.T) {
+	t.Parallel()
+
+	teamWWW := `
+		proxies:
+		  - external_url: "http://www.example.com/"
+		    target: "http://www:80/"
+		acl:
+		  "http://www.example.com":
+		    paths:
+		      "/":
+		        - methods: ["GET"]
+		          emails: ["-"]
+	`
+	teamDocs := `
+		proxies:
+		  - external_url: "http://docs.example.com/"
+		    target: "http://docs:80/"
+		acl:
+		  "http://docs.example.com/":
+		    paths:
+		      "/":
+		        - methods: ["GET"]
+		          emails: ["*"]
+	`
+
+	t.Run("may merge included files", func(t *testing.T) {
+		t.Parallel()
+		dir := writeManifests(t, map[string]string{
+			"manifest.yaml": `
+				include:
+				  - "teams/*.yaml"
+				  - "manifest.yaml" # loaded once
+				service_accounts:
+				  "ci-deployer":
+				    client_secret_hash: "${CI_DEPLOYER_HASH}"
+			`,
+			"teams/docs.yaml": teamDocs,
+			"teams/www.yaml":  teamWWW,
+		})
+
+		got, err := LoadManifest(dir+"/manifest.yaml", "")
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Equal(t, []proxy{
+			{ExternalURL: "http://docs.example.com/", Target: "http://docs:80/"},
+			{ExternalURL: "http://www.example.com/", Target: "http://www:80/"},
+		}, got.Proxies)
+		assert.Len(t, got.ACL, 2)
+		assert.Len(t, got.ServiceAccounts, 1)
+		assert.Nil(t, got.Include)
+	})
+
+	t.Run("may load files in directory", func(t *testing.T) {
+		t.Parallel()
+		dir := writeManifests(t, map[string]string{
+			"docs.yml":              teamDocs,
+			"www.yaml":              teamWWW,
+			"README.md":             "not a manifest",
+			"..data/ignored.yaml":   "invalid: [",
+			".hidden.yaml":          "invalid: [",
+			"subdir.yaml/docs.yaml": teamDocs,
+		})
+
+		got, err := LoadManifest("", dir)
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Len(t, got.Proxies, 2)
+		assert.Len(t, got.ACL, 2)
+	})
+
+	t.Run("may report conflicts between files", func(t *testing.T) {
+		t.Parallel()
+		dir := writeManifests(t, map[string]string{
+			"a.yaml": teamWWW,
+			"b.yaml": teamWWW,
+			"c.yaml": `
+				oauth2:
+				  "github":
+				    type: github
+				    client_id: "id"
+			`,
+			"d.yaml": `
+				oauth2:
+				  "github":
+				    type: github
+				    client_id: "id"
+			`,
+		})
+
+		_, err := LoadManifest("", dir)
+		if !assert.Error(t, err) {
+			return
+		}
+		for _, want := range []string{
+			"invalid manifests:",
+			dir + "/b.yaml: line 3: proxies[0].external_url: duplicated external URL `http://www.example.com/` (also in proxies[0] of `" + dir + "/a.yaml`)",
+			dir + "/b.yaml: line 6: acl[\"http://www.example.com\"]: duplicated origin `http://www.example.com` (also in `" + dir + "/a.yaml`)",
+			dir + "/d.yaml: line 3: oauth2.github: duplicated oauth2 client `github` (also in `" + dir + "/c.yaml`)",
+		} {
+			assert.Contains(t, err.Error(), want)
+		}
+	})
+
+	t.Run("may fail with missing include", func(t *testing.T) {
+		t.Parallel()
+		dir := writeManifests(t, map[string]string{
+			"manifest.yaml": `
+				include: ["teams/www.yaml"]
+			`,
+		})
+
+		_, err := LoadManifest(dir+"/manifest.yaml", "")
+		assert.ErrorContains(t, err, "teams/www.yaml")
+	})
+}
+
+func Test_loadRevProxyACLManifest_JSONAndTOML(t *testing.T) {
+	t.Parallel()
+
+	want := &RevProxyACLManifest{
+		Proxies: []proxy{{ExternalURL: "http://www.example.com/", Target: "http://www:80/"}},
+		ACL: acl.Pool{
+			"http://www.example.com": acl.ScopeOrigin{
+				PathScopes: map[string][]acl.ScopePath{
+					"/": {{Methods: []string{"GET"}, EmailRegexes: []acl.EmailRegex{"-"}}},
+				},
+				OriginConfig: acl.OriginConfig{
+					JWTExpiryIn: ptr(acl.JWTExpiryIn(3 * time.Hour)),
+					ErrorPages:  map[int]string{403: "/etc/oauth2rbac/403.html"},
+				},
+			},
+		},
+	}
+
+	for name, rawManifest := range map[string]string{
+		"manifest.json": `{
+			"proxies": [{"external_url": "http://www.example.com/", "target": "http://www:80/"}],
+			"acl": {
+				"http://www.example.com": {
+					"jwt_expiry_in": "3h",
+					"error_pages": {"403": "/etc/oauth2rbac/403.html"},
+					"paths": {"/": [{"methods": ["GET"], "emails": ["-"]}]}
+				}
+			}
+		}`,
+		"manifest.toml": dedent.Dedent(`
+			[[proxies]]
+			external_url = "http://www.example.com/"
+			target = "http://www:80/"
+
+			[acl."http://www.example.com"]
+			jwt_expiry_in = "3h"
+			error_pages = { 403 = "/etc/oauth2rbac/403.html" }
+
+			[[acl."http://www.example.com".paths."/"]]
+			methods = ["GET"]
+			emails = ["-"]
+		`),
+	} {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			filePath := t.TempDir() + "/" + name
+			os.WriteFile(filePath, []byte(rawManifest), 0644)
+
+			got, _, err := loadRevProxyACLManifest(filePath)
+			assert.NoError(t, err)
+			assert.Equal(t, want, got)
+		})
+	}
+
+	t.Run("JSON keys like integers may be kept as strings", func(t *testing.T) {
+		t.Parallel()
+		filePath := t.TempDir() + "/manifest.json"
+		os.WriteFile(filePath, []byte(`{
+			"proxies": [{"external_url": "http://www.example.com/", "target": "http://www:80/", "set_headers": {"X-Team": ["007"]}}],
+			"acl": {"http://www.example.com": {"roles": {"007": ["*@example.com"]}, "paths": {"/": [{"methods": ["GET"], "emails": ["-"]}]}}}
+		}`), 0644)
+
+		got, _, err := loadRevProxyACLManifest(filePath)
+		assert.NoError(t, err)
+		assert.Equal(t, map[string][]acl.EmailRegex{"007": {"*@example.com"}}, got.ACL["http://www.example.com"].Roles)
+		assert.Equal(t, map[string][]string{"X-Team": {"007"}}, got.Proxies[0].SetHeaders)
+	})
+
+	t.Run("error pages may reject invalid status codes", func(t *testing.T) {
+		t.Parallel()
+		filePath := t.TempDir() + "/manifest.json"
+		os.WriteFile(filePath, []byte(`{"acl": {"http://www.example.com": {"error_pages": {"not-found": "/404.html"}}}}`), 0644)
+
+		_, _, err := loadRevProxyACLManifest(filePath)
+		assert.ErrorContains(t, err, "invalid status code `not-found`")
+	})
+
+	t.Run("JSON errors may have line numbers", func(t *testing.T) {
+		t.Parallel()
+		filePath := t.TempDir() + "/manifest.json"
+		os.WriteFile(filePath, []byte("{\n  \"proxies\": [],\n  \"unknown\": true\n}"), 0644)
+
+		_, err := LoadManifest(filePath, "")
+		assert.ErrorContains(t, err, "line 3: field unknown not found")
+	})
+
+	t.Run("TOML errors may not have line numbers", func(t *testing.T) {
+		t.Parallel()
+		filePath := t.TempDir() + "/manifest.toml"
+		os.WriteFile(filePath, []byte("unknown = true\n"), 0644)
+
+		_, err := LoadManifest(filePath, "")
+		if assert.Error(t, err) {
+			assert.Contains(t, err.Error(), "field unknown not found")
+			assert.NotContains(t, err.Error(), "line")
+		}
+	})
+}
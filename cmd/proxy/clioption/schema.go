@@ -0,0 +1,39 @@
+package clioption
+
+import (
+	"reflect"
+	"slices"
+
+	"github.com/tingtt/oauth2rbac/internal/oauth2"
+	"github.com/tingtt/oauth2rbac/internal/util/jsonschema"
+)
+
+// ManifestSchema returns the JSON Schema of the manifest, for editors to validate manifests.
+// Semantic errors (e.g. unreachable path rules) are reported only by `oauth2rbac config lint`.
+func ManifestSchema() *jsonschema.Schema {
+	schema := jsonschema.For(reflect.TypeFor[RevProxyACLManifest]())
+	schema.Schema = jsonschema.Draft
+	schema.Title = "oauth2rbac manifest"
+
+	proxy := schema.Properties["proxies"].Items
+	proxy.Required = []string{"external_url", "target"}
+	proxy.Properties["external_url"].Pattern = `^https?://[^/]+`
+	proxy.Properties["target"].Pattern = `^https?://[^/]+`
+
+	origins := schema.Properties["acl"]
+	origins.PropertyNames = &jsonschema.Schema{Pattern: `^https?://[^/]+/?$`}
+	origins.AdditionalProperties.Properties["paths"].PropertyNames = &jsonschema.Schema{Pattern: `^/`}
+
+	serviceAccount := schema.Properties["service_accounts"].AdditionalProperties
+	serviceAccount.Required = []string{"client_secret_hash"}
+
+	oauth2Clients := schema.Properties["oauth2"]
+	oauth2Clients.PropertyNames = &jsonschema.Schema{Pattern: oauth2ClientNameRegex.String()}
+	oauth2Client := oauth2Clients.AdditionalProperties
+	oauth2Client.Required = []string{"type", "client_id"}
+	for _, providerType := range slices.Sorted(slices.Values(oauth2.ProviderNames())) {
+		oauth2Client.Properties["type"].Enum = append(oauth2Client.Properties["type"].Enum, providerType)
+	}
+
+	return schema
+}
@@ -0,0 +1,65 @@
+package clioption
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/tingtt/oauth2rbac/internal/util/jsonschema"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestManifestSchema(t *testing.T) {
+	t.Parallel()
+
+	schema := ManifestSchema()
+	_, err := json.Marshal(schema)
+	assert.NoError(t, err)
+
+	assert.ElementsMatch(t, []string{"include", "proxies", "acl", "service_accounts", "oauth2"}, keys(schema.Properties))
+
+	t.Run("may include inline fields of ACL", func(t *testing.T) {
+		t.Parallel()
+		scopeOrigin := schema.Properties["acl"].AdditionalProperties
+		assert.Contains(t, scopeOrigin.Properties, "paths")
+		assert.Contains(t, scopeOrigin.Properties, "jwt_expiry_in")
+		assert.Contains(t, scopeOrigin.Properties, "step_up_paths")
+	})
+
+	t.Run("may disallow unknown fields", func(t *testing.T) {
+		t.Parallel()
+		rawJSON, _ := json.Marshal(schema.Properties["proxies"].Items)
+		assert.Contains(t, string(rawJSON), `"additionalProperties":false`)
+	})
+
+	t.Run("may enumerate provider types", func(t *testing.T) {
+		t.Parallel()
+		assert.Contains(t, schema.Properties["oauth2"].AdditionalProperties.Properties["type"].Enum, "github")
+	})
+}
+
+func TestManifestSchema_roundTrip(t *testing.T) {
+	t.Parallel()
+
+	want := ManifestSchema()
+	rawJSON, err := json.Marshal(want)
+	if !assert.NoError(t, err) {
+		return
+	}
+	var got jsonschema.Schema
+	assert.NoError(t, json.Unmarshal(rawJSON, &got))
+	assert.Equal(t, want, &got)
+
+	// stable for editors to cache
+	rawJSONAgain, err := json.Marshal(&got)
+	assert.NoError(t, err)
+	assert.JSONEq(t, string(rawJSON), string(rawJSONAgain))
+}
+
+func keys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	return keys
+}
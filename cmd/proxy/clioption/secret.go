@@ -0,0 +1,92 @@
+package clioption
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// envPrefix is the prefix of environment variables of CLI options. (e.g. `OAUTH2RBAC_JWT_SECRET`)
+const envPrefix = "OAUTH2RBAC_"
+
+// secretFromOptions returns the secret given by the CLI option, the file of the CLI option,
+// the environment variable `OAUTH2RBAC_<NAME>`, or the file of the environment variable `OAUTH2RBAC_<NAME>_FILE`, in this order.
+func secretFromOptions(flagName, value, filePath string) (string, error) {
+	if value != "" && filePath != "" {
+		return "", fmt.Errorf("CLI option `--%s` and `--%s-file` cannot be used together", flagName, flagName)
+	}
+	if value != "" {
+		return value, nil
+	}
+	if filePath != "" {
+		return readSecretFile(filePath)
+	}
+
+	envName := envPrefix + strings.ToUpper(strings.ReplaceAll(flagName, "-", "_"))
+	if value, ok := os.LookupEnv(envName); ok && value != "" {
+		return value, nil
+	}
+	if filePath, ok := os.LookupEnv(envName + "_FILE"); ok && filePath != "" {
+		return readSecretFile(filePath)
+	}
+	return "", nil
+}
+
+// readSecretFile reads the secret from the file, without the trailing newline.
+// The error does not contain the file content.
+func readSecretFile(filePath string) (string, error) {
+	b, err := os.ReadFile(filePath)
+	if err != nil {
+		return "", fmt.Errorf("failed to read secret file: %w", err)
+	}
+	return strings.TrimRight(string(b), "\r\n"), nil
+}
+
+// envReferenceRegex matches `${ENV}`, and `$${ENV}` escaping it.
+var envReferenceRegex = regexp.MustCompile(`\$?\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// isSecretReference reports whether the manifest value has `${ENV}` or `file:` references. (not escaped)
+func isSecretReference(value string) bool {
+	if strings.HasPrefix(value, "file:") {
+		return true
+	}
+	for _, reference := range envReferenceRegex.FindAllString(value, -1) {
+		if !strings.HasPrefix(reference, "$$") {
+			return true
+		}
+	}
+	return false
+}
+
+// resolveSecretReference resolves the references in the manifest value.
+//   - `file:<path>`: The content of the file. (whole value only)
+//   - `${ENV}`: The environment variable. (undefined variables are not allowed)
+//
+// The references are escaped with `$` to be kept as is. (e.g. `$file:<path>` and `$${ENV}`)
+func resolveSecretReference(value string) (string, error) {
+	if filePath, ok := strings.CutPrefix(value, "file:"); ok {
+		return readSecretFile(filePath)
+	}
+	if literal, ok := strings.CutPrefix(value, "$file:"); ok {
+		value = "file:" + literal
+	}
+
+	var errs []error
+	resolved := envReferenceRegex.ReplaceAllStringFunc(value, func(reference string) string {
+		if escaped, ok := strings.CutPrefix(reference, "$$"); ok {
+			return "$" + escaped
+		}
+		envName := envReferenceRegex.FindStringSubmatch(reference)[1]
+		envValue, ok := os.LookupEnv(envName)
+		if !ok {
+			errs = append(errs, fmt.Errorf("environment variable `%s` is not defined", envName))
+		}
+		return envValue
+	})
+	if len(errs) != 0 {
+		return "", errors.Join(errs...)
+	}
+	return resolved, nil
+}
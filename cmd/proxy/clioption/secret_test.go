@@ -0,0 +1,91 @@
+package clioption
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_secretFromOptions(t *testing.T) {
+	secretFile := t.TempDir() + "/secret"
+	os.WriteFile(secretFile, []byte("secret-from-file\n"), 0600)
+
+	t.Run("may prefer CLI option", func(t *testing.T) {
+		t.Setenv("OAUTH2RBAC_JWT_SECRET", "secret-from-env")
+		got, err := secretFromOptions("jwt-secret", "secret", "")
+		assert.NoError(t, err)
+		assert.Equal(t, "secret", got)
+	})
+
+	t.Run("may read file of CLI option", func(t *testing.T) {
+		got, err := secretFromOptions("jwt-secret", "", secretFile)
+		assert.NoError(t, err)
+		assert.Equal(t, "secret-from-file", got)
+	})
+
+	t.Run("may reject both CLI option and file", func(t *testing.T) {
+		_, err := secretFromOptions("jwt-secret", "secret", secretFile)
+		assert.Error(t, err)
+	})
+
+	t.Run("may use environment variable", func(t *testing.T) {
+		t.Setenv("OAUTH2RBAC_JWT_SECRET", "secret-from-env")
+		got, err := secretFromOptions("jwt-secret", "", "")
+		assert.NoError(t, err)
+		assert.Equal(t, "secret-from-env", got)
+	})
+
+	t.Run("may read file of environment variable", func(t *testing.T) {
+		t.Setenv("OAUTH2RBAC_JWT_SECRET_FILE", secretFile)
+		got, err := secretFromOptions("jwt-secret", "", "")
+		assert.NoError(t, err)
+		assert.Equal(t, "secret-from-file", got)
+	})
+}
+
+func Test_resolveSecretReference(t *testing.T) {
+	secretFile := t.TempDir() + "/secret"
+	os.WriteFile(secretFile, []byte("secret-from-file\n"), 0600)
+	t.Setenv("TEST_SECRET", "secret-from-env")
+
+	tests := []struct {
+		name    string
+		value   string
+		want    string
+		wantErr bool
+	}{
+		{name: "may keep plain value", value: "plain $TEST_SECRET", want: "plain $TEST_SECRET"},
+		{name: "may read file", value: "file:" + secretFile, want: "secret-from-file"},
+		{name: "may expand environment variable", value: "Bearer ${TEST_SECRET}", want: "Bearer secret-from-env"},
+		{name: "may reject undefined environment variable", value: "${TEST_UNDEFINED}", wantErr: true},
+		{name: "may keep escaped environment variable", value: "Bearer $${TEST_SECRET} ${TEST_SECRET} $${TEST_UNDEFINED}", want: "Bearer ${TEST_SECRET} secret-from-env ${TEST_UNDEFINED}"},
+		{name: "may keep escaped file", value: "$file:" + secretFile, want: "file:" + secretFile},
+		{name: "may reject missing file", value: "file:" + secretFile + ".missing", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := resolveSecretReference(tt.value)
+			if tt.wantErr {
+				assert.Error(t, err)
+				assert.NotContains(t, err.Error(), "secret-from")
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func Test_isSecretReference(t *testing.T) {
+	for value, want := range map[string]bool{
+		"file:/run/secrets/api-key":  true,
+		"Bearer ${API_KEY}":          true,
+		"$${LITERAL} ${API_KEY}":     true,
+		"plain":                      false,
+		"$file:/run/secrets/api-key": false,
+		"Bearer $${LITERAL}":         false,
+	} {
+		assert.Equal(t, want, isSecretReference(value), value)
+	}
+}
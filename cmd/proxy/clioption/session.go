@@ -0,0 +1,22 @@
+package clioption
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/tingtt/oauth2rbac/internal/session"
+)
+
+// sessionStore creates session store from `--session-store` option.
+// (format: `memory` or `file:<FilePath>`, sessions are disabled if empty)
+func sessionStore(option string) (session.Store, error) {
+	switch {
+	case option == "":
+		return nil, nil
+	case option == "memory":
+		return session.NewMemoryStore(), nil
+	case strings.HasPrefix(option, "file:"):
+		return session.NewFileStore(strings.TrimPrefix(option, "file:"))
+	}
+	return nil, fmt.Errorf("invalid session store `%s` (format: `memory` or `file:<FilePath>`)", option)
+}
@@ -0,0 +1,304 @@
+package clioption
+
+import (
+	"cmp"
+	"fmt"
+	"maps"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/tingtt/oauth2rbac/internal/oauth2"
+
+	"gopkg.in/yaml.v3"
+)
+
+// validMethods is the methods allowed in ACL. ("*" allows all methods.)
+var validMethods = []string{
+	"*",
+	http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch,
+	http.MethodDelete, http.MethodConnect, http.MethodOptions, http.MethodTrace,
+}
+
+// manifestValidator collects all semantic errors of the manifest files, with the line numbers.
+type manifestValidator struct {
+	files []manifestFile
+	errs  []manifestError
+}
+
+type manifestError struct {
+	file int
+	line int
+	msg  string
+}
+
+// manifestLocation is the file and the index of an item declared in the manifest files.
+type manifestLocation struct {
+	file  int
+	index int
+}
+
+// validateManifest checks the semantics of the manifest files as merged, and returns the error messages.
+// (e.g. "line 3: proxies[0].external_url: ...", prefixed with the file path if there are multiple files, without lines for TOML)
+// Origins, external URLs, service accounts and OAuth2 clients cannot be declared in multiple files.
+func validateManifest(files []manifestFile) []string {
+	v := &manifestValidator{files: files}
+
+	for f, file := range files {
+		for _, msg := range file.typeErrs {
+			var line int
+			if _, err := fmt.Sscanf(msg, "line %d:", &line); err == nil {
+				_, msg, _ = strings.Cut(msg, ": ")
+			}
+			if /* lines are not of the file (e.g. TOML) */ file.root == nil {
+				line = 0
+			}
+			v.errs = append(v.errs, manifestError{file: f, line: line, msg: msg})
+		}
+	}
+
+	proxyOrigins := map[string][]string{} // origin -> paths of external URLs
+	proxyOriginLocations := map[string]manifestLocation{}
+	externalURLs := map[string]manifestLocation{}
+	for f, file := range files {
+		for i, proxy := range file.manifest.Proxies {
+			if externalURL, ok := v.validateURL(f, proxy.ExternalURL, "proxies", i, "external_url"); ok {
+				origin := externalURL.Scheme + "://" + externalURL.Host
+				if _, ok := proxyOrigins[origin]; !ok {
+					proxyOriginLocations[origin] = manifestLocation{f, i}
+				}
+				proxyOrigins[origin] = append(proxyOrigins[origin], externalURL.Path)
+			}
+			v.validateURL(f, proxy.Target, "proxies", i, "target")
+
+			if first, duplicated := externalURLs[proxy.ExternalURL]; duplicated {
+				v.errorf(f, []any{"proxies", i, "external_url"}, "duplicated external URL `%s` (also in proxies[%d]%s)", proxy.ExternalURL, first.index, v.of(f, first.file))
+			} else {
+				externalURLs[proxy.ExternalURL] = manifestLocation{f, i}
+			}
+		}
+	}
+
+	aclOrigins := map[string]int{} // sanitized origin -> file
+	for f, file := range files {
+		for _, origin := range slices.Sorted(maps.Keys(file.manifest.ACL)) {
+			scope := file.manifest.ACL[origin]
+			sanitizedOrigin := strings.TrimSuffix(origin, "/")
+			if u, err := url.Parse(sanitizedOrigin); err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") || u.Path != "" {
+				v.errorf(f, []any{"acl", origin}, "origin must be `<http|https>://<host>`")
+			}
+			if first, duplicated := aclOrigins[sanitizedOrigin]; duplicated {
+				v.errorf(f, []any{"acl", origin}, "duplicated origin `%s`%s", sanitizedOrigin, v.alsoIn(f, first))
+			} else {
+				aclOrigins[sanitizedOrigin] = f
+			}
+			externalURLPaths, proxied := proxyOrigins[sanitizedOrigin]
+			if !proxied {
+				v.errorf(f, []any{"acl", origin}, "no proxy of the origin found in proxies")
+			}
+
+			for _, path := range slices.Sorted(maps.Keys(scope.PathScopes)) {
+				if !strings.HasPrefix(path, "/") {
+					v.errorf(f, []any{"acl", origin, "paths", path}, "unreachable path rule (path must start with `/`)")
+				} else if proxied && !slices.ContainsFunc(externalURLPaths, func(externalURLPath string) bool {
+					return strings.HasPrefix(path, externalURLPath) || strings.HasPrefix(externalURLPath, path)
+				}) {
+					v.errorf(f, []any{"acl", origin, "paths", path}, "unreachable path rule (no proxy of the path found in proxies)")
+				}
+
+				for i, scopePath := range scope.PathScopes[path] {
+					if len(scopePath.Methods) == 0 {
+						v.errorf(f, []any{"acl", origin, "paths", path, i}, "methods is empty")
+					}
+					for j, method := range scopePath.Methods {
+						if !slices.Contains(validMethods, strings.ToUpper(method)) {
+							v.errorf(f, []any{"acl", origin, "paths", path, i, "methods", j}, "invalid HTTP method `%s`", method)
+						}
+					}
+				}
+			}
+		}
+	}
+
+	for _, origin := range slices.Sorted(maps.Keys(proxyOrigins)) {
+		if _, found := aclOrigins[origin]; !found {
+			location := proxyOriginLocations[origin]
+			v.errorf(location.file, []any{"proxies", location.index, "external_url"}, "no ACL of the origin `%s` found in acl", origin)
+		}
+	}
+
+	clientIDs := map[string]int{}
+	for f, file := range files {
+		for _, clientID := range slices.Sorted(maps.Keys(file.manifest.ServiceAccounts)) {
+			if clientID == "" {
+				v.errorf(f, []any{"service_accounts", clientID}, "client id cannot be empty")
+			}
+			if first, duplicated := clientIDs[clientID]; duplicated {
+				v.errorf(f, []any{"service_accounts", clientID}, "duplicated service account `%s`%s", clientID, v.alsoIn(f, first))
+			} else {
+				clientIDs[clientID] = f
+			}
+			secretHash := file.manifest.ServiceAccounts[clientID].ClientSecretHash
+			if /* resolved on loading */ isSecretReference(string(secretHash)) {
+				continue
+			}
+			if err := secretHash.Validate(); err != nil {
+				v.errorf(f, []any{"service_accounts", clientID, "client_secret_hash"}, "%s", err.Error())
+			}
+		}
+	}
+
+	oauth2ClientNames := map[string]int{}
+	for f, file := range files {
+		for _, name := range slices.Sorted(maps.Keys(file.manifest.OAuth2)) {
+			client := file.manifest.OAuth2[name]
+			if !oauth2ClientNameRegex.MatchString(name) {
+				v.errorf(f, []any{"oauth2", name}, "name must match `%s`", oauth2ClientNameRegex)
+			}
+			if first, duplicated := oauth2ClientNames[name]; duplicated {
+				v.errorf(f, []any{"oauth2", name}, "duplicated oauth2 client `%s`%s", name, v.alsoIn(f, first))
+			} else {
+				oauth2ClientNames[name] = f
+			}
+			if _, supported := oauth2.Providers[client.Type]; !supported {
+				v.errorf(f, []any{"oauth2", name, "type"}, "oauth2 provider type `%s` is not supported (supported: %s)",
+					client.Type, strings.Join(slices.Sorted(slices.Values(oauth2.ProviderNames())), ", "))
+			}
+			if client.ClientID == "" {
+				v.errorf(f, []any{"oauth2", name}, "client_id is required")
+			}
+			if client.ClientSecret != "" && client.ClientSecretFile != "" {
+				v.errorf(f, []any{"oauth2", name, "client_secret_file"}, "client_secret and client_secret_file cannot be used together")
+			}
+			if client.Icon != "" {
+				if err := validateIcon(client.Icon); err != nil {
+					v.errorf(f, []any{"oauth2", name, "icon"}, "%s", err.Error())
+				}
+			}
+		}
+	}
+
+	return v.messages()
+}
+
+// validateURL checks the URL has the scheme (http or https) and the host.
+func (v *manifestValidator) validateURL(file int, rawURL string, keys ...any) (*url.URL, bool) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		v.errorf(file, keys, "invalid URL: %s", err.Error())
+		return nil, false
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		v.errorf(file, keys, "URL `%s` must have the scheme `http` or `https`", rawURL)
+		return nil, false
+	}
+	if u.Host == "" {
+		v.errorf(file, keys, "URL `%s` must have the host", rawURL)
+		return nil, false
+	}
+	return u, true
+}
+
+func (v *manifestValidator) errorf(file int, keys []any, format string, args ...any) {
+	v.errs = append(v.errs, manifestError{
+		file: file,
+		line: nodeLine(v.files[file].root, keys...),
+		msg:  keyPath(keys...) + ": " + fmt.Sprintf(format, args...),
+	})
+}
+
+// of returns " of `<path>`" if the item is in another file, to refer to the item. (e.g. "proxies[0] of `a.yaml`")
+func (v *manifestValidator) of(file, other int) string {
+	if file == other {
+		return ""
+	}
+	return " of `" + v.files[other].path + "`"
+}
+
+// alsoIn returns " (also in `<path>`)" if the item is also declared in another file.
+func (v *manifestValidator) alsoIn(file, other int) string {
+	if file == other {
+		return ""
+	}
+	return " (also in `" + v.files[other].path + "`)"
+}
+
+// messages returns the error messages sorted by the files and the line numbers.
+func (v *manifestValidator) messages() []string {
+	slices.SortStableFunc(v.errs, func(a, b manifestError) int {
+		return cmp.Or(cmp.Compare(a.file, b.file), cmp.Compare(a.line, b.line))
+	})
+	msgs := make([]string, 0, len(v.errs))
+	for _, err := range v.errs {
+		msg := err.msg
+		if err.line != 0 {
+			msg = fmt.Sprintf("line %d: %s", err.line, err.msg)
+		}
+		if len(v.files) > 1 {
+			msg = v.files[err.file].path + ": " + msg
+		}
+		msgs = append(msgs, msg)
+	}
+	return msgs
+}
+
+// nodeLine returns the line of the node of the keys (string for mapping keys, int for sequence indexes).
+// If the node is not found, the line of the nearest parent is returned.
+func nodeLine(node *yaml.Node, keys ...any) int {
+	if node == nil {
+		return 0
+	}
+	if node.Kind == yaml.DocumentNode && len(node.Content) != 0 {
+		node = node.Content[0]
+	}
+	line := node.Line
+	for _, key := range keys {
+		var next *yaml.Node
+		switch key := key.(type) {
+		case string:
+			if node.Kind != yaml.MappingNode {
+				return line
+			}
+			for i := 0; i+1 < len(node.Content); i += 2 {
+				if node.Content[i].Value == key {
+					line, next = node.Content[i].Line, node.Content[i+1]
+					break
+				}
+			}
+		case int:
+			if node.Kind != yaml.SequenceNode || key < 0 || key >= len(node.Content) {
+				return line
+			}
+			next = node.Content[key]
+			line = next.Line
+		}
+		if next == nil {
+			return line
+		}
+		node = next
+	}
+	return line
+}
+
+// keyPath returns the path of the keys. (e.g. `acl["http://www.example.com"].paths["/"][0].methods`)
+func keyPath(keys ...any) string {
+	var b strings.Builder
+	for i, key := range keys {
+		switch key := key.(type) {
+		case string:
+			if i != 0 && strings.ContainsAny(key, ":/.*@ ") {
+				b.WriteString("[" + strconv.Quote(key) + "]")
+				continue
+			}
+			if i != 0 {
+				b.WriteString(".")
+			}
+			b.WriteString(key)
+		case int:
+			b.WriteString("[" + strconv.Itoa(key) + "]")
+		}
+	}
+	return b.String()
+}
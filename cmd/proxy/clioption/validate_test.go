@@ -0,0 +1,74 @@
+package clioption
+
+import (
+	"os"
+	"testing"
+
+	"github.com/lithammer/dedent"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_loadAndValidateManifest(t *testing.T) {
+	t.Parallel()
+
+	t.Run("may accept valid manifest", func(t *testing.T) {
+		t.Parallel()
+		for _, tt := range loadRevProxyACLManifestTests {
+			filePath := t.TempDir() + "/manifest.yaml"
+			os.WriteFile(filePath, []byte(tt.rawYAML), 0644)
+
+			_, _, err := loadAndValidateManifest(filePath, "")
+			assert.NoError(t, err)
+		}
+	})
+
+	t.Run("may report all errors with line numbers", func(t *testing.T) {
+		t.Parallel()
+		filePath := t.TempDir() + "/manifest.yaml"
+		os.WriteFile(filePath, []byte(dedent.Dedent(`
+			proxies:
+			  - external_url: "http://www.example.com/"
+			    target: "http://www:80/"
+			  - external_url: "www.example.com/blog/"
+			    target: "http://blog:80/"
+			  - external_url: "http://www.example.com/"
+			    target: "http://www2:80/"
+			  - external_url: "http://docs.example.com/"
+			    target: "docs"
+			acl:
+			  "http://www.example.com":
+			    paths:
+			      "/":
+			        - mothods: ["GET"]
+			          emails: ["-"]
+			        - methods: ["GET", "FETCH"]
+			          emails: ["*"]
+			      "api/":
+			        - methods: ["*"]
+			          emails: ["*"]
+			  "http://unknown.example.com":
+			    paths:
+			      "/":
+			        - methods: ["*"]
+			          emails: ["*"]
+		`)), 0644)
+
+		_, _, err := loadAndValidateManifest(filePath, "")
+		if !assert.Error(t, err) {
+			return
+		}
+		for _, want := range []string{
+			"line 15: field mothods not found",
+			"line 5: proxies[1].external_url: URL `www.example.com/blog/` must have the scheme `http` or `https`",
+			"line 7: proxies[2].external_url: duplicated external URL `http://www.example.com/` (also in proxies[0])",
+			"line 10: proxies[3].target: URL `docs` must have the scheme `http` or `https`",
+			"line 15: acl[\"http://www.example.com\"].paths[\"/\"][0]: methods is empty",
+			"line 17: acl[\"http://www.example.com\"].paths[\"/\"][1].methods[1]: invalid HTTP method `FETCH`",
+			"line 19: acl[\"http://www.example.com\"].paths[\"api/\"]: unreachable path rule",
+			"line 22: acl[\"http://unknown.example.com\"]: no proxy of the origin found in proxies",
+			"line 9: proxies[3].external_url: no ACL of the origin `http://docs.example.com` found in acl",
+		} {
+			assert.Contains(t, err.Error(), want)
+		}
+	})
+}
@@ -0,0 +1,93 @@
+package configcmd
+
+import (
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/tingtt/oauth2rbac/cmd/proxy/clioption"
+
+	"github.com/spf13/pflag"
+	"gopkg.in/yaml.v3"
+)
+
+const usage = `Usage: oauth2rbac config <command> [-f <manifest file path>] [--config.dir <manifest directory path>]
+
+Commands:
+  lint    Validate the manifest, and exit non-zero with the errors.
+  dump    Print the manifest as enforced by the proxy (includes merged, ACL sanitized, defaults filled, secrets redacted).
+  schema  Print the JSON Schema of the manifest.
+`
+
+// Run runs `config` subcommands with the args after `config`, and returns the exit code.
+func Run(args []string, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		fmt.Fprint(stderr, usage)
+		return 2
+	}
+	command, args := args[0], args[1:]
+
+	flags := pflag.NewFlagSet("config "+command, pflag.ContinueOnError)
+	flags.SetOutput(stderr)
+	manifestFilePath := flags.StringP("config.file", "f", "/etc/oauth2rbac/config.file", "Manifest file path")
+	manifestDirPath := flags.String("config.dir", "", "Directory of manifest files (*.yaml, *.yml) merged with --config.file (if given explicitly)")
+	if err := flags.Parse(args); err != nil {
+		if errors.Is(err, pflag.ErrHelp) {
+			return 0
+		}
+		return 2
+	}
+
+	filePath := clioption.ManifestFilePath(flags, *manifestFilePath, *manifestDirPath)
+	switch command {
+	case "lint":
+		return lint(filePath, *manifestDirPath, stdout, stderr)
+	case "dump":
+		return dump(filePath, *manifestDirPath, stdout, stderr)
+	case "schema":
+		return schema(stdout, stderr)
+	default:
+		fmt.Fprintf(stderr, "unknown command `%s`\n\n%s", command, usage)
+		return 2
+	}
+}
+
+func lint(filePath, dirPath string, stdout, stderr io.Writer) int {
+	if _, err := clioption.LoadManifest(filePath, dirPath); err != nil {
+		fmt.Fprintln(stderr, err.Error())
+		return 1
+	}
+	fmt.Fprintf(stdout, "%s: ok\n", cmp.Or(filePath, dirPath))
+	return 0
+}
+
+func dump(filePath, dirPath string, stdout, stderr io.Writer) int {
+	manifest, err := clioption.LoadManifest(filePath, dirPath)
+	if err != nil {
+		fmt.Fprintln(stderr, err.Error())
+		return 1
+	}
+	encoder := yaml.NewEncoder(stdout)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(manifest.Normalized()); err != nil {
+		fmt.Fprintln(stderr, err.Error())
+		return 1
+	}
+	if err := encoder.Close(); err != nil {
+		fmt.Fprintln(stderr, err.Error())
+		return 1
+	}
+	return 0
+}
+
+func schema(stdout, stderr io.Writer) int {
+	encoder := json.NewEncoder(stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(clioption.ManifestSchema()); err != nil {
+		fmt.Fprintln(stderr, err.Error())
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,111 @@
+package configcmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"testing"
+
+	"github.com/lithammer/dedent"
+	"github.com/stretchr/testify/assert"
+)
+
+func writeManifest(t *testing.T, rawYAML string) string {
+	t.Helper()
+	filePath := t.TempDir() + "/manifest.yaml"
+	os.WriteFile(filePath, []byte(dedent.Dedent(rawYAML)), 0644)
+	return filePath
+}
+
+func TestRun(t *testing.T) {
+	t.Parallel()
+
+	valid := writeManifest(t, `
+		proxies:
+		  - external_url: "http://www.example.com/"
+		    target: "http://www:80/"
+		    set_headers:
+		      Authorization: ["Bearer sk-live-xyz${SUFFIX}"]
+		      X-Api-Key: ["literal-api-key"]
+		      X-Env-Key: ["${API_KEY}"]
+		      X-File-Key: ["file:/run/secrets/api-key"]
+		acl:
+		  "http://www.example.com/":
+		    paths:
+		      "/":
+		        - methods: ["get", "GET"]
+		          emails: ["*"]
+		service_accounts:
+		  "ci-deployer":
+		    client_secret_hash: "sha256:2bb80d537b1da3e38bd30361aa855686bde0eacd7162fef6a25fe97bf527a25b"
+		oauth2:
+		  "corp-github":
+		    type: github
+		    client_id: "id"
+		    client_secret: "secret"
+	`)
+	invalid := writeManifest(t, `
+		proxies:
+		  - external_url: "www.example.com"
+		    target: "http://www:80/"
+		acl:
+		  "http://www.example.com":
+		    paths:
+		      "/":
+		        - mothods: ["GET"]
+	`)
+
+	t.Run("lint may succeed", func(t *testing.T) {
+		t.Parallel()
+		stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+
+		assert.Equal(t, 0, Run([]string{"lint", "-f", valid}, stdout, stderr))
+		assert.Empty(t, stderr.String())
+	})
+
+	t.Run("lint may exit non-zero with all errors", func(t *testing.T) {
+		t.Parallel()
+		stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+
+		assert.Equal(t, 1, Run([]string{"lint", "-f", invalid}, stdout, stderr))
+		assert.Contains(t, stderr.String(), "line 3: proxies[0].external_url")
+		assert.Contains(t, stderr.String(), "line 9: field mothods not found")
+		assert.Contains(t, stderr.String(), "methods is empty")
+	})
+
+	t.Run("dump may print normalized manifest without secrets", func(t *testing.T) {
+		t.Parallel()
+		stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+
+		assert.Equal(t, 0, Run([]string{"dump", "-f", valid}, stdout, stderr))
+		got := stdout.String()
+		assert.Contains(t, got, "http://www.example.com:\n")
+		assert.Contains(t, got, "methods:\n            - GET\n")
+		assert.Contains(t, got, "jwt_expiry_in: 3h0m0s")
+		assert.Contains(t, got, "X-Env-Key:\n        - ${API_KEY}\n")
+		assert.Contains(t, got, "X-File-Key:\n        - file:/run/secrets/api-key\n")
+		assert.Contains(t, got, "X-Api-Key:\n        - REDACTED\n")
+		assert.NotContains(t, got, "literal-api-key")
+		assert.Contains(t, got, "Authorization:\n        - REDACTED\n", "references mixed with literals redacted")
+		assert.NotContains(t, got, "sk-live-xyz")
+		assert.Contains(t, got, "client_secret_hash: REDACTED")
+		assert.Contains(t, got, "client_secret: REDACTED")
+		assert.NotContains(t, got, "2bb80d537b1da3e38bd30361aa855686bde0eacd7162fef6a25fe97bf527a25b")
+		assert.NotContains(t, got, "client_secret: secret")
+	})
+
+	t.Run("schema may print JSON Schema", func(t *testing.T) {
+		t.Parallel()
+		stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+
+		assert.Equal(t, 0, Run([]string{"schema"}, stdout, stderr))
+		var schema map[string]any
+		assert.NoError(t, json.Unmarshal(stdout.Bytes(), &schema))
+		assert.Equal(t, "https://json-schema.org/draft/2020-12/schema", schema["$schema"])
+	})
+
+	t.Run("may reject unknown command", func(t *testing.T) {
+		t.Parallel()
+		assert.Equal(t, 2, Run([]string{"unknown"}, &bytes.Buffer{}, &bytes.Buffer{}))
+	})
+}
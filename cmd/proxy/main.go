@@ -5,10 +5,16 @@ import (
 	"os"
 
 	"github.com/tingtt/oauth2rbac/cmd/proxy/clioption"
+	"github.com/tingtt/oauth2rbac/cmd/proxy/configcmd"
 	"github.com/tingtt/oauth2rbac/cmd/proxy/server"
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "config" {
+		os.Exit(configcmd.Run(os.Args[2:], os.Stdout, os.Stderr))
+		return
+	}
+
 	if err := run(); err != nil {
 		slog.Error(err.Error())
 		os.Exit(1)
@@ -0,0 +1,20 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/tingtt/oauth2rbac/internal/metrics"
+)
+
+// newAdminServer returns the server of the admin listener, separated from the proxies.
+// readyz is the readiness check with the results of the checks, not exposed on the proxies.
+func newAdminServer(port uint16, readyz http.Handler) *http.Server {
+	mux := http.NewServeMux()
+	mux.Handle("GET /metrics", metrics.Handler())
+	mux.Handle("GET /readyz", readyz)
+	return &http.Server{
+		Addr:    fmt.Sprintf(":%d", port),
+		Handler: mux,
+	}
+}
@@ -0,0 +1,22 @@
+package server
+
+import (
+	"net"
+	"net/http"
+	"sync/atomic"
+)
+
+// connCounter counts the open connections of http.Server. Upgraded connections are counted as in-flight requests instead.
+type connCounter struct {
+	open atomic.Int64
+}
+
+// track implements http.Server.ConnState.
+func (c *connCounter) track(_ net.Conn, state http.ConnState) {
+	switch state {
+	case http.StateNew:
+		c.open.Add(1)
+	case http.StateHijacked, http.StateClosed:
+		c.open.Add(-1)
+	}
+}
@@ -1,43 +1,184 @@
 package server
 
 import (
+	"context"
 	"crypto/tls"
+	"errors"
 	"fmt"
 	"log/slog"
 	"net/http"
+	"os"
+	"os/signal"
+	"slices"
+	"syscall"
+	"time"
 
 	"github.com/tingtt/oauth2rbac/cmd/proxy/clioption"
+	"github.com/tingtt/oauth2rbac/internal/acl"
 	"github.com/tingtt/oauth2rbac/internal/api/handler"
+	reverseproxy "github.com/tingtt/oauth2rbac/internal/api/handler/reverse_proxy"
 	handleroption "github.com/tingtt/oauth2rbac/internal/api/handler/util/option"
+	"github.com/tingtt/oauth2rbac/internal/audit"
+	"github.com/tingtt/oauth2rbac/internal/health"
+	"github.com/tingtt/oauth2rbac/internal/ingress"
+	"github.com/tingtt/oauth2rbac/internal/tracing"
 )
 
 func Serve(cliOption clioption.CLIOption) error {
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, os.Interrupt)
+	defer stop()
+
+	if /* tracing enabled */ cliOption.Tracing {
+		shutdownTracing, err := tracing.Setup(ctx, tracing.Option{RecordUserEmail: cliOption.TracingUserEmail})
+		if err != nil {
+			return err
+		}
+		defer func() {
+			// flush the spans of the requests drained
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			if err := shutdownTracing(ctx); err != nil {
+				slog.Error(fmt.Errorf("failed to flush spans: %w", err).Error())
+			}
+		}()
+	}
+
+	defer func() {
+		// flush the events of the requests drained, dropping the rest after the timeout (e.g. webhook unreachable)
+		ctx, cancel := context.WithTimeout(context.Background(), cliOption.ShutdownTimeout)
+		defer cancel()
+		if err := cliOption.AuditLogger.Close(ctx); err != nil {
+			slog.Error(fmt.Errorf("failed to close audit log: %w", err).Error())
+		}
+	}()
+	cliOption.AuditLogger.Log(configEvent(audit.TypeConfigLoaded, "manifest", cliOption.RevProxyConfig, cliOption.ACL))
+
+	aclProvider := acl.NewSwappableProvider(cliOption.ACL)
 	handler, err := handler.New(cliOption.OAuth2, cliOption.RevProxyConfig,
 		handleroption.WithJWTAuth(cliOption.JWTSignKey),
 		handleroption.WithSecureCookie(cliOption.UseSecureCookie),
-		handleroption.WithACL(cliOption.ACL),
+		handleroption.WithACLProvider(aclProvider),
+		handleroption.WithErrorPages(cliOption.ErrorPages),
+		handleroption.WithAPITokenStore(cliOption.APITokenStore),
+		handleroption.WithServiceAccounts(cliOption.ServiceAccounts),
+		handleroption.WithSessionStore(cliOption.SessionStore),
+		handleroption.WithAdmins(cliOption.Admins),
+		handleroption.WithIdentityRecheckInterval(cliOption.IdentityRecheckInterval),
+		handleroption.WithReadinessChecks(health.All{"config": configCheck(cliOption.IngressController)}),
+		handleroption.WithUpstreamHealthCheck(cliOption.CheckUpstreams),
+		handleroption.WithAuditLogger(cliOption.AuditLogger),
 	)
 	if err != nil {
 		return err
 	}
 
+	if /* Kubernetes Ingresses enabled */ cliOption.IngressController != nil {
+		go cliOption.IngressController.Run(ctx, func(config ingress.Config) {
+			// ACL first, so that new proxies are never served without their ACL.
+			aclProvider.Swap(config.ACL)
+			handler.UpdateReverseProxyConfig(config.ReverseProxy)
+			cliOption.AuditLogger.Log(configEvent(audit.TypeConfigReloaded, "ingress", config.ReverseProxy, config.ACL))
+		})
+	}
+
+	conns := &connCounter{}
 	server := &http.Server{
-		Addr:    fmt.Sprintf(":%d", cliOption.Port),
-		Handler: handler,
+		Addr:      fmt.Sprintf(":%d", cliOption.Port),
+		Handler:   handler,
+		ConnState: conns.track,
 	}
 
-	if /* TLS cert/key specified */ len(cliOption.X509KeyPairs) != 0 {
-		server.TLSConfig = &tls.Config{Certificates: cliOption.X509KeyPairs, MinVersion: tls.VersionTLS13}
-		slog.Info(fmt.Sprintf("Starting HTTPS Server. Listening at %s.", server.Addr))
-		err = server.ListenAndServeTLS("", "")
-	} else {
-		slog.Info(fmt.Sprintf("Starting HTTP Server. Listening at %s.", server.Addr))
-		err = server.ListenAndServe()
+	serveErr := make(chan error, 2)
+	var adminServer *http.Server
+	if /* admin listener enabled */ cliOption.AdminPort != 0 {
+		adminServer = newAdminServer(cliOption.AdminPort, handler.ReadinessDetails())
+		go func() {
+			slog.Info(fmt.Sprintf("Starting admin HTTP Server. Listening at %s.", adminServer.Addr))
+			if err := adminServer.ListenAndServe(); err != http.ErrServerClosed {
+				serveErr <- err
+			}
+		}()
 	}
-	if err != nil && err != http.ErrServerClosed {
+	go func() {
+		if /* TLS cert/key specified */ len(cliOption.X509KeyPairs) != 0 {
+			server.TLSConfig = &tls.Config{Certificates: cliOption.X509KeyPairs, MinVersion: tls.VersionTLS13}
+			slog.Info(fmt.Sprintf("Starting HTTPS Server. Listening at %s.", server.Addr))
+			serveErr <- server.ListenAndServeTLS("", "")
+		} else {
+			slog.Info(fmt.Sprintf("Starting HTTP Server. Listening at %s.", server.Addr))
+			serveErr <- server.ListenAndServe()
+		}
+	}()
+
+	select {
+	case err := <-serveErr:
 		return err
+	case <-ctx.Done():
 	}
+	// the second signal terminates immediately
+	stop()
 
+	err = shutdown(server, handler, conns, cliOption.ShutdownDelay, cliOption.ShutdownTimeout)
+	if adminServer != nil {
+		// closed after draining, so that metrics are scraped while draining
+		adminServer.Close()
+	}
 	slog.Info("Server closed.")
-	return nil
+	return err
+}
+
+// shutdown fails the health check, waits for the delay, and drains the connections until the timeout.
+// The connections left after the timeout are closed.
+func shutdown(server *http.Server, handler *handler.Handler, conns *connCounter, delay, timeout time.Duration) error {
+	slog.Info("Shutting down server.",
+		slog.Int64("open_connections", conns.open.Load()),
+		slog.Int64("in_flight_requests", handler.InFlightRequests()),
+		slog.Duration("delay", delay),
+		slog.Duration("timeout", timeout),
+	)
+	handler.StartShutdown()
+	time.Sleep(delay)
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	err := server.Shutdown(ctx)
+	if err == nil {
+		// upgraded connections are not waited by http.Server.Shutdown
+		err = handler.WaitRequests(ctx)
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		slog.Warn("Shutdown timed out. Closing connections.",
+			slog.Int64("open_connections", conns.open.Load()),
+			slog.Int64("in_flight_requests", handler.InFlightRequests()),
+		)
+		return server.Close()
+	}
+	return err
+}
+
+// configCheck fails until the config is loaded. The manifest is loaded before serving, and Ingresses are loaded after. (if enabled)
+func configCheck(ingressController *ingress.Controller) health.CheckFunc {
+	return func(context.Context) error {
+		if ingressController != nil && !ingressController.Synced() {
+			return errors.New("ingresses are not synced yet")
+		}
+		return nil
+	}
+}
+
+// configEvent is the audit event of the config loaded, with the external URLs of the proxies and the origins of the ACL.
+func configEvent(eventType audit.Type, source string, revProxyConfig reverseproxy.Config, pool acl.Pool) audit.Event {
+	externalURLs := make([]string, 0, len(revProxyConfig.Proxies))
+	for _, proxy := range revProxyConfig.Proxies {
+		externalURLs = append(externalURLs, proxy.ExternalURL)
+	}
+	origins := make([]string, 0, len(pool))
+	for origin := range pool {
+		origins = append(origins, origin)
+	}
+	slices.Sort(origins)
+	return audit.Event{
+		Type:    eventType,
+		Details: map[string]any{"source": source, "proxies": externalURLs, "origins": origins},
+	}
 }
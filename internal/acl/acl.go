@@ -6,13 +6,17 @@ import (
 	"regexp"
 	"slices"
 	"sort"
+	"strconv"
 	"strings"
 	"time"
+
+	"github.com/tingtt/oauth2rbac/internal/util/jsonschema"
 )
 
 type Pool map[ /* origin */ string]ScopeOrigin
 
-func (p Pool) sanitized() Pool {
+// Sanitized returns the pool as enforced, with trailing slashes of origins removed and methods normalized.
+func (p Pool) Sanitized() Pool {
 	sanitized := Pool{}
 	for origin, scope := range p {
 		sanitizedOrigin, _ := strings.CutSuffix(origin, "/")
@@ -51,9 +55,49 @@ type Path = string
 type Method = string
 
 // EmailRegex is a regex pattern for email addresses without the ^ and $ anchors.
+// Service accounts are matched only by patterns with ServiceAccountPrefix. (e.g. "serviceaccount:*")
 type EmailRegex string
 
+// ServiceAccountPrefix is the prefix of service account identities.
+const ServiceAccountPrefix = "serviceaccount:"
+
+// GitHubGroupPrefix is the prefix of GitHub organization and team groups. (e.g. "github:acme", "github:acme/sre")
+const GitHubGroupPrefix = "github:"
+
+// GoogleGroupPrefix is the prefix of Google Workspace domains and Google Groups. (e.g. "google:example.com", "google:sre@example.com")
+const GoogleGroupPrefix = "google:"
+
+// EntraGroupPrefix is the prefix of Microsoft Entra ID groups by object ID. (e.g. "entra:00000000-0000-0000-0000-000000000000")
+const EntraGroupPrefix = "entra:"
+
+// isGroup reports whether the pattern is a group of OAuth2 providers (e.g. "github:acme/sre").
+func (eg EmailRegex) isGroup() bool {
+	return strings.HasPrefix(string(eg), GitHubGroupPrefix) ||
+		strings.HasPrefix(string(eg), GoogleGroupPrefix) ||
+		strings.HasPrefix(string(eg), EntraGroupPrefix)
+}
+
+// MatchIdentity reports whether the pattern matches the email, or one of the groups if the pattern is a group.
+// Groups are compared case-insensitively, without wildcards.
+func (eg EmailRegex) MatchIdentity(email string, groups []string) bool {
+	if eg.isGroup() {
+		return slices.ContainsFunc(groups, func(group string) bool {
+			return strings.EqualFold(group, string(eg))
+		})
+	}
+	return string(eg) == email || eg.Match(email)
+}
+
 func (eg EmailRegex) Match(email string) bool {
+	clientID, isServiceAccount := strings.CutPrefix(email, ServiceAccountPrefix)
+	clientIDRegex, isServiceAccountRegex := strings.CutPrefix(string(eg), ServiceAccountPrefix)
+	if isServiceAccount != isServiceAccountRegex {
+		return false
+	}
+	if isServiceAccount {
+		eg, email = EmailRegex(clientIDRegex), clientID
+	}
+
 	// Convert the email pattern to a regex pattern
 	regexPattern := fmt.Sprintf("^%s$", eg)
 	regexPattern = regexp.MustCompile(`\.`).ReplaceAllString(regexPattern, `\.`)
@@ -71,27 +115,146 @@ type ScopeOrigin struct {
 
 type OriginConfig struct {
 	JWTExpiryIn *JWTExpiryIn `yaml:"jwt_expiry_in"`
+	// SessionCookie makes the JWT cookie a session cookie, removed when the browser is closed.
+	// Otherwise, the cookie expires with the JWT.
+	SessionCookie bool `yaml:"session_cookie"`
+	// ErrorPages is the HTML template file paths of custom error pages by status code.
+	ErrorPages ErrorPages `yaml:"error_pages"`
+	// APIPaths is the path patterns responding 401 instead of redirecting to login page.
+	APIPaths []PathPattern `yaml:"api_paths"`
+	// SkipRedirectAfterLoginPaths is the path patterns not remembered as the redirect destination after login.
+	// If not specified, DefaultSkipRedirectAfterLoginPaths is used.
+	SkipRedirectAfterLoginPaths []PathPattern `yaml:"skip_redirect_after_login_paths"`
+	// CORSAllowedOrigins is the origins allowed to call `/.auth/userinfo` with credentials.
+	CORSAllowedOrigins []string `yaml:"cors_allowed_origins"`
+	// MaxSessionAge is the absolute lifetime of sessions since sign-in, regardless of JWT renewal.
+	MaxSessionAge *Duration `yaml:"max_session_age"`
+	// StepUpPaths is the paths requiring a recent sign-in.
+	StepUpPaths []StepUpPath `yaml:"step_up_paths"`
+}
+
+// StepUpPath requires users to sign in again if they signed in more than MaxAuthAge ago.
+type StepUpPath struct {
+	Path       PathPattern `yaml:"path"`
+	MaxAuthAge Duration    `yaml:"max_auth_age"`
+}
+
+var DefaultSkipRedirectAfterLoginPaths = []PathPattern{
+	"/favicon.ico",
+	"/api/",
+	"/.well-known/",
+	"/_next/",
+	"*.svg",
+}
+
+func (c *OriginConfig) IsAPIPath(path string) bool {
+	if c == nil {
+		return false
+	}
+	return matchPathPatterns(path, c.APIPaths)
+}
+
+func (c *OriginConfig) SkipRedirectAfterLogin(path string) bool {
+	if c == nil || c.SkipRedirectAfterLoginPaths == nil {
+		return matchPathPatterns(path, DefaultSkipRedirectAfterLoginPaths)
+	}
+	return matchPathPatterns(path, c.SkipRedirectAfterLoginPaths)
+}
+
+// MaxAuthAge returns the maximum elapsed time since sign-in allowed for the path.
+// It is the shortest of MaxSessionAge and MaxAuthAge of matched StepUpPaths.
+func (c *OriginConfig) MaxAuthAge(path string) (time.Duration, bool) {
+	if c == nil {
+		return 0, false
+	}
+	var maxAuthAge *time.Duration
+	if c.MaxSessionAge != nil {
+		maxAuthAge = (*time.Duration)(c.MaxSessionAge)
+	}
+	for _, stepUp := range c.StepUpPaths {
+		if stepUp.Path.Match(path) && (maxAuthAge == nil || time.Duration(stepUp.MaxAuthAge) < *maxAuthAge) {
+			maxAuthAge = (*time.Duration)(&stepUp.MaxAuthAge)
+		}
+	}
+	if maxAuthAge == nil {
+		return 0, false
+	}
+	return *maxAuthAge, true
+}
+
+// PathPattern is a path prefix (e.g. "/api/"), or a path suffix with a leading "*" (e.g. "*.svg").
+type PathPattern string
+
+func (pp PathPattern) Match(path string) bool {
+	if suffix, isSuffix := strings.CutPrefix(string(pp), "*"); isSuffix {
+		return strings.HasSuffix(path, suffix)
+	}
+	return strings.HasPrefix(path, string(pp))
+}
+
+func matchPathPatterns(path string, patterns []PathPattern) bool {
+	for _, pattern := range patterns {
+		if pattern.Match(path) {
+			return true
+		}
+	}
+	return false
 }
 
 type JWTExpiryIn time.Duration
 
 func (d *JWTExpiryIn) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	return (*Duration)(d).UnmarshalYAML(unmarshal)
+}
+
+func (d JWTExpiryIn) MarshalYAML() (interface{}, error) {
+	return Duration(d).MarshalYAML()
+}
+
+func (d JWTExpiryIn) JSONSchema() *jsonschema.Schema {
+	return Duration(d).JSONSchema()
+}
+
+// ErrorPages is the file paths by status code.
+// The status codes may be strings, as the keys of JSON and TOML. (e.g. `"404"`)
+type ErrorPages map[int]string
+
+func (p *ErrorPages) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var pages map[string]string
+	if err := unmarshal(&pages); err != nil || pages == nil {
+		return err
+	}
+	*p = make(ErrorPages, len(pages))
+	for key, filePath := range pages {
+		statusCode, err := strconv.Atoi(key)
+		if err != nil {
+			return fmt.Errorf("invalid status code `%s`", key)
+		}
+		(*p)[statusCode] = filePath
+	}
+	return nil
+}
+
+// Duration is a duration string (e.g. "12h") or seconds in number.
+type Duration time.Duration
+
+func (d *Duration) UnmarshalYAML(unmarshal func(interface{}) error) error {
 	var v any
 	if err := unmarshal(&v); err != nil {
 		return err
 	}
 	switch value := v.(type) {
 	case int:
-		*d = JWTExpiryIn(time.Duration(value) * time.Second)
+		*d = Duration(time.Duration(value) * time.Second)
 		return nil
 	case int64:
-		*d = JWTExpiryIn(time.Duration(value) * time.Second)
+		*d = Duration(time.Duration(value) * time.Second)
 		return nil
 	case float32:
-		*d = JWTExpiryIn(time.Duration(value * float32(time.Second)))
+		*d = Duration(time.Duration(value * float32(time.Second)))
 		return nil
 	case float64:
-		*d = JWTExpiryIn(time.Duration(value * float64(time.Second)))
+		*d = Duration(time.Duration(value * float64(time.Second)))
 		return nil
 	case string:
 		var err error
@@ -99,13 +262,26 @@ func (d *JWTExpiryIn) UnmarshalYAML(unmarshal func(interface{}) error) error {
 		if err != nil {
 			return err
 		}
-		*d = JWTExpiryIn(parsed)
+		*d = Duration(parsed)
 		return nil
 	default:
 		return errors.New("invalid duration")
 	}
 }
 
+// MarshalYAML returns the duration string. (e.g. "12h0m0s")
+func (d Duration) MarshalYAML() (interface{}, error) {
+	return time.Duration(d).String(), nil
+}
+
+// JSONSchema returns the schema of the duration string or seconds in number.
+func (Duration) JSONSchema() *jsonschema.Schema {
+	return &jsonschema.Schema{OneOf: []*jsonschema.Schema{
+		{Type: "string", Pattern: `^([0-9]+(\.[0-9]+)?(ns|us|µs|ms|s|m|h))+$`},
+		{Type: "number"},
+	}}
+}
+
 type ScopePath struct {
 	EmailRegexes []EmailRegex `yaml:"emails"`
 	Methods      []Method     `yaml:"methods"`
@@ -121,6 +297,28 @@ func (as AllowedScopes) Match(path, method string) bool {
 	return slices.Contains(*methods, "*") || slices.Contains(*methods, method)
 }
 
+// MatchedPath returns the longest path of the scopes matching the path, regardless of the methods.
+func (as AllowedScopes) MatchedPath(path string) (Path, bool) {
+	for _, p := range sortPathsByLengthDesc(as) {
+		if strings.HasPrefix(path, p) {
+			return p, true
+		}
+	}
+	return "", false
+}
+
+// Covers reports whether all of the other scopes are allowed in the scopes.
+func (as AllowedScopes) Covers(other AllowedScopes) bool {
+	for path, methods := range other {
+		for _, method := range methods {
+			if !as.Match(path, method) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
 func sortPathsByLengthDesc[S any](m map[Path]S) []Path {
 	keys := make([]Path, 0, len(m))
 	for k := range m {
@@ -161,13 +359,13 @@ func (scope ScopeOrigin) LoginRequired(path, method string) bool {
 	return !anonymousAllowed
 }
 
-func (scope ScopeOrigin) AllowedScopes(email string) AllowedScopes {
+func (scope ScopeOrigin) AllowedScopes(email string, groups ...string) AllowedScopes {
 	allowedScopes := AllowedScopes{}
 	for path, scopes := range scope.PathScopes {
 		allowedScopes[path] = []Method{}
 		for _, s := range scopes {
 			for _, emailRegex := range s.EmailRegexes {
-				if string(emailRegex) == "-" || string(emailRegex) == email || emailRegex.Match(email) {
+				if string(emailRegex) == "-" || emailRegex.MatchIdentity(email, groups) {
 					allowedScopes[path] = slices.Compact(append(allowedScopes[path], s.Methods...))
 				}
 			}
@@ -176,11 +374,11 @@ func (scope ScopeOrigin) AllowedScopes(email string) AllowedScopes {
 	return allowedScopes
 }
 
-func (scope ScopeOrigin) AllowedRoles(email string) []string {
+func (scope ScopeOrigin) AllowedRoles(email string, groups ...string) []string {
 	roles := []string{}
 	for role, emailRegexes := range scope.Roles {
 		for _, emailRegex := range emailRegexes {
-			if string(role) == "-" || string(role) == email || emailRegex.Match(email) {
+			if string(role) == "-" || string(role) == email || emailRegex.MatchIdentity(email, groups) {
 				roles = append(roles, role)
 			}
 		}
@@ -3,6 +3,7 @@ package acl
 import (
 	"reflect"
 	"testing"
+	"time"
 )
 
 func TestScopeOrigin_LoginRequired(t *testing.T) {
@@ -182,3 +183,204 @@ func TestScopeOrigin_AllowedRoles(t *testing.T) {
 		})
 	}
 }
+
+func TestOriginConfig_IsAPIPath(t *testing.T) {
+	tests := []struct {
+		name   string
+		config *OriginConfig
+		path   string
+		want   bool
+	}{
+		{"nil config", nil, "/api/users", false},
+		{"prefix match", &OriginConfig{APIPaths: []PathPattern{"/api/"}}, "/api/users", true},
+		{"prefix unmatch", &OriginConfig{APIPaths: []PathPattern{"/api/"}}, "/apis", false},
+		{"suffix match", &OriginConfig{APIPaths: []PathPattern{"*.json"}}, "/data/users.json", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.config.IsAPIPath(tt.path); got != tt.want {
+				t.Errorf("OriginConfig.IsAPIPath() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOriginConfig_SkipRedirectAfterLogin(t *testing.T) {
+	tests := []struct {
+		name   string
+		config *OriginConfig
+		path   string
+		want   bool
+	}{
+		{"nil config uses defaults", nil, "/logo.svg", true},
+		{"unset uses defaults", &OriginConfig{}, "/_next/static/chunk.js", true},
+		{"defaults unmatch", &OriginConfig{}, "/dashboard", false},
+		{"configured", &OriginConfig{SkipRedirectAfterLoginPaths: []PathPattern{"/assets/"}}, "/assets/app.js", true},
+		{"configured overrides defaults", &OriginConfig{SkipRedirectAfterLoginPaths: []PathPattern{}}, "/favicon.ico", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.config.SkipRedirectAfterLogin(tt.path); got != tt.want {
+				t.Errorf("OriginConfig.SkipRedirectAfterLogin() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOriginConfig_MaxAuthAge(t *testing.T) {
+	week, tenMinutes := Duration(7*24*time.Hour), Duration(10*time.Minute)
+	stepUp := []StepUpPath{{Path: "/admin/", MaxAuthAge: tenMinutes}}
+	tests := []struct {
+		name   string
+		config *OriginConfig
+		path   string
+		want   time.Duration
+		wantOk bool
+	}{
+		{"nil config", nil, "/", 0, false},
+		{"unset", &OriginConfig{}, "/", 0, false},
+		{"max session age", &OriginConfig{MaxSessionAge: &week}, "/", 7 * 24 * time.Hour, true},
+		{"step-up path", &OriginConfig{StepUpPaths: stepUp}, "/admin/users", 10 * time.Minute, true},
+		{"step-up path unmatch", &OriginConfig{StepUpPaths: stepUp}, "/users", 0, false},
+		{"shorter step-up path", &OriginConfig{MaxSessionAge: &week, StepUpPaths: stepUp}, "/admin/users", 10 * time.Minute, true},
+		{"shorter max session age", &OriginConfig{MaxSessionAge: &tenMinutes, StepUpPaths: []StepUpPath{{Path: "/admin/", MaxAuthAge: week}}}, "/admin/users", 10 * time.Minute, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := tt.config.MaxAuthAge(tt.path)
+			if got != tt.want || ok != tt.wantOk {
+				t.Errorf("OriginConfig.MaxAuthAge() = %v, %v, want %v, %v", got, ok, tt.want, tt.wantOk)
+			}
+		})
+	}
+}
+
+func TestAllowedScopes_Covers(t *testing.T) {
+	allowed := AllowedScopes{
+		"/":       {"GET"},
+		"/api/":   {"*"},
+		"/admin/": {},
+	}
+	tests := []struct {
+		name  string
+		other AllowedScopes
+		want  bool
+	}{
+		{"same scopes", AllowedScopes{"/": {"GET"}}, true},
+		{"narrower path", AllowedScopes{"/api/users/": {"POST", "DELETE"}}, true},
+		{"wider methods", AllowedScopes{"/": {"POST"}}, false},
+		{"wildcard methods", AllowedScopes{"/": {"*"}}, false},
+		{"not allowed path", AllowedScopes{"/admin/": {"GET"}}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := allowed.Covers(tt.other); got != tt.want {
+				t.Errorf("AllowedScopes.Covers() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAllowedScopes_MatchedPath(t *testing.T) {
+	allowed := AllowedScopes{
+		"/":       {"GET"},
+		"/admin/": {},
+	}
+	tests := []struct {
+		path   string
+		want   Path
+		wantOK bool
+	}{
+		{"/index.html", "/", true},
+		{"/admin/users", "/admin/", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			got, ok := allowed.MatchedPath(tt.path)
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("AllowedScopes.MatchedPath() = %v, %v, want %v, %v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+	if _, ok := (AllowedScopes{"/api/": {"GET"}}).MatchedPath("/"); ok {
+		t.Errorf("AllowedScopes.MatchedPath() matched a path out of the scopes")
+	}
+}
+
+func TestEmailRegex_Match_serviceAccount(t *testing.T) {
+	tests := []struct {
+		pattern EmailRegex
+		subject string
+		want    bool
+	}{
+		{"*", "serviceaccount:ci", false},
+		{"*@example.com", "serviceaccount:ci@example.com", false},
+		{"serviceaccount:ci", "serviceaccount:ci", true},
+		{"serviceaccount:*", "serviceaccount:ci", true},
+		{"serviceaccount:*", "user@example.com", false},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.pattern)+" "+tt.subject, func(t *testing.T) {
+			if got := tt.pattern.Match(tt.subject); got != tt.want {
+				t.Errorf("EmailRegex.Match() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScopeOrigin_AllowedScopes_groups(t *testing.T) {
+	scope := ScopeOrigin{
+		PathScopes: map[Path][]ScopePath{
+			"/":      {{EmailRegexes: []EmailRegex{"github:acme"}, Methods: []Method{"GET"}}},
+			"/admin": {{EmailRegexes: []EmailRegex{"github:acme/sre"}, Methods: []Method{"*"}}},
+		},
+		Roles: map[string][]EmailRegex{"sre": {"github:acme/sre"}},
+	}
+	tests := []struct {
+		name      string
+		groups    []string
+		want      AllowedScopes
+		wantRoles []string
+	}{
+		{"no groups", nil, AllowedScopes{"/": {}, "/admin": {}}, []string{}},
+		{"org member", []string{"github:acme"}, AllowedScopes{"/": {"GET"}, "/admin": {}}, []string{}},
+		{"team member", []string{"github:acme", "github:Acme/SRE"}, AllowedScopes{"/": {"GET"}, "/admin": {"*"}}, []string{"sre"}},
+		{"other org", []string{"github:other/sre"}, AllowedScopes{"/": {}, "/admin": {}}, []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := scope.AllowedScopes("user@example.test", tt.groups...); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ScopeOrigin.AllowedScopes() = %v, want %v", got, tt.want)
+			}
+			if got := scope.AllowedRoles("user@example.test", tt.groups...); !reflect.DeepEqual(got, tt.wantRoles) {
+				t.Errorf("ScopeOrigin.AllowedRoles() = %v, want %v", got, tt.wantRoles)
+			}
+		})
+	}
+}
+
+func TestEmailRegex_MatchIdentity(t *testing.T) {
+	tests := []struct {
+		name    string
+		pattern EmailRegex
+		email   string
+		groups  []string
+		want    bool
+	}{
+		{"email", "user@example.test", "user@example.test", nil, true},
+		{"email pattern", "*@example.test", "user@example.test", []string{"github:acme"}, true},
+		{"group", "github:acme", "user@example.test", []string{"github:acme"}, true},
+		{"group not match email", "github:acme", "github:acme", nil, false},
+		{"google workspace domain", "google:example.test", "user@example.test", []string{"google:example.test"}, true},
+		{"google group", "google:sre@example.test", "user@example.test", []string{"google:example.test", "google:sre@example.test"}, true},
+		{"google group not match email", "google:sre@example.test", "sre@example.test", nil, false},
+		{"group without wildcard", "github:acme/*", "user@example.test", []string{"github:acme/sre"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.pattern.MatchIdentity(tt.email, tt.groups); got != tt.want {
+				t.Errorf("EmailRegex.MatchIdentity() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
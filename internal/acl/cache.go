@@ -160,5 +160,5 @@ func (cache *cache) matchRoles(origin, email string) ([]string, bool) {
 			return roles, true
 		}
 	}
-	return nil, true
+	return nil, false
 }
@@ -2,21 +2,27 @@ package acl
 
 import (
 	"net/url"
+	"slices"
+	"sync/atomic"
+
+	"github.com/tingtt/oauth2rbac/internal/metrics"
 )
 
 // Provider is an interface that provides the allowed scopes for a given email and URL.
 // Is also caches the allowed scopes for a given URL and email.
 type Provider interface {
 	LoginRequired(url *url.URL, method string) bool
-	AllowedScopes(url *url.URL, email string) AllowedScopes
-	Roles(url *url.URL, email string) []string
+	// AllowedScopes returns the allowed scopes for the email, and the groups of OAuth2 providers. (e.g. "github:acme/sre")
+	AllowedScopes(url *url.URL, email string, groups ...string) AllowedScopes
+	Roles(url *url.URL, email string, groups ...string) []string
 	OriginConfig(url *url.URL) *OriginConfig
+	Origins() []string
 
 	originFromURL(url *url.URL) string
 }
 
 func NewProvider(pool Pool) Provider {
-	pool = pool.sanitized()
+	pool = pool.Sanitized()
 	cache := cache{}
 	cache.initialize(pool)
 	return &provider{pool, cache}
@@ -32,20 +38,26 @@ func (p *provider) originFromURL(url *url.URL) string {
 }
 
 // AllowedScopes implements Provider.
-func (p *provider) AllowedScopes(url *url.URL, email string) AllowedScopes {
+func (p *provider) AllowedScopes(url *url.URL, email string, groups ...string) AllowedScopes {
 	origin := p.originFromURL(url)
 
-	if allowedScopes, hit := p.cache.matchAllowedScopes(origin, email); hit {
-		return allowedScopes
+	if /* groups are not cached */ len(groups) == 0 {
+		allowedScopes, hit := p.cache.matchAllowedScopes(origin, email)
+		metrics.ObserveACLCache("allowed_scopes", hit)
+		if hit {
+			return allowedScopes
+		}
 	}
 
 	scope := p.pool.MatchOrigin(origin)
 	if scope == nil {
 		return nil
 	}
-	allowedScopes := scope.AllowedScopes(email)
+	allowedScopes := scope.AllowedScopes(email, groups...)
 
-	p.cache.cacheAllowedScopes(origin, email, allowedScopes)
+	if len(groups) == 0 {
+		p.cache.cacheAllowedScopes(origin, email, allowedScopes)
+	}
 	return allowedScopes
 }
 
@@ -53,7 +65,9 @@ func (p *provider) AllowedScopes(url *url.URL, email string) AllowedScopes {
 func (p *provider) LoginRequired(url *url.URL, method string) bool {
 	origin := p.originFromURL(url)
 
-	if loginRequired, hit := p.cache.matchLoginRequired(origin, url.Path, method); hit {
+	loginRequired, hit := p.cache.matchLoginRequired(origin, url.Path, method)
+	metrics.ObserveACLCache("login_required", hit)
+	if hit {
 		return loginRequired
 	}
 
@@ -65,20 +79,26 @@ func (p *provider) LoginRequired(url *url.URL, method string) bool {
 }
 
 // Roles implements Provider.
-func (p *provider) Roles(url *url.URL, email string) []string {
+func (p *provider) Roles(url *url.URL, email string, groups ...string) []string {
 	origin := p.originFromURL(url)
 
-	if roles, hit := p.cache.matchRoles(origin, email); hit {
-		return roles
+	if /* groups are not cached */ len(groups) == 0 {
+		roles, hit := p.cache.matchRoles(origin, email)
+		metrics.ObserveACLCache("roles", hit)
+		if hit {
+			return roles
+		}
 	}
 
 	scope := p.pool.MatchOrigin(origin)
 	if scope == nil {
 		return nil
 	}
-	roles := scope.AllowedRoles(email)
+	roles := scope.AllowedRoles(email, groups...)
 
-	p.cache.cacheRoles(origin, email, roles)
+	if len(groups) == 0 {
+		p.cache.cacheRoles(origin, email, roles)
+	}
 	return roles
 }
 
@@ -91,3 +111,58 @@ func (p *provider) OriginConfig(url *url.URL) *OriginConfig {
 	}
 	return &scope.OriginConfig
 }
+
+// Origins implements Provider.
+func (p *provider) Origins() []string {
+	origins := make([]string, 0, len(p.pool))
+	for origin := range p.pool {
+		origins = append(origins, origin)
+	}
+	slices.Sort(origins)
+	return origins
+}
+
+// SwappableProvider is a Provider of which the pool can be replaced while serving requests. (e.g. Kubernetes Ingresses changed)
+type SwappableProvider struct {
+	current atomic.Pointer[provider]
+}
+
+func NewSwappableProvider(pool Pool) *SwappableProvider {
+	p := &SwappableProvider{}
+	p.Swap(pool)
+	return p
+}
+
+// Swap replaces the pool. The caches of the previous pool are dropped.
+func (p *SwappableProvider) Swap(pool Pool) {
+	p.current.Store(NewProvider(pool).(*provider))
+}
+
+// AllowedScopes implements Provider.
+func (p *SwappableProvider) AllowedScopes(url *url.URL, email string, groups ...string) AllowedScopes {
+	return p.current.Load().AllowedScopes(url, email, groups...)
+}
+
+// LoginRequired implements Provider.
+func (p *SwappableProvider) LoginRequired(url *url.URL, method string) bool {
+	return p.current.Load().LoginRequired(url, method)
+}
+
+// Roles implements Provider.
+func (p *SwappableProvider) Roles(url *url.URL, email string, groups ...string) []string {
+	return p.current.Load().Roles(url, email, groups...)
+}
+
+// OriginConfig implements Provider.
+func (p *SwappableProvider) OriginConfig(url *url.URL) *OriginConfig {
+	return p.current.Load().OriginConfig(url)
+}
+
+// Origins implements Provider.
+func (p *SwappableProvider) Origins() []string {
+	return p.current.Load().Origins()
+}
+
+func (p *SwappableProvider) originFromURL(url *url.URL) string {
+	return p.current.Load().originFromURL(url)
+}
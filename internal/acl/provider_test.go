@@ -0,0 +1,42 @@
+package acl
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProvider_Roles(t *testing.T) {
+	t.Parallel()
+
+	provider := NewProvider(Pool{
+		"https://example.com": {
+			PathScopes: map[Path][]ScopePath{
+				"/": {{EmailRegexes: []EmailRegex{"*"}, Methods: []Method{"GET"}}},
+			},
+			Roles: map[string][]EmailRegex{
+				"admin":  {"admin@example.com"},
+				"editor": {"*@example.com"},
+			},
+		},
+	})
+	originURL, _ := url.Parse("https://example.com/")
+
+	tests := []struct {
+		name  string
+		email string
+		want  []string
+	}{
+		{"email declared may have roles", "admin@example.com", []string{"admin", "editor"}},
+		// not declared as is, so that the cache misses
+		{"email matched by pattern may have roles", "user@example.com", []string{"editor"}},
+		{"email not matched may have no roles", "user@example.org", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			assert.ElementsMatch(t, tt.want, provider.Roles(originURL, tt.email))
+		})
+	}
+}
@@ -0,0 +1,92 @@
+package adminhandler
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+
+	"github.com/tingtt/oauth2rbac/internal/acl"
+	"github.com/tingtt/oauth2rbac/internal/api/handler/oauth2/ui"
+	handleroption "github.com/tingtt/oauth2rbac/internal/api/handler/util/option"
+	jwtmiddleware "github.com/tingtt/oauth2rbac/internal/api/middleware/jwt"
+	"github.com/tingtt/oauth2rbac/internal/audit"
+	"github.com/tingtt/oauth2rbac/internal/session"
+	"github.com/tingtt/oauth2rbac/pkg/jwtclaims"
+
+	"github.com/go-chi/jwtauth/v5"
+)
+
+type handler struct {
+	jwt        *jwtauth.JWTAuth
+	admins     []acl.EmailRegex
+	sessions   session.Store
+	errorPages *ui.ErrorPages
+	audit      *audit.Logger
+}
+
+func New(option *handleroption.Option) handler {
+	return handler{option.JWTAuth, option.Admins, option.SessionStore, option.ErrorPages, option.AuditLogger}
+}
+
+// authorize returns the email of the admin.
+// If the request is not from admins, it responds 401 or 403.
+func (h *handler) authorize(res http.ResponseWriter, req *http.Request, reqURL url.URL) (string, bool) {
+	origin := reqURL.Scheme + "://" + reqURL.Host
+	claims, err := h.claims(req)
+	if err != nil {
+		res.Header().Set("WWW-Authenticate", fmt.Sprintf("Bearer realm=%q", origin))
+		h.errorPages.WriteJSON(res, ui.ErrorPageProps{
+			StatusCode: http.StatusUnauthorized,
+			Message:    "Sign in required.",
+			LoginURL:   origin + "/.auth/login",
+		})
+		return "", false
+	}
+	if !h.isAdmin(claims.Email) {
+		h.errorPages.WriteJSON(res, ui.ErrorPageProps{StatusCode: http.StatusForbidden, Email: claims.Email})
+		h.audit.LogRequest(req, reqURL, audit.Event{
+			Type:      audit.TypeAccessDenied,
+			Actor:     claims.Email,
+			SessionID: claims.SessionID,
+			Reason:    "not an admin",
+		})
+		return "", false
+	}
+	return claims.Email, true
+}
+
+func (h *handler) claims(req *http.Request) (jwtclaims.Claims, error) {
+	token, err := h.jwt.Decode(jwtmiddleware.TokenFromRequest(req))
+	if err != nil {
+		return jwtclaims.Claims{}, err
+	}
+	claimsJSON, _ := json.Marshal(token.PrivateClaims())
+	claims, err := jwtclaims.Unmarshal(claimsJSON)
+	if err != nil {
+		return jwtclaims.Claims{}, err
+	}
+	if err := session.Check(h.sessions, claims); err != nil {
+		return jwtclaims.Claims{}, err
+	}
+	return claims, nil
+}
+
+func (h *handler) isAdmin(email string) bool {
+	for _, admin := range h.admins {
+		if string(admin) == email || admin.Match(email) {
+			return true
+		}
+	}
+	return false
+}
+
+func writeJSON(res http.ResponseWriter, statusCode int, v any) {
+	res.Header().Set("Cache-Control", "no-store")
+	res.Header().Set("Content-Type", "application/json; charset=utf-8")
+	res.WriteHeader(statusCode)
+	if err := json.NewEncoder(res).Encode(v); err != nil {
+		slog.Error("failed to encode response", slog.String("err", err.Error()))
+	}
+}
@@ -0,0 +1,103 @@
+package adminhandler
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/tingtt/oauth2rbac/internal/api/handler/oauth2/ui"
+	logutil "github.com/tingtt/oauth2rbac/internal/api/handler/util/log"
+	urlutil "github.com/tingtt/oauth2rbac/internal/api/handler/util/url"
+	"github.com/tingtt/oauth2rbac/internal/audit"
+	"github.com/tingtt/oauth2rbac/internal/session"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// ListSessions lists sessions. (filter by the `email` query)
+func (h *handler) ListSessions(rw http.ResponseWriter, req *http.Request) {
+	reqURL := urlutil.RequestURL(*req.URL, urlutil.WithRequest(req), urlutil.WithXForwardedHeaders(req.Header))
+	res, logInfo := logutil.InfoLogger(reqURL, req.Method, rw, req)
+
+	if _, ok := h.authorize(res, req, reqURL); !ok {
+		logInfo("unauthorized")
+		return
+	}
+
+	sessions, err := h.sessions.List(req.URL.Query().Get("email"))
+	if err != nil {
+		h.errorPages.WriteJSON(res, ui.ErrorPageProps{StatusCode: http.StatusInternalServerError})
+		logInfo("failed to list sessions", slog.String("err", err.Error()))
+		return
+	}
+	for i := range sessions {
+		sessions[i].ProviderToken = nil
+	}
+	writeJSON(res, http.StatusOK, sessions)
+	logInfo("")
+}
+
+// RevokeSession revokes the session.
+func (h *handler) RevokeSession(rw http.ResponseWriter, req *http.Request) {
+	reqURL := urlutil.RequestURL(*req.URL, urlutil.WithRequest(req), urlutil.WithXForwardedHeaders(req.Header))
+	res, logInfo := logutil.InfoLogger(reqURL, req.Method, rw, req)
+
+	admin, ok := h.authorize(res, req, reqURL)
+	if !ok {
+		logInfo("unauthorized")
+		return
+	}
+
+	id := chi.URLParam(req, "sessionID")
+	err := h.sessions.Revoke(id)
+	if errors.Is(err, session.ErrNotFound) {
+		h.errorPages.WriteJSON(res, ui.ErrorPageProps{StatusCode: http.StatusNotFound})
+		logInfo("session not found", slog.String("sid", id))
+		return
+	}
+	if err != nil {
+		h.errorPages.WriteJSON(res, ui.ErrorPageProps{StatusCode: http.StatusInternalServerError})
+		logInfo("failed to revoke session", slog.String("err", err.Error()))
+		return
+	}
+	res.WriteHeader(http.StatusNoContent)
+	h.audit.LogRequest(req, reqURL, audit.Event{Type: audit.TypeSessionRevoked, Actor: admin, Target: id})
+	logInfo("session revoked", slog.String("admin", admin), slog.String("sid", id))
+}
+
+// RevokeSessions revokes sessions of the user given by the `email` query,
+// or all sessions with the `all=true` query.
+func (h *handler) RevokeSessions(rw http.ResponseWriter, req *http.Request) {
+	reqURL := urlutil.RequestURL(*req.URL, urlutil.WithRequest(req), urlutil.WithXForwardedHeaders(req.Header))
+	res, logInfo := logutil.InfoLogger(reqURL, req.Method, rw, req)
+
+	admin, ok := h.authorize(res, req, reqURL)
+	if !ok {
+		logInfo("unauthorized")
+		return
+	}
+
+	email := req.URL.Query().Get("email")
+	if email == "" && req.URL.Query().Get("all") != "true" {
+		h.errorPages.WriteJSON(res, ui.ErrorPageProps{
+			StatusCode: http.StatusBadRequest,
+			Message:    "query `email` or `all=true` is required",
+		})
+		logInfo("invalid request")
+		return
+	}
+	revoked, err := h.sessions.RevokeUser(email)
+	if err != nil {
+		h.errorPages.WriteJSON(res, ui.ErrorPageProps{StatusCode: http.StatusInternalServerError})
+		logInfo("failed to revoke sessions", slog.String("err", err.Error()))
+		return
+	}
+	writeJSON(res, http.StatusOK, map[string]int{"revoked": revoked})
+	h.audit.LogRequest(req, reqURL, audit.Event{
+		Type:    audit.TypeSessionsRevoked,
+		Actor:   admin,
+		Target:  email, // all sessions if empty
+		Details: map[string]any{"revoked": revoked},
+	})
+	logInfo("sessions revoked", slog.String("admin", admin), slog.String("email", email), slog.Int("revoked", revoked))
+}
@@ -0,0 +1,208 @@
+package apitokenhandler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"mime"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tingtt/oauth2rbac/internal/acl"
+	"github.com/tingtt/oauth2rbac/internal/api/handler/oauth2/ui"
+	logutil "github.com/tingtt/oauth2rbac/internal/api/handler/util/log"
+	urlutil "github.com/tingtt/oauth2rbac/internal/api/handler/util/url"
+	"github.com/tingtt/oauth2rbac/internal/apitoken"
+	"github.com/tingtt/oauth2rbac/internal/audit"
+)
+
+type createRequest struct {
+	Name      string                                     `json:"name"`
+	Scopes    map[ /* origin */ string]acl.AllowedScopes `json:"scopes"`
+	ExpiresIn *time.Duration                             `json:"-"`
+}
+
+type createResponse struct {
+	TokenString string `json:"token"`
+	apitoken.Token
+}
+
+func (h *handler) Create(rw http.ResponseWriter, req *http.Request) {
+	reqURL := urlutil.RequestURL(*req.URL, urlutil.WithRequest(req), urlutil.WithXForwardedHeaders(req.Header))
+	res, logInfo := logutil.InfoLogger(reqURL, req.Method, rw, req)
+
+	claims, ok := h.signedIn(res, req, reqURL)
+	if !ok {
+		logInfo("unauthorized")
+		return
+	}
+	email := claims.Email
+
+	mediaType, _, _ := mime.ParseMediaType(req.Header.Get("Content-Type"))
+	isJSON := mediaType == "application/json"
+	writeBadRequest := func(err error) {
+		if isJSON {
+			h.errorPages.WriteJSON(res, ui.ErrorPageProps{StatusCode: http.StatusBadRequest, Message: err.Error()})
+		} else {
+			h.writePage(res, email, ui.APITokensPageProps{Error: err.Error()}, http.StatusBadRequest)
+		}
+		logInfo("invalid request", slog.String("err", err.Error()))
+	}
+
+	var createReq createRequest
+	var err error
+	if isJSON {
+		createReq, err = parseJSONRequest(req)
+	} else {
+		createReq, err = parseFormRequest(req)
+	}
+	if err != nil {
+		writeBadRequest(err)
+		return
+	}
+	if err := h.validateScopes(email, createReq.Scopes); err != nil {
+		writeBadRequest(err)
+		return
+	}
+
+	tokenStr, hash := apitoken.Generate()
+	now := time.Now()
+	token := apitoken.Token{
+		ID:        apitoken.NewID(),
+		Name:      createReq.Name,
+		Email:     email,
+		Hash:      hash,
+		Scopes:    createReq.Scopes,
+		CreatedAt: now,
+	}
+	if createReq.ExpiresIn != nil {
+		expiresAt := now.Add(*createReq.ExpiresIn)
+		token.ExpiresAt = &expiresAt
+	}
+	if err := h.store.Create(token); err != nil {
+		slog.Error("failed to create api token", slog.String("err", err.Error()))
+		if isJSON {
+			h.errorPages.WriteJSON(res, ui.ErrorPageProps{StatusCode: http.StatusInternalServerError})
+		} else {
+			h.writePage(res, email, ui.APITokensPageProps{Error: "failed to create api token"}, http.StatusInternalServerError)
+		}
+		logInfo("failed to create api token")
+		return
+	}
+
+	h.audit.LogRequest(req, reqURL, audit.Event{
+		Type:      audit.TypeAPITokenCreated,
+		Actor:     email,
+		SessionID: claims.SessionID,
+		Target:    token.ID,
+		Details:   map[string]any{"name": token.Name, "scopes": token.Scopes, "expires_at": token.ExpiresAt},
+	})
+	if isJSON {
+		writeJSON(res, http.StatusCreated, createResponse{tokenStr, withoutHash(token)[0]})
+	} else {
+		h.writePage(res, email, ui.APITokensPageProps{NewToken: tokenStr}, http.StatusCreated)
+	}
+	logInfo("api token created", slog.String("id", token.ID))
+}
+
+// Example body:
+//
+//	{"name": "ci", "scopes": {"https://example.com": {"/api/": ["GET"]}}, "expires_in": "720h"}
+func parseJSONRequest(req *http.Request) (createRequest, error) {
+	var body struct {
+		createRequest
+		ExpiresIn string `json:"expires_in"`
+	}
+	if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+		return createRequest{}, fmt.Errorf("invalid json: %w", err)
+	}
+	createReq := body.createRequest
+	if body.ExpiresIn != "" {
+		expiresIn, err := time.ParseDuration(body.ExpiresIn)
+		if err != nil {
+			return createRequest{}, fmt.Errorf("invalid expires_in: %w", err)
+		}
+		createReq.ExpiresIn = &expiresIn
+	}
+	return createReq, validateRequest(createReq)
+}
+
+func parseFormRequest(req *http.Request) (createRequest, error) {
+	if err := req.ParseForm(); err != nil {
+		return createRequest{}, err
+	}
+	methods := []acl.Method{}
+	for _, method := range strings.Split(req.PostForm.Get("methods"), ",") {
+		if method = strings.TrimSpace(method); method != "" {
+			methods = append(methods, method)
+		}
+	}
+	createReq := createRequest{
+		Name: req.PostForm.Get("name"),
+		Scopes: map[string]acl.AllowedScopes{
+			req.PostForm.Get("origin"): {req.PostForm.Get("path"): methods},
+		},
+	}
+	if days := req.PostForm.Get("expires_in_days"); days != "" && days != "0" {
+		n, err := strconv.Atoi(days)
+		if err != nil || n < 0 {
+			return createRequest{}, errors.New("invalid expires_in_days")
+		}
+		expiresIn := time.Duration(n) * 24 * time.Hour
+		createReq.ExpiresIn = &expiresIn
+	}
+	return createReq, validateRequest(createReq)
+}
+
+func validateRequest(createReq createRequest) error {
+	if strings.TrimSpace(createReq.Name) == "" {
+		return errors.New("name is required")
+	}
+	if createReq.ExpiresIn != nil && *createReq.ExpiresIn <= 0 {
+		return errors.New("expiry must be positive")
+	}
+	if len(createReq.Scopes) == 0 {
+		return errors.New("scopes are required")
+	}
+	for origin, scopes := range createReq.Scopes {
+		if len(scopes) == 0 {
+			return fmt.Errorf("scopes for `%s` are empty", origin)
+		}
+		for path, methods := range scopes {
+			if !strings.HasPrefix(path, "/") {
+				return fmt.Errorf("path `%s` must start with \"/\"", path)
+			}
+			if len(methods) == 0 {
+				return fmt.Errorf("methods for `%s%s` are empty", origin, path)
+			}
+			for i := range methods {
+				methods[i] = strings.ToUpper(methods[i])
+			}
+		}
+	}
+	return nil
+}
+
+// validateScopes checks the scopes are no wider than the user's allowed scopes.
+// Scopes allowed by groups are not given to API tokens, as the groups cannot be resolved without the user signing in.
+func (h *handler) validateScopes(email string, scopes map[string]acl.AllowedScopes) error {
+	origins := h.acl.Origins()
+	for origin, requested := range scopes {
+		if !slices.Contains(origins, origin) {
+			return fmt.Errorf("origin `%s` is not configured", origin)
+		}
+		originURL, err := url.Parse(origin)
+		if err != nil {
+			return fmt.Errorf("invalid origin `%s`", origin)
+		}
+		if !h.acl.AllowedScopes(originURL, email).Covers(requested) {
+			return fmt.Errorf("scopes for `%s` are wider than allowed for %s (scopes allowed by groups are not available for api tokens)", origin, email)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+package apitokenhandler
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/url"
+
+	"github.com/tingtt/oauth2rbac/internal/acl"
+	"github.com/tingtt/oauth2rbac/internal/api/handler/oauth2/ui"
+	cookieutil "github.com/tingtt/oauth2rbac/internal/api/handler/util/cookie"
+	logutil "github.com/tingtt/oauth2rbac/internal/api/handler/util/log"
+	negotiateutil "github.com/tingtt/oauth2rbac/internal/api/handler/util/negotiate"
+	handleroption "github.com/tingtt/oauth2rbac/internal/api/handler/util/option"
+	jwtmiddleware "github.com/tingtt/oauth2rbac/internal/api/middleware/jwt"
+	"github.com/tingtt/oauth2rbac/internal/apitoken"
+	"github.com/tingtt/oauth2rbac/internal/audit"
+	"github.com/tingtt/oauth2rbac/internal/session"
+	"github.com/tingtt/oauth2rbac/pkg/jwtclaims"
+
+	"github.com/go-chi/jwtauth/v5"
+)
+
+type handler struct {
+	store      apitoken.Store
+	jwt        *jwtauth.JWTAuth
+	acl        acl.Provider
+	cookie     cookieutil.Controller
+	errorPages *ui.ErrorPages
+	sessions   session.Store
+	audit      *audit.Logger
+}
+
+func New(option *handleroption.Option) handler {
+	return handler{option.APITokenStore, option.JWTAuth, option.ACLProvider, option.CookieController, option.ErrorPages, option.SessionStore, option.AuditLogger}
+}
+
+// signedIn returns the claims of the signed-in user.
+// If not signed in, it responds 401 (API clients) or redirects to the login page.
+// API tokens and service accounts cannot be used to manage API tokens.
+func (h *handler) signedIn(res *logutil.CustomResponseWriter, req *http.Request, reqURL url.URL) (jwtclaims.Claims, bool) {
+	token, err := h.jwt.Decode(jwtmiddleware.TokenFromRequest(req))
+	if err == nil {
+		claimsJSON, _ := json.Marshal(token.PrivateClaims())
+		claims, err := jwtclaims.Unmarshal(claimsJSON)
+		if err == nil && claims.Email != "" && claims.ServiceAccount == nil && session.Check(h.sessions, claims) == nil {
+			return claims, true
+		}
+	}
+
+	if negotiateutil.WantsJSON(req) {
+		origin := reqURL.Scheme + "://" + reqURL.Host
+		h.errorPages.WriteJSON(res, ui.ErrorPageProps{
+			StatusCode: http.StatusUnauthorized,
+			Message:    "Sign in required.",
+			LoginURL:   origin + "/.auth/login",
+		})
+		return jwtclaims.Claims{}, false
+	}
+	h.cookie.SetRedirectURLForAfterLogin(res, reqURL.Scheme+"://"+reqURL.Host+"/.auth/tokens")
+	http.Redirect(res, req, "/.auth/login", http.StatusFound)
+	return jwtclaims.Claims{}, false
+}
+
+func (h *handler) writePage(res http.ResponseWriter, email string, props ui.APITokensPageProps, statusCode int) {
+	tokens, err := h.store.List(email)
+	if err != nil {
+		slog.Error("failed to list api tokens", slog.String("err", err.Error()))
+	}
+	props.Email = email
+	props.Tokens = tokens
+	props.Origins = h.acl.Origins()
+
+	res.Header().Set("Cache-Control", "no-store")
+	res.Header().Set("Content-Type", "text/html; charset=utf-8")
+	res.WriteHeader(statusCode)
+	if err := ui.APITokensPage(props).Render(res); err != nil {
+		slog.Error("failed render html", slog.String("err", err.Error()))
+	}
+}
+
+func writeJSON(res http.ResponseWriter, statusCode int, v any) {
+	res.Header().Set("Cache-Control", "no-store")
+	res.Header().Set("Content-Type", "application/json; charset=utf-8")
+	res.WriteHeader(statusCode)
+	if err := json.NewEncoder(res).Encode(v); err != nil {
+		slog.Error("failed to encode response", slog.String("err", err.Error()))
+	}
+}
+
+func withoutHash(tokens ...apitoken.Token) []apitoken.Token {
+	for i := range tokens {
+		tokens[i].Hash = ""
+	}
+	return tokens
+}
@@ -0,0 +1,37 @@
+package apitokenhandler
+
+import (
+	"log/slog"
+	"net/http"
+
+	"github.com/tingtt/oauth2rbac/internal/api/handler/oauth2/ui"
+	logutil "github.com/tingtt/oauth2rbac/internal/api/handler/util/log"
+	negotiateutil "github.com/tingtt/oauth2rbac/internal/api/handler/util/negotiate"
+	urlutil "github.com/tingtt/oauth2rbac/internal/api/handler/util/url"
+)
+
+func (h *handler) List(rw http.ResponseWriter, req *http.Request) {
+	reqURL := urlutil.RequestURL(*req.URL, urlutil.WithRequest(req), urlutil.WithXForwardedHeaders(req.Header))
+	res, logInfo := logutil.InfoLogger(reqURL, req.Method, rw, req)
+
+	claims, ok := h.signedIn(res, req, reqURL)
+	if !ok {
+		logInfo("unauthorized")
+		return
+	}
+	email := claims.Email
+
+	if negotiateutil.WantsJSON(req) {
+		tokens, err := h.store.List(email)
+		if err != nil {
+			h.errorPages.WriteJSON(res, ui.ErrorPageProps{StatusCode: http.StatusInternalServerError})
+			logInfo("failed to list api tokens", slog.String("err", err.Error()))
+			return
+		}
+		writeJSON(res, http.StatusOK, withoutHash(tokens...))
+		logInfo("")
+		return
+	}
+	h.writePage(res, email, ui.APITokensPageProps{}, http.StatusOK)
+	logInfo("")
+}
@@ -0,0 +1,58 @@
+package apitokenhandler
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/tingtt/oauth2rbac/internal/api/handler/oauth2/ui"
+	logutil "github.com/tingtt/oauth2rbac/internal/api/handler/util/log"
+	negotiateutil "github.com/tingtt/oauth2rbac/internal/api/handler/util/negotiate"
+	urlutil "github.com/tingtt/oauth2rbac/internal/api/handler/util/url"
+	"github.com/tingtt/oauth2rbac/internal/apitoken"
+	"github.com/tingtt/oauth2rbac/internal/audit"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func (h *handler) Revoke(rw http.ResponseWriter, req *http.Request) {
+	reqURL := urlutil.RequestURL(*req.URL, urlutil.WithRequest(req), urlutil.WithXForwardedHeaders(req.Header))
+	res, logInfo := logutil.InfoLogger(reqURL, req.Method, rw, req)
+
+	claims, ok := h.signedIn(res, req, reqURL)
+	if !ok {
+		logInfo("unauthorized")
+		return
+	}
+	email := claims.Email
+
+	id := chi.URLParam(req, "tokenID")
+	err := h.store.Revoke(email, id)
+	if err != nil && !errors.Is(err, apitoken.ErrNotFound) {
+		slog.Error("failed to revoke api token", slog.String("err", err.Error()))
+	}
+	if err == nil {
+		h.audit.LogRequest(req, reqURL, audit.Event{Type: audit.TypeAPITokenRevoked, Actor: email, SessionID: claims.SessionID, Target: id})
+	}
+	if negotiateutil.WantsJSON(req) || req.Method == http.MethodDelete {
+		switch {
+		case errors.Is(err, apitoken.ErrNotFound):
+			h.errorPages.WriteJSON(res, ui.ErrorPageProps{StatusCode: http.StatusNotFound})
+		case err != nil:
+			h.errorPages.WriteJSON(res, ui.ErrorPageProps{StatusCode: http.StatusInternalServerError})
+		default:
+			res.WriteHeader(http.StatusNoContent)
+			logInfo("api token revoked", slog.String("id", id))
+			return
+		}
+		logInfo("failed to revoke api token", slog.String("id", id))
+		return
+	}
+	if err != nil {
+		h.writePage(res, email, ui.APITokensPageProps{Error: "failed to revoke api token"}, http.StatusNotFound)
+		logInfo("failed to revoke api token", slog.String("id", id))
+		return
+	}
+	http.Redirect(res, req, "/.auth/tokens", http.StatusSeeOther)
+	logInfo("api token revoked", slog.String("id", id))
+}
@@ -0,0 +1,56 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"net/http"
+	"time"
+
+	handleroption "github.com/tingtt/oauth2rbac/internal/api/handler/util/option"
+	"github.com/tingtt/oauth2rbac/internal/health"
+	"github.com/tingtt/oauth2rbac/internal/oauth2"
+)
+
+// readinessCacheTTL is the TTL of the checks of the providers and upstreams, not to request them on every `/readyz`. (unauthenticated)
+const readinessCacheTTL = 10 * time.Second
+
+// ReadinessDetails serves `/readyz` with the results of the checks, for the admin listener.
+// The public `/readyz` responds only the status.
+func (h *Handler) ReadinessDetails() http.Handler {
+	return health.DetailsHandler(h.readinessCheck)
+}
+
+// readiness returns the checks of `/readyz`.
+// It fails on shutdown, or if all upstreams are unreachable. (if enabled)
+// It is degraded if the OpenID Connect discovery of any provider fails, as only signing in depends on the providers.
+func (h *Handler) readiness(oauth2Config map[string]oauth2.Service, option *handleroption.Option) health.Checker {
+	checks := maps.Clone(option.ReadinessChecks)
+	if checks == nil {
+		checks = health.All{}
+	}
+	checks["shutdown"] = health.CheckFunc(func(context.Context) error {
+		if h.shuttingDown.Load() {
+			return errors.New("shutting down")
+		}
+		return nil
+	})
+
+	client := &http.Client{Timeout: health.Timeout}
+	providers := health.All{}
+	for name, service := range oauth2Config {
+		if discoveryURL := service.DiscoveryURL(); discoveryURL != "" {
+			providers[name] = health.OIDCDiscovery(client, discoveryURL)
+		}
+	}
+	if len(providers) != 0 {
+		checks["oauth2_providers"] = health.Cached(health.Optional{Checker: providers}, readinessCacheTTL)
+	}
+
+	if option.CheckUpstreams {
+		checks["upstreams"] = health.Cached(health.Dynamic(func() health.Checker {
+			return health.Upstreams(h.revProxy.Targets())
+		}), readinessCacheTTL)
+	}
+	return checks
+}
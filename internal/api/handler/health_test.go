@@ -0,0 +1,119 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/tingtt/oauth2rbac/internal/acl"
+	reverseproxy "github.com/tingtt/oauth2rbac/internal/api/handler/reverse_proxy"
+	handleroption "github.com/tingtt/oauth2rbac/internal/api/handler/util/option"
+	"github.com/tingtt/oauth2rbac/internal/health"
+	"github.com/tingtt/oauth2rbac/internal/oauth2"
+
+	"github.com/stretchr/testify/assert"
+	xoauth2 "golang.org/x/oauth2"
+)
+
+func TestHandler_readiness(t *testing.T) {
+	t.Parallel()
+
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("proxied"))
+	}))
+	t.Cleanup(upstream.Close)
+
+	h, err := New(nil, reverseproxy.Config{Proxies: []reverseproxy.Proxy{
+		{ExternalURL: "http://example.com/", Target: reverseproxy.Target{URL: upstream.URL}},
+	}},
+		handleroption.WithJWTAuth("secret"),
+		handleroption.WithACL(acl.Pool{"http://example.com": {PathScopes: map[acl.Path][]acl.ScopePath{
+			"/": {{Methods: []acl.Method{"*"}, EmailRegexes: []acl.EmailRegex{"-"}}},
+		}}}),
+		handleroption.WithSecureCookie(false),
+		handleroption.WithReadinessChecks(health.All{"config": health.CheckFunc(func(context.Context) error { return nil })}),
+		handleroption.WithUpstreamHealthCheck(true),
+	)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	get := func(handler http.Handler, path string) (int, health.Status) {
+		rw := httptest.NewRecorder()
+		handler.ServeHTTP(rw, httptest.NewRequest(http.MethodGet, "http://example.com"+path, nil))
+		var status health.Status
+		json.NewDecoder(rw.Body).Decode(&status)
+		return rw.Code, status
+	}
+
+	code, status := get(h, "/livez")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, health.StatusOK, status.Status)
+
+	code, status = get(h, "/readyz")
+	assert.Equal(t, http.StatusOK, code, "not shadowed by the proxy of `http://example.com/`")
+	assert.Equal(t, health.Status{Status: health.StatusOK}, status, "results of the checks not exposed to the public")
+
+	code, status = get(h.ReadinessDetails(), "/readyz")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, health.StatusOK, status.Checks["config"].Status)
+	assert.Equal(t, health.StatusOK, status.Checks["upstreams"].Status)
+	assert.Contains(t, status.Checks["upstreams"].Checks, upstream.Listener.Addr().String())
+
+	h.StartShutdown()
+
+	code, status = get(h, "/readyz")
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, health.Status{Status: health.StatusFailed}, status)
+
+	code, status = get(h.ReadinessDetails(), "/readyz")
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, health.Status{Status: health.StatusFailed, Error: "shutting down"}, status.Checks["shutdown"])
+
+	code, _ = get(h, "/livez")
+	assert.Equal(t, http.StatusOK, code)
+}
+
+func TestHandler_readiness_oauth2Providers(t *testing.T) {
+	t.Parallel()
+
+	var requested atomic.Int32
+	idp := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requested.Add(1)
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	t.Cleanup(idp.Close)
+
+	h, err := New(map[string]oauth2.Service{
+		"gitlab": oauth2.New(&xoauth2.Config{}, "gitlab", oauth2.Provider{DiscoveryURL: idp.URL + "/.well-known/openid-configuration"}, false),
+	}, reverseproxy.Config{},
+		handleroption.WithJWTAuth("secret"),
+		handleroption.WithACL(acl.Pool{}),
+		handleroption.WithSecureCookie(false),
+	)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	for range 3 {
+		rw := httptest.NewRecorder()
+		h.ServeHTTP(rw, httptest.NewRequest(http.MethodGet, "http://example.com/readyz", nil))
+		var status health.Status
+		json.NewDecoder(rw.Body).Decode(&status)
+
+		assert.Equal(t, http.StatusOK, rw.Code, "not failed by the unavailable provider")
+		assert.Equal(t, health.Status{Status: health.StatusDegraded}, status)
+	}
+
+	rw := httptest.NewRecorder()
+	h.ReadinessDetails().ServeHTTP(rw, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	var status health.Status
+	json.NewDecoder(rw.Body).Decode(&status)
+	assert.Equal(t, http.StatusOK, rw.Code)
+	assert.Equal(t, health.StatusDegraded, status.Checks["oauth2_providers"].Status)
+	assert.Equal(t, health.StatusFailed, status.Checks["oauth2_providers"].Checks["gitlab"].Status)
+	assert.Equal(t, int32(1), requested.Load(), "discovery document cached, and shared with the admin listener")
+}
@@ -0,0 +1,57 @@
+package handler
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/tingtt/oauth2rbac/internal/acl"
+	reverseproxy "github.com/tingtt/oauth2rbac/internal/api/handler/reverse_proxy"
+	handleroption "github.com/tingtt/oauth2rbac/internal/api/handler/util/option"
+	"github.com/tingtt/oauth2rbac/internal/metrics"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHandler_metrics(t *testing.T) {
+	t.Parallel()
+
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	t.Cleanup(upstream.Close)
+	closed, _ := net.Listen("tcp", "127.0.0.1:0")
+	closed.Close()
+	downURL := "http://" + closed.Addr().String() + "/"
+
+	h, err := New(nil, reverseproxy.Config{Proxies: []reverseproxy.Proxy{
+		{ExternalURL: "http://metrics.example.com/", Target: reverseproxy.Target{URL: upstream.URL}},
+		{ExternalURL: "http://metrics.example.com/down/", Target: reverseproxy.Target{URL: downURL}},
+	}},
+		handleroption.WithJWTAuth("secret"),
+		handleroption.WithACL(acl.Pool{"http://metrics.example.com": {PathScopes: map[acl.Path][]acl.ScopePath{
+			"/":        {{Methods: []acl.Method{"GET"}, EmailRegexes: []acl.EmailRegex{"-"}}},
+			"/private": {{Methods: []acl.Method{"GET"}, EmailRegexes: []acl.EmailRegex{"*@example.com"}}},
+		}}}),
+		handleroption.WithSecureCookie(false),
+	)
+	if !assert.NoError(t, err) {
+		return
+	}
+	for _, path := range []string{"/", "/down/", "/private"} {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "http://metrics.example.com"+path, nil))
+	}
+
+	rw := httptest.NewRecorder()
+	metrics.Handler().ServeHTTP(rw, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body, _ := io.ReadAll(rw.Body)
+	scraped := string(body)
+
+	assert.Contains(t, scraped, `oauth2rbac_http_requests_total{method="GET",origin="http://metrics.example.com",status="200",target="`+upstream.URL+`"} 1`)
+	assert.Contains(t, scraped, `oauth2rbac_http_requests_total{method="GET",origin="http://metrics.example.com",status="502",target="`+downURL+`"} 1`)
+	assert.Contains(t, scraped, `oauth2rbac_http_requests_total{method="GET",origin="http://metrics.example.com",status="302",target=""} 1`)
+	assert.Contains(t, scraped, `oauth2rbac_authorizations_total{origin="http://metrics.example.com",outcome="public"} 2`)
+	assert.Contains(t, scraped, `oauth2rbac_authorizations_total{origin="http://metrics.example.com",outcome="login_redirect"} 1`)
+	assert.Contains(t, scraped, `oauth2rbac_upstream_errors_total{target="`+downURL+`"} 1`)
+	assert.Contains(t, scraped, `oauth2rbac_acl_cache_lookups_total{lookup="login_required",result="hit"}`)
+}
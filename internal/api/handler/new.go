@@ -2,43 +2,114 @@ package handler
 
 import (
 	"net/http"
+	"slices"
+	"sync/atomic"
 
+	adminhandler "github.com/tingtt/oauth2rbac/internal/api/handler/admin"
+	apitokenhandler "github.com/tingtt/oauth2rbac/internal/api/handler/apitoken"
 	oauth2handler "github.com/tingtt/oauth2rbac/internal/api/handler/oauth2"
 	reverseproxy "github.com/tingtt/oauth2rbac/internal/api/handler/reverse_proxy"
 	handleroption "github.com/tingtt/oauth2rbac/internal/api/handler/util/option"
+	"github.com/tingtt/oauth2rbac/internal/health"
+	"github.com/tingtt/oauth2rbac/internal/metrics"
 	"github.com/tingtt/oauth2rbac/internal/oauth2"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/jwtauth/v5"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
 )
 
+// healthCheckPaths is not traced.
+var healthCheckPaths = []string{"/healthz", "/livez", "/readyz"}
+
+// Handler is the handler of the auth endpoints and the reverse proxy.
+type Handler struct {
+	http.Handler
+	revProxy interface {
+		http.Handler
+		UpdateConfig(reverseproxy.Config)
+		Targets() []string
+	}
+
+	// readinessCheck is the checks of `/readyz`, shared with the admin listener.
+	readinessCheck health.Checker
+
+	shuttingDown atomic.Bool
+	// inFlight is the number of requests being served, including upgraded connections. (e.g. WebSocket)
+	inFlight atomic.Int64
+}
+
+// UpdateReverseProxyConfig replaces the proxies while serving requests. (e.g. Kubernetes Ingresses changed)
+// The ACL is updated with the acl.Provider given (e.g. acl.SwappableProvider), and must be swapped before,
+// so that the allowed scopes of JWTs issued before are re-evaluated with the new ACL.
+func (h *Handler) UpdateReverseProxyConfig(config reverseproxy.Config) {
+	h.revProxy.UpdateConfig(config)
+}
+
 func New(
 	oauth2Config map[string]oauth2.Service,
 	revProxyConfig reverseproxy.Config,
 	handlerOptions ...handleroption.Applier,
-) (http.Handler, error) {
+) (*Handler, error) {
 	option, err := handleroption.New(handlerOptions...)
 	if err != nil {
 		return nil, err
 	}
 
 	oauth2Handler := oauth2handler.New(oauth2Config, option)
+	h := &Handler{revProxy: reverseproxy.NewReverseProxyHandler(revProxyConfig, oauth2Config, option)}
 
 	r := chi.NewRouter()
+	r.Use(otelhttp.NewMiddleware("oauth2rbac",
+		otelhttp.WithSpanNameFormatter(func(_ string, r *http.Request) string { return r.Method }),
+		otelhttp.WithFilter(func(r *http.Request) bool { return !slices.Contains(healthCheckPaths, r.URL.Path) }),
+	))
+	r.Use(metrics.Middleware)
+	r.Use(h.trackRequests)
 	r.Use(jwtauth.Verifier(option.JWTAuth))
-	r.Get("/healthz", healthCheck)
+	r.Get("/healthz", h.healthCheck)
+	// registered before the proxies, so that they are never shadowed by proxied origins
+	r.Get("/livez", health.Handler(health.All{}))
+	h.readinessCheck = h.readiness(oauth2Config, option)
+	r.Get("/readyz", health.Handler(h.readinessCheck))
 	r.Route("/.auth", func(r chi.Router) {
 		r.Get("/login", oauth2Handler.SelectProvider)
+		r.Get("/logout", oauth2Handler.Logout)
+		r.Get("/userinfo", oauth2Handler.UserInfo)
+		r.Post("/token", oauth2Handler.Token)
+		r.Options("/userinfo", oauth2Handler.UserInfo)
 		r.Get("/{oauthProvider}/login", oauth2Handler.Login)
 		r.Get("/{oauthProvider}/callback", oauth2Handler.Callback)
+
+		if /* api tokens enabled */ option.APITokenStore != nil {
+			apiTokenHandler := apitokenhandler.New(option)
+			r.Get("/tokens", apiTokenHandler.List)
+			r.Post("/tokens", apiTokenHandler.Create)
+			r.Post("/tokens/{tokenID}/revoke", apiTokenHandler.Revoke)
+			r.Delete("/tokens/{tokenID}", apiTokenHandler.Revoke)
+		}
+
+		if /* admin APIs enabled */ len(option.Admins) != 0 && option.SessionStore != nil {
+			adminHandler := adminhandler.New(option)
+			r.Route("/admin", func(r chi.Router) {
+				r.Get("/sessions", adminHandler.ListSessions)
+				r.Delete("/sessions", adminHandler.RevokeSessions)
+				r.Delete("/sessions/{sessionID}", adminHandler.RevokeSession)
+			})
+		}
 	})
 
-	revProxy := reverseproxy.NewReverseProxyHandler(revProxyConfig, option)
-	r.HandleFunc("/*", revProxy.ServeHTTP)
-	return r, nil
+	r.HandleFunc("/*", h.revProxy.ServeHTTP)
+	h.Handler = r
+	return h, nil
 }
 
-func healthCheck(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) healthCheck(w http.ResponseWriter, r *http.Request) {
+	if h.shuttingDown.Load() {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		w.Write([]byte("shutting down"))
+		return
+	}
 	w.WriteHeader(http.StatusOK)
 	w.Write([]byte("healthy"))
 }
@@ -5,16 +5,23 @@ import (
 	"fmt"
 	"log/slog"
 	"net/http"
+	"net/url"
 	"time"
 
 	"github.com/tingtt/oauth2rbac/internal/api/handler/oauth2/ui"
 	cookieutil "github.com/tingtt/oauth2rbac/internal/api/handler/util/cookie"
 	logutil "github.com/tingtt/oauth2rbac/internal/api/handler/util/log"
 	urlutil "github.com/tingtt/oauth2rbac/internal/api/handler/util/url"
+	"github.com/tingtt/oauth2rbac/internal/audit"
+	"github.com/tingtt/oauth2rbac/internal/metrics"
+	"github.com/tingtt/oauth2rbac/internal/oauth2"
+	"github.com/tingtt/oauth2rbac/internal/session"
+	"github.com/tingtt/oauth2rbac/internal/tracing"
 	"github.com/tingtt/oauth2rbac/pkg/jwtclaims"
 
 	"github.com/go-chi/chi/v5"
-	"github.com/go-chi/jwtauth/v5"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 func (h *handler) Callback(rw http.ResponseWriter, req *http.Request) {
@@ -30,59 +37,114 @@ func (h *handler) Callback(rw http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	redirectURL := reqURL.Scheme + "://" + reqURL.Host + "/.auth/" + providerName + "/callback"
+	origin := reqURL.Scheme + "://" + reqURL.Host
+	redirectURL := origin + "/.auth/" + providerName + "/callback"
 
-	ctx := context.Background()
-	oauth2Token, err := oauth2.Exchange(ctx, req.FormValue("code"), redirectURL)
+	// not canceled by the client, but traced in the request
+	ctx := context.WithoutCancel(req.Context())
+	spanCtx, span := tracing.Start(ctx, "oauth2.Exchange", trace.WithAttributes(attribute.String("oauth2rbac.provider", providerName)))
+	oauth2Token, err := oauth2.Exchange(spanCtx, req.FormValue("code"), redirectURL)
+	tracing.End(span, err)
 	if err != nil {
 		slog.Error("failed to exchange code to token", slog.String("provider", providerName), slog.String("error", err.Error()))
-		res.WriteHeader(http.StatusInternalServerError)
-		res.Write([]byte(clientSideRedirectConfirmErrorHTML(
-			/* request redirect to */ fmt.Sprintf("/.auth/login/%s", req.URL.RawQuery),
-			/* cause */ "failed to exchange code to token",
-		)))
+		h.errorPages.Write(res, req, origin, ui.ErrorPageProps{
+			StatusCode: http.StatusInternalServerError,
+			Message:    "failed to exchange code to token",
+			LoginURL:   "/.auth/login",
+		})
 		logInfo("failed to exchange code to token", slog.String("provider", providerName), slog.String("error", err.Error()))
+		metrics.Logins.WithLabelValues(providerName, metrics.ResultFailure).Inc()
+		h.audit.LogRequest(req, reqURL, audit.Event{Type: audit.TypeLoginFailed, Provider: providerName, Reason: "failed to exchange code to token: " + err.Error()})
 		return
 	}
-	oauth2ProviderUsername, email, err := oauth2.GetUserInfo(ctx, oauth2Token)
+	spanCtx, span = tracing.Start(ctx, "oauth2.GetUserInfo", trace.WithAttributes(attribute.String("oauth2rbac.provider", providerName)))
+	userInfo, err := oauth2.GetUserInfo(spanCtx, oauth2Token)
+	tracing.End(span, err)
 	if err != nil {
 		slog.Error("failed to get userinfo", slog.String("provider", providerName), slog.String("error", err.Error()))
-		res.WriteHeader(http.StatusInternalServerError)
-		res.Write([]byte(clientSideRedirectConfirmErrorHTML(
-			/* request redirect to */ fmt.Sprintf("/.auth/login/%s", req.URL.RawQuery),
-			/* cause */ "failed to get userinfo",
-		)))
+		h.errorPages.Write(res, req, origin, ui.ErrorPageProps{
+			StatusCode: http.StatusInternalServerError,
+			Message:    "failed to get userinfo",
+			LoginURL:   "/.auth/login",
+		})
 		logInfo("failed to get userinfo", slog.String("provider", providerName), slog.String("error", err.Error()))
+		metrics.Logins.WithLabelValues(providerName, metrics.ResultFailure).Inc()
+		h.audit.LogRequest(req, reqURL, audit.Event{Type: audit.TypeLoginFailed, Provider: providerName, Reason: "failed to get userinfo: " + err.Error()})
 		return
 	}
 
-	c := JWTClaims{
-		AllowedScopes: h.acl.AllowedScopes(&reqURL, email),
-		Email:         email,
-		Roles:         h.acl.Roles(&reqURL, email),
+	c := jwtclaims.Claims{
+		AuthTime: time.Now().Unix(),
 	}
-	switch providerName {
+	switch oauth2.Type() {
 	case "github":
-		c.GitHub = &jwtclaims.ClaimsGitHub{ID: oauth2ProviderUsername}
+		c.GitHub = &jwtclaims.ClaimsGitHub{ID: userInfo.Username, Orgs: userInfo.GitHubOrgs, Teams: userInfo.GitHubTeams}
 	case "google":
-		c.Google = &jwtclaims.ClaimsGoogle{Username: oauth2ProviderUsername}
+		c.Google = &jwtclaims.ClaimsGoogle{Username: userInfo.Username, HostedDomain: userInfo.GoogleHostedDomain, Groups: userInfo.GoogleGroups}
+	case "gitlab":
+		c.GitLab = &jwtclaims.ClaimsGitLab{Username: userInfo.Username}
+	case "entra":
+		c.Entra = &jwtclaims.ClaimsEntra{Username: userInfo.Username, Groups: userInfo.EntraGroups}
+	case "bitbucket":
+		c.Bitbucket = &jwtclaims.ClaimsBitbucket{Username: userInfo.Username}
 	}
-	claim := c.MapCollect()
-	jwtauth.SetIssuedNow(claim)
-	jwtauth.SetExpiryIn(claim, time.Hour)
-	_, tokenStr, err := h.jwt.Encode(claim)
+	email := h.selectEmail(&reqURL, userInfo, c.Groups())
+	c.Email = email
+	tracing.SetUserEmail(ctx, email)
+	c.AllowedScopes = h.acl.AllowedScopes(&reqURL, email, c.Groups()...)
+	c.Roles = h.acl.Roles(&reqURL, email, c.Groups()...)
+	if /* sessions enabled */ h.sessions != nil {
+		c.SessionID = session.NewID()
+	}
+	token, tokenStr, err := h.tokenIssuer.Issue(&reqURL, c)
 	if err != nil {
-		slog.Error(fmt.Errorf("failed to encode jwt token: %w", err).Error())
-		res.WriteHeader(http.StatusInternalServerError)
-		res.Write([]byte(clientSideRedirectConfirmErrorHTML(
-			/* request redirect to */ fmt.Sprintf("/.auth/login/%s", req.URL.RawQuery),
-			/* cause */ "failed to encode jwt token",
-		)))
+		slog.Error(err.Error())
+		h.errorPages.Write(res, req, origin, ui.ErrorPageProps{
+			StatusCode: http.StatusInternalServerError,
+			Message:    "failed to encode jwt token",
+			LoginURL:   "/.auth/login",
+		})
 		logInfo("failed to encode jwt token")
+		metrics.Logins.WithLabelValues(providerName, metrics.ResultFailure).Inc()
+		h.audit.LogRequest(req, reqURL, audit.Event{Type: audit.TypeLoginFailed, Actor: email, Provider: providerName, Reason: "failed to encode jwt token"})
 		return
 	}
+	if /* sessions enabled */ h.sessions != nil {
+		err := h.sessions.Create(session.Session{
+			ID:         c.SessionID,
+			Email:      email,
+			Provider:   providerName,
+			RemoteAddr: req.RemoteAddr,
+			CreatedAt:  token.IssuedAt(),
+			LastSeenAt: token.IssuedAt(),
+			ExpiresAt:  token.Expiration(),
+
+			ProviderToken: oauth2Token,
+			VerifiedAt:    token.IssuedAt(),
+		})
+		if err != nil {
+			slog.Error(fmt.Errorf("failed to create session: %w", err).Error())
+			h.errorPages.Write(res, req, origin, ui.ErrorPageProps{
+				StatusCode: http.StatusInternalServerError,
+				Message:    "failed to create session",
+				LoginURL:   "/.auth/login",
+			})
+			logInfo("failed to create session")
+			metrics.Logins.WithLabelValues(providerName, metrics.ResultFailure).Inc()
+			h.audit.LogRequest(req, reqURL, audit.Event{Type: audit.TypeLoginFailed, Actor: email, Provider: providerName, Reason: "failed to create session"})
+			return
+		}
+	}
 
-	h.cookie.SetJWT(res, tokenStr)
+	h.tokenIssuer.SetCookie(res, &reqURL, token, tokenStr)
+	metrics.Logins.WithLabelValues(providerName, metrics.ResultSuccess).Inc()
+	h.audit.LogRequest(req, reqURL, audit.Event{
+		Type:      audit.TypeLoginSucceeded,
+		Actor:     email,
+		Provider:  providerName,
+		SessionID: c.SessionID,
+		Details:   map[string]any{"roles": c.Roles},
+	})
 	cookieRedirectPath, err := req.Cookie(cookieutil.COOKIE_KEY_REDIRECT_URL_FOR_AFTER_LOGIN)
 	if /* cookie redirect url not received */ err != nil {
 		html := ui.ClientSideRedirect("/")
@@ -105,19 +167,15 @@ func (h *handler) Callback(rw http.ResponseWriter, req *http.Request) {
 	logInfo("signed-in", slog.Bool("cookie_redirect_url_found", true))
 }
 
-func clientSideRedirectConfirmErrorHTML(url string, cause string) string {
-	return fmt.Sprintf(`
-<!DOCTYPE html>
-<html lang="en">
-<head>
-    <meta charset="UTF-8">
-    <title>Error occured</title>
-</head>
-<body>
-		<font color="red">%s</font>
-		<br />
-    <p><a href="%s">login</a>.</p>
-</body>
-</html>
-`, cause, url)
+// selectEmail returns the primary email, or the secondary email granted the widest access to the origin.
+// A secondary email is selected only if its scopes strictly cover the scopes of the primary email, not to lose any access.
+func (h *handler) selectEmail(reqURL *url.URL, userInfo oauth2.UserInfo, groups []string) string {
+	selected, selectedScopes := userInfo.Email, h.acl.AllowedScopes(reqURL, userInfo.Email, groups...)
+	for _, email := range userInfo.SecondaryEmails {
+		scopes := h.acl.AllowedScopes(reqURL, email, groups...)
+		if scopes.Covers(selectedScopes) && !selectedScopes.Covers(scopes) {
+			selected, selectedScopes = email, scopes
+		}
+	}
+	return selected
 }
@@ -0,0 +1,100 @@
+package oauth2handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/tingtt/oauth2rbac/internal/acl"
+	handleroption "github.com/tingtt/oauth2rbac/internal/api/handler/util/option"
+	"github.com/tingtt/oauth2rbac/internal/oauth2"
+	"github.com/tingtt/oauth2rbac/pkg/jwtclaims"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	xoauth2 "golang.org/x/oauth2"
+)
+
+type MockOAuth2Service struct {
+	oauth2.Service
+	userInfo oauth2.UserInfo
+}
+
+func (m MockOAuth2Service) Type() string { return "github" }
+
+func (m MockOAuth2Service) Exchange(ctx context.Context, code string, redirectURL string, opts ...xoauth2.AuthCodeOption) (*xoauth2.Token, error) {
+	return &xoauth2.Token{AccessToken: "access-token"}, nil
+}
+
+func (m MockOAuth2Service) TokenSource(ctx context.Context, token *xoauth2.Token) xoauth2.TokenSource {
+	return xoauth2.StaticTokenSource(token)
+}
+
+func (m MockOAuth2Service) GetUserInfo(ctx context.Context, token *xoauth2.Token) (oauth2.UserInfo, error) {
+	return m.userInfo, nil
+}
+
+func Test_handler_Callback_secondaryEmails(t *testing.T) {
+	t.Parallel()
+
+	option, _ := handleroption.New(
+		handleroption.WithJWTAuth("secret"),
+		handleroption.WithACL(acl.Pool{
+			"http://example.com": {
+				PathScopes: map[acl.Path][]acl.ScopePath{
+					"/docs/":  {{EmailRegexes: []acl.EmailRegex{"primary@example.com", "partial@example.net", "wide@example.net", "widest@example.org"}, Methods: []acl.Method{"GET"}}},
+					"/blog/":  {{EmailRegexes: []acl.EmailRegex{"primary@example.com", "wide@example.net", "widest@example.org"}, Methods: []acl.Method{"GET"}}},
+					"/wiki/":  {{EmailRegexes: []acl.EmailRegex{"partial@example.net", "wide@example.net", "widest@example.org"}, Methods: []acl.Method{"GET"}}},
+					"/admin/": {{EmailRegexes: []acl.EmailRegex{"widest@example.org"}, Methods: []acl.Method{"GET"}}},
+				},
+			},
+		}),
+		handleroption.WithSecureCookie(false),
+	)
+
+	tests := []struct {
+		name            string
+		secondaryEmails []string
+		want            string
+	}{
+		{"primary email may be selected without secondary emails", nil, "primary@example.com"},
+		{"secondary email with overlapping scopes may not be selected", []string{"partial@example.net"}, "primary@example.com"},
+		{"secondary email strictly covering the primary email may be selected", []string{"partial@example.net", "wide@example.net"}, "wide@example.net"},
+		{"widest secondary email may be selected", []string{"wide@example.net", "widest@example.org"}, "widest@example.org"},
+		{"widest secondary email may be selected regardless of the order", []string{"widest@example.org", "wide@example.net"}, "widest@example.org"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			h := New(map[string]oauth2.Service{"github": MockOAuth2Service{userInfo: oauth2.UserInfo{
+				Username:        "user",
+				Email:           "primary@example.com",
+				SecondaryEmails: tt.secondaryEmails,
+			}}}, option)
+			rctx := chi.NewRouteContext()
+			rctx.URLParams.Add("oauthProvider", "github")
+			req := httptest.NewRequest(http.MethodGet, "http://example.com/.auth/github/callback?code=code", nil)
+			req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+			rec := httptest.NewRecorder()
+
+			h.Callback(rec, req)
+
+			assert.Equal(t, http.StatusOK, rec.Code)
+			var tokenStr string
+			for _, cookie := range rec.Result().Cookies() {
+				if cookie.Name == "jwt" {
+					tokenStr = cookie.Value
+				}
+			}
+			token, err := option.JWTAuth.Decode(tokenStr)
+			if !assert.NoError(t, err) {
+				return
+			}
+			claimsJSON, _ := json.Marshal(token.PrivateClaims())
+			claims, _ := jwtclaims.Unmarshal(claimsJSON)
+			assert.Equal(t, tt.want, claims.Email)
+		})
+	}
+}
@@ -2,20 +2,32 @@ package oauth2handler
 
 import (
 	"github.com/tingtt/oauth2rbac/internal/acl"
+	"github.com/tingtt/oauth2rbac/internal/api/handler/oauth2/ui"
 	cookieutil "github.com/tingtt/oauth2rbac/internal/api/handler/util/cookie"
+	identityutil "github.com/tingtt/oauth2rbac/internal/api/handler/util/identity"
 	handleroption "github.com/tingtt/oauth2rbac/internal/api/handler/util/option"
+	tokenutil "github.com/tingtt/oauth2rbac/internal/api/handler/util/token"
+	"github.com/tingtt/oauth2rbac/internal/audit"
 	"github.com/tingtt/oauth2rbac/internal/oauth2"
+	"github.com/tingtt/oauth2rbac/internal/serviceaccount"
+	"github.com/tingtt/oauth2rbac/internal/session"
 
 	"github.com/go-chi/jwtauth/v5"
 )
 
 type handler struct {
-	oauth2 map[string]oauth2.Service
-	jwt    *jwtauth.JWTAuth
-	acl    acl.Provider
-	cookie cookieutil.Controller
+	oauth2          map[string]oauth2.Service
+	jwt             *jwtauth.JWTAuth
+	tokenIssuer     *tokenutil.Issuer
+	acl             acl.Provider
+	cookie          cookieutil.Controller
+	errorPages      *ui.ErrorPages
+	serviceAccounts serviceaccount.Pool
+	sessions        session.Store
+	identity        *identityutil.Rechecker
+	audit           *audit.Logger
 }
 
 func New(oauth2 map[string]oauth2.Service, option *handleroption.Option) handler {
-	return handler{oauth2, option.JWTAuth, option.ACLProvider, option.CookieController}
+	return handler{oauth2, option.JWTAuth, tokenutil.NewIssuer(option), option.ACLProvider, option.CookieController, option.ErrorPages, option.ServiceAccounts, option.SessionStore, identityutil.NewRechecker(oauth2, option), option.AuditLogger}
 }
@@ -1,6 +1,7 @@
 package oauth2handler
 
 import (
+	"log/slog"
 	"net/http"
 
 	logutil "github.com/tingtt/oauth2rbac/internal/api/handler/util/log"
@@ -23,6 +24,7 @@ func (h *handler) Login(rw http.ResponseWriter, req *http.Request) {
 	}
 
 	callbackURL := reqURL.Scheme + "://" + reqURL.Host + "/.auth/" + providerName + "/callback"
-	http.Redirect(res, req, oauth2.AuthCodeURL(callbackURL), http.StatusTemporaryRedirect)
-	logInfo("")
+	reauthenticate := req.URL.Query().Get("prompt") == "login"
+	http.Redirect(res, req, oauth2.AuthCodeURL(callbackURL, reauthenticate), http.StatusTemporaryRedirect)
+	logInfo("", slog.Bool("reauthenticate", reauthenticate))
 }
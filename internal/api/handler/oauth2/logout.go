@@ -0,0 +1,39 @@
+package oauth2handler
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+
+	logutil "github.com/tingtt/oauth2rbac/internal/api/handler/util/log"
+	urlutil "github.com/tingtt/oauth2rbac/internal/api/handler/util/url"
+	jwtmiddleware "github.com/tingtt/oauth2rbac/internal/api/middleware/jwt"
+	"github.com/tingtt/oauth2rbac/pkg/jwtclaims"
+)
+
+func (h *handler) Logout(rw http.ResponseWriter, req *http.Request) {
+	reqURL := urlutil.RequestURL(*req.URL, urlutil.WithRequest(req), urlutil.WithXForwardedHeaders(req.Header))
+	res, logInfo := logutil.InfoLogger(reqURL, req.Method, rw, req)
+
+	if /* sessions enabled */ h.sessions != nil {
+		h.revokeSession(req)
+	}
+	h.cookie.DeleteJWT(res)
+	http.Redirect(res, req, "/.auth/login", http.StatusFound)
+	logInfo("signed-out")
+}
+
+func (h *handler) revokeSession(req *http.Request) {
+	token, err := h.jwt.Decode(jwtmiddleware.TokenFromRequest(req))
+	if err != nil {
+		return
+	}
+	claimsJSON, _ := json.Marshal(token.PrivateClaims())
+	claims, err := jwtclaims.Unmarshal(claimsJSON)
+	if err != nil || claims.SessionID == "" {
+		return
+	}
+	if err := h.sessions.Revoke(claims.SessionID); err != nil {
+		slog.Debug("failed to revoke session", slog.String("sid", claims.SessionID), slog.String("err", err.Error()))
+	}
+}
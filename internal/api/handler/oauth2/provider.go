@@ -14,7 +14,11 @@ func (h *handler) SelectProvider(rw http.ResponseWriter, req *http.Request) {
 	reqURL := urlutil.RequestURL(*req.URL, urlutil.WithRequest(req), urlutil.WithXForwardedHeaders(req.Header))
 	res, logInfo := logutil.InfoLogger(reqURL, req.Method, rw, req)
 
-	html := ui.ProviderListUI(req.URL.RawQuery)
+	providers := make([]ui.Provider, 0, len(h.oauth2))
+	for name, service := range h.oauth2 {
+		providers = append(providers, ui.NewProvider(name, service.DisplayName(), service.Icon()))
+	}
+	html := ui.ProviderListUI(req.URL.RawQuery, providers)
 	err := html.Render(res)
 	if err != nil {
 		slog.Error(fmt.Errorf("failed render html: %w", err).Error())
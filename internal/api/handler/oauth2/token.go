@@ -0,0 +1,107 @@
+package oauth2handler
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"time"
+
+	logutil "github.com/tingtt/oauth2rbac/internal/api/handler/util/log"
+	urlutil "github.com/tingtt/oauth2rbac/internal/api/handler/util/url"
+	"github.com/tingtt/oauth2rbac/internal/audit"
+	"github.com/tingtt/oauth2rbac/internal/serviceaccount"
+	"github.com/tingtt/oauth2rbac/pkg/jwtclaims"
+)
+
+// grantTypeClientCredentials is also the provider of service accounts in audit logs.
+const grantTypeClientCredentials = "client_credentials"
+
+type tokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int64  `json:"expires_in"`
+}
+
+type tokenErrorResponse struct {
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description,omitempty"`
+}
+
+// Token issues JWT for service accounts with the OAuth2 client credentials grant. (RFC 6749 section 4.4)
+func (h *handler) Token(rw http.ResponseWriter, req *http.Request) {
+	reqURL := urlutil.RequestURL(*req.URL, urlutil.WithRequest(req), urlutil.WithXForwardedHeaders(req.Header))
+	res, logInfo := logutil.InfoLogger(reqURL, req.Method, rw, req)
+
+	if err := req.ParseForm(); err != nil {
+		writeTokenError(res, http.StatusBadRequest, "invalid_request", "failed to parse form")
+		logInfo("invalid request", slog.String("err", err.Error()))
+		return
+	}
+	if grantType := req.PostForm.Get("grant_type"); grantType != grantTypeClientCredentials {
+		writeTokenError(res, http.StatusBadRequest, "unsupported_grant_type", "")
+		logInfo("unsupported grant type", slog.String("grant_type", grantType))
+		return
+	}
+
+	clientID, clientSecret, basicAuth := req.BasicAuth()
+	if !basicAuth {
+		clientID, clientSecret = req.PostForm.Get("client_id"), req.PostForm.Get("client_secret")
+	}
+	if !h.serviceAccounts.Authenticate(clientID, clientSecret) {
+		res.Header().Set("WWW-Authenticate", `Basic realm="oauth2rbac"`)
+		writeTokenError(res, http.StatusUnauthorized, "invalid_client", "")
+		h.audit.LogRequest(req, reqURL, audit.Event{
+			Type:     audit.TypeLoginFailed,
+			Actor:    serviceaccount.Subject(clientID),
+			Provider: grantTypeClientCredentials,
+			Reason:   "invalid client",
+		})
+		logInfo("invalid client", slog.String("client_id", clientID))
+		return
+	}
+
+	subject := serviceaccount.Subject(clientID)
+	c := jwtclaims.Claims{
+		AllowedScopes:  h.acl.AllowedScopes(&reqURL, subject),
+		Email:          subject,
+		Roles:          h.acl.Roles(&reqURL, subject),
+		AuthTime:       time.Now().Unix(),
+		ServiceAccount: &jwtclaims.ClaimsServiceAccount{ClientID: clientID},
+	}
+	token, tokenStr, err := h.tokenIssuer.Issue(&reqURL, c)
+	if err != nil {
+		slog.Error(err.Error())
+		writeTokenError(res, http.StatusInternalServerError, "server_error", "")
+		h.audit.LogRequest(req, reqURL, audit.Event{
+			Type:     audit.TypeLoginFailed,
+			Actor:    subject,
+			Provider: grantTypeClientCredentials,
+			Reason:   "failed to encode jwt token",
+		})
+		logInfo("failed to encode jwt token")
+		return
+	}
+
+	h.audit.LogRequest(req, reqURL, audit.Event{
+		Type:     audit.TypeLoginSucceeded,
+		Actor:    subject,
+		Provider: grantTypeClientCredentials,
+		Details:  map[string]any{"roles": c.Roles},
+	})
+	res.Header().Set("Cache-Control", "no-store")
+	res.Header().Set("Content-Type", "application/json; charset=utf-8")
+	res.WriteHeader(http.StatusOK)
+	json.NewEncoder(res).Encode(tokenResponse{
+		AccessToken: tokenStr,
+		TokenType:   "Bearer",
+		ExpiresIn:   int64(token.Expiration().Sub(token.IssuedAt()) / time.Second),
+	})
+	logInfo("token issued", slog.String("client_id", clientID))
+}
+
+func writeTokenError(res http.ResponseWriter, statusCode int, errorCode, description string) {
+	res.Header().Set("Cache-Control", "no-store")
+	res.Header().Set("Content-Type", "application/json; charset=utf-8")
+	res.WriteHeader(statusCode)
+	json.NewEncoder(res).Encode(tokenErrorResponse{errorCode, description})
+}
@@ -0,0 +1,154 @@
+package oauth2handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/tingtt/oauth2rbac/internal/acl"
+	handleroption "github.com/tingtt/oauth2rbac/internal/api/handler/util/option"
+	"github.com/tingtt/oauth2rbac/internal/audit"
+	"github.com/tingtt/oauth2rbac/internal/serviceaccount"
+	"github.com/tingtt/oauth2rbac/pkg/jwtclaims"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_handler_Token(t *testing.T) {
+	t.Parallel()
+
+	option, _ := handleroption.New(
+		handleroption.WithJWTAuth("secret"),
+		handleroption.WithACL(acl.Pool{
+			"http://example.com": {
+				PathScopes: map[acl.Path][]acl.ScopePath{
+					"/api/": {{EmailRegexes: []acl.EmailRegex{"serviceaccount:ci"}, Methods: []acl.Method{"GET"}}},
+				},
+				Roles: map[string][]acl.EmailRegex{"deployer": {"serviceaccount:ci"}},
+			},
+		}),
+		handleroption.WithSecureCookie(false),
+		handleroption.WithServiceAccounts(serviceaccount.Pool{
+			// sha256("secret")
+			"ci": {ClientSecretHash: "sha256:2bb80d537b1da3e38bd30361aa855686bde0eacd7162fef6a25fe97bf527a25b"},
+		}),
+	)
+	h := New(nil, option)
+
+	newRequest := func(form url.Values) *http.Request {
+		req := httptest.NewRequest(http.MethodPost, "http://example.com/.auth/token", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		return req
+	}
+
+	t.Run("may issue JWT with client credentials", func(t *testing.T) {
+		t.Parallel()
+		req := newRequest(url.Values{"grant_type": {"client_credentials"}})
+		req.SetBasicAuth("ci", "secret")
+		rec := httptest.NewRecorder()
+
+		h.Token(rec, req)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		var got tokenResponse
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &got))
+		assert.Equal(t, "Bearer", got.TokenType)
+
+		token, err := option.JWTAuth.Decode(got.AccessToken)
+		assert.NoError(t, err)
+		claimsJSON, _ := json.Marshal(token.PrivateClaims())
+		claims, _ := jwtclaims.Unmarshal(claimsJSON)
+		assert.Equal(t, "serviceaccount:ci", claims.Email)
+		assert.Equal(t, []string{"http://example.com"}, token.Audience())
+		assert.Equal(t, []string{"deployer"}, claims.Roles)
+		assert.True(t, claims.AllowedScopes.Match("/api/users", http.MethodGet))
+		assert.Equal(t, &jwtclaims.ClaimsServiceAccount{ClientID: "ci"}, claims.ServiceAccount)
+	})
+
+	t.Run("may accept client credentials in the form", func(t *testing.T) {
+		t.Parallel()
+		req := newRequest(url.Values{"grant_type": {"client_credentials"}, "client_id": {"ci"}, "client_secret": {"secret"}})
+		rec := httptest.NewRecorder()
+
+		h.Token(rec, req)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+	})
+
+	t.Run("may reject invalid client", func(t *testing.T) {
+		t.Parallel()
+		req := newRequest(url.Values{"grant_type": {"client_credentials"}})
+		req.SetBasicAuth("ci", "wrong")
+		rec := httptest.NewRecorder()
+
+		h.Token(rec, req)
+
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+		assert.JSONEq(t, `{"error":"invalid_client"}`, rec.Body.String())
+	})
+
+	t.Run("may reject unsupported grant type", func(t *testing.T) {
+		t.Parallel()
+		req := newRequest(url.Values{"grant_type": {"password"}})
+		rec := httptest.NewRecorder()
+
+		h.Token(rec, req)
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.JSONEq(t, `{"error":"unsupported_grant_type"}`, rec.Body.String())
+	})
+}
+
+func Test_handler_Token_audit(t *testing.T) {
+	t.Parallel()
+
+	auditLogPath := t.TempDir() + "/audit.log"
+	sink, _ := audit.NewFileSink(auditLogPath, 0, 0)
+	auditLogger := audit.New(sink)
+	option, _ := handleroption.New(
+		handleroption.WithJWTAuth("secret"),
+		handleroption.WithACL(acl.Pool{
+			"http://example.com": {
+				PathScopes: map[acl.Path][]acl.ScopePath{
+					"/api/": {{EmailRegexes: []acl.EmailRegex{"serviceaccount:ci"}, Methods: []acl.Method{"GET"}}},
+				},
+			},
+		}),
+		handleroption.WithSecureCookie(false),
+		handleroption.WithServiceAccounts(serviceaccount.Pool{
+			// sha256("secret")
+			"ci": {ClientSecretHash: "sha256:2bb80d537b1da3e38bd30361aa855686bde0eacd7162fef6a25fe97bf527a25b"},
+		}),
+		handleroption.WithAuditLogger(auditLogger),
+	)
+	h := New(nil, option)
+
+	for _, clientSecret := range []string{"wrong", "secret"} {
+		form := url.Values{"grant_type": {"client_credentials"}}
+		req := httptest.NewRequest(http.MethodPost, "http://example.com/.auth/token", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.SetBasicAuth("ci", clientSecret)
+		h.Token(httptest.NewRecorder(), req)
+	}
+	assert.NoError(t, auditLogger.Close(context.Background()))
+
+	data, _ := os.ReadFile(auditLogPath)
+	lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	if assert.Len(t, lines, 2) {
+		var failed, succeeded audit.Event
+		assert.NoError(t, json.Unmarshal([]byte(lines[0]), &failed))
+		assert.NoError(t, json.Unmarshal([]byte(lines[1]), &succeeded))
+		assert.Equal(t, audit.TypeLoginFailed, failed.Type)
+		assert.Equal(t, "serviceaccount:ci", failed.Actor)
+		assert.Equal(t, "client_credentials", failed.Provider)
+		assert.Equal(t, "invalid client", failed.Reason)
+		assert.Equal(t, audit.TypeLoginSucceeded, succeeded.Type)
+		assert.Equal(t, "serviceaccount:ci", succeeded.Actor)
+		assert.Equal(t, "client_credentials", succeeded.Provider)
+	}
+}
@@ -0,0 +1,113 @@
+package ui
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/tingtt/oauth2rbac/internal/apitoken"
+
+	"github.com/lithammer/dedent"
+	"maragu.dev/gomponents"
+	"maragu.dev/gomponents/html"
+)
+
+type APITokensPageProps struct {
+	Email   string
+	Tokens  []apitoken.Token
+	Origins []string
+	// NewToken is the token string just created. It is shown only once.
+	NewToken string
+	Error    string
+}
+
+func APITokensPage(props APITokensPageProps) gomponents.Node {
+	return layoutWithTitle("API tokens - ", html.Div(
+		html.Style(dedent.Dedent(`
+			max-width: 720px;
+			margin: 40px auto;
+			background: var(--base);
+			border-radius: 16px;
+			padding: 20px 32px;
+		`)),
+		html.StyleEl(gomponents.Text(dedent.Dedent(`
+			table { width: 100%; border-collapse: collapse; }
+			th, td { text-align: left; padding: 8px 4px; border-bottom: 1px solid var(--background); vertical-align: top; }
+			form.create { display: grid; gap: 8px; }
+			input, select, button { padding: 8px; border-radius: 8px; border: 1px solid var(--background); }
+			code { word-break: break-all; }
+		`))),
+		html.Div(
+			html.Style("margin: 20px 4px; font-size: 2rem; font-weight: bold;"),
+			gomponents.Text("API tokens"),
+		),
+		html.P(
+			gomponents.Text("Signed in as "),
+			html.Strong(gomponents.Text(props.Email)),
+			gomponents.Text(" ("),
+			html.A(html.Href("/.auth/logout"), gomponents.Text("Sign out")),
+			gomponents.Text(")"),
+		),
+		gomponents.If(props.Error != "",
+			html.P(html.Style("color: red;"), gomponents.Text(props.Error)),
+		),
+		gomponents.If(props.NewToken != "",
+			html.Div(
+				html.P(gomponents.Text("Copy the new token now. It will not be shown again.")),
+				html.P(html.Code(gomponents.Text(props.NewToken))),
+			),
+		),
+		html.Table(
+			html.THead(html.Tr(
+				html.Th(gomponents.Text("Name")),
+				html.Th(gomponents.Text("Scopes")),
+				html.Th(gomponents.Text("Expires")),
+				html.Th(),
+			)),
+			html.TBody(gomponents.Map(props.Tokens, func(token apitoken.Token) gomponents.Node {
+				expires := "never"
+				if token.ExpiresAt != nil {
+					expires = token.ExpiresAt.Format("2006-01-02")
+				}
+				return html.Tr(
+					html.Td(gomponents.Text(token.Name)),
+					html.Td(gomponents.Map(scopeLines(token), func(line string) gomponents.Node {
+						return html.Div(html.Code(gomponents.Text(line)))
+					})),
+					html.Td(gomponents.Text(expires)),
+					html.Td(html.Form(
+						html.Method("post"),
+						html.Action(fmt.Sprintf("/.auth/tokens/%s/revoke", token.ID)),
+						html.Button(html.Type("submit"), gomponents.Text("Revoke")),
+					)),
+				)
+			})),
+		),
+		html.H3(gomponents.Text("New token")),
+		html.Form(
+			html.Class("create"),
+			html.Method("post"),
+			html.Action("/.auth/tokens"),
+			html.Input(html.Name("name"), html.Placeholder("Name (e.g. ci-deploy)"), html.Required()),
+			html.Select(html.Name("origin"), gomponents.Map(props.Origins, func(origin string) gomponents.Node {
+				return html.Option(html.Value(origin), gomponents.Text(origin))
+			})),
+			html.Input(html.Name("path"), html.Placeholder("Path (e.g. /api/)"), html.Value("/"), html.Required()),
+			html.Input(html.Name("methods"), html.Placeholder("Methods (e.g. GET, POST)"), html.Value("GET"), html.Required()),
+			html.Input(html.Name("expires_in_days"), html.Type("number"), html.Min("0"), html.Value("30"),
+				html.Title("Days until expiry (0: never)")),
+			html.Button(html.Type("submit"), gomponents.Text("Create")),
+		),
+	))
+}
+
+func scopeLines(token apitoken.Token) []string {
+	lines := []string{}
+	for origin, scopes := range token.Scopes {
+		for path, methods := range scopes {
+			lines = append(lines, fmt.Sprintf("%s %s%s", strings.Join(methods, ","), origin, path))
+		}
+	}
+	slices.Sort(lines)
+	return lines
+}
@@ -0,0 +1,17 @@
+package assets
+
+import (
+	"github.com/lithammer/dedent"
+	"maragu.dev/gomponents"
+)
+
+func SVGBitbucket(width, height int) gomponents.Node {
+	return gomponents.Rawf(dedent.Dedent(`
+		<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 24 24">
+			<path
+				fill="#0052CC"
+				d="M.778 1.213a.768.768 0 0 0-.768.892l3.263 19.81c.084.5.515.868 1.022.873H19.95a.772.772 0 0 0 .77-.646l3.27-20.03a.768.768 0 0 0-.768-.891zM14.52 15.53H9.522L8.17 8.466h7.561z"
+			/>
+		</svg>
+	`), width, height)
+}
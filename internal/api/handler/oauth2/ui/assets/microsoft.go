@@ -0,0 +1,17 @@
+package assets
+
+import (
+	"github.com/lithammer/dedent"
+	"maragu.dev/gomponents"
+)
+
+func SVGMicrosoft(width, height int) gomponents.Node {
+	return gomponents.Rawf(dedent.Dedent(`
+		<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 24 24">
+			<path fill="#F25022" d="M1 1h10.5v10.5H1z"/>
+			<path fill="#7FBA00" d="M12.5 1H23v10.5H12.5z"/>
+			<path fill="#00A4EF" d="M1 12.5h10.5V23H1z"/>
+			<path fill="#FFB900" d="M12.5 12.5H23V23H12.5z"/>
+		</svg>
+	`), width, height)
+}
@@ -0,0 +1,93 @@
+package ui
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/lithammer/dedent"
+	"maragu.dev/gomponents"
+	"maragu.dev/gomponents/html"
+)
+
+// ErrorPageProps is the content of an error response.
+// It is also passed to custom error page templates and encoded as JSON body.
+type ErrorPageProps struct {
+	StatusCode int    `json:"status"`
+	Title      string `json:"error"`
+	Message    string `json:"message,omitempty"`
+	Email      string `json:"email,omitempty"`
+	Method     string `json:"method,omitempty"`
+	URL        string `json:"url,omitempty"`
+	LoginURL   string `json:"login_url,omitempty"`
+	LogoutURL  string `json:"logout_url,omitempty"`
+}
+
+func ErrorPage(props ErrorPageProps) gomponents.Node {
+	return layoutWithTitle(fmt.Sprintf("%d %s - ", props.StatusCode, props.Title), html.Div(
+		html.Style(dedent.Dedent(`
+			max-width: 480px;
+			margin: 40px auto;
+			background: var(--base);
+			border-radius: 16px;
+			padding: 20px 32px;
+		`)),
+		html.StyleEl(gomponents.Text(dedent.Dedent(`
+			.errorLinkButton {
+				display: block;
+				background: var(--background);
+				color: var(--foreground);
+				border-radius: 8px;
+				padding: 12px 16px;
+				text-decoration: none;
+			}
+			.errorLinkButton:hover {
+				outline: 1px solid var(--foreground);
+			}
+		`))),
+		html.Div(
+			html.Style(dedent.Dedent(`
+				margin: 20px 4px;
+				font-size: 2rem;
+				font-weight: bold;
+			`)),
+			gomponents.Text(strconv.Itoa(props.StatusCode)+" "+props.Title),
+		),
+		gomponents.If(props.Message != "",
+			html.P(gomponents.Text(props.Message)),
+		),
+		gomponents.If(props.URL != "",
+			html.P(
+				gomponents.Text("Requested: "),
+				html.Code(gomponents.Text(props.Method+" "+props.URL)),
+			),
+		),
+		gomponents.If(props.Email != "",
+			html.P(
+				gomponents.Text("Signed in as "),
+				html.Strong(gomponents.Text(props.Email)),
+			),
+		),
+		html.Div(
+			html.Style(dedent.Dedent(`
+				display: grid;
+				gap: 16px;
+				margin: 20px 0;
+			`)),
+			gomponents.If(props.LoginURL != "",
+				html.A(
+					html.Class("errorLinkButton"),
+					html.Href(props.LoginURL),
+					gomponents.If(props.Email != "", gomponents.Text("Sign in with another account")),
+					gomponents.If(props.Email == "", gomponents.Text("Sign in")),
+				),
+			),
+			gomponents.If(props.Email != "" && props.LogoutURL != "",
+				html.A(
+					html.Class("errorLinkButton"),
+					html.Href(props.LogoutURL),
+					gomponents.Text("Sign out"),
+				),
+			),
+		),
+	))
+}
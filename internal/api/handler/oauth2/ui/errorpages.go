@@ -0,0 +1,93 @@
+package ui
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/tingtt/oauth2rbac/internal/acl"
+	negotiateutil "github.com/tingtt/oauth2rbac/internal/api/handler/util/negotiate"
+)
+
+// ErrorPages writes error responses.
+// It responds JSON to API/XHR clients, otherwise renders the HTML error page
+// (or the custom template configured for the origin).
+type ErrorPages struct {
+	templates map[ /* origin */ string]map[ /* status code */ int]*template.Template
+}
+
+// LoadErrorPages parses the custom error page templates configured in `error_pages` of each origin.
+func LoadErrorPages(pool acl.Pool) (*ErrorPages, error) {
+	templates := map[string]map[int]*template.Template{}
+	for origin, scope := range pool {
+		if len(scope.ErrorPages) == 0 {
+			continue
+		}
+		sanitizedOrigin, _ := strings.CutSuffix(origin, "/")
+		templates[sanitizedOrigin] = make(map[int]*template.Template, len(scope.ErrorPages))
+		for statusCode, filePath := range scope.ErrorPages {
+			if http.StatusText(statusCode) == "" {
+				return nil, fmt.Errorf("error page for origin `%s`: invalid status code %d", origin, statusCode)
+			}
+			tmpl, err := template.ParseFiles(filePath)
+			if err != nil {
+				return nil, fmt.Errorf("error page for origin `%s`: %w", origin, err)
+			}
+			templates[sanitizedOrigin][statusCode] = tmpl
+		}
+	}
+	return &ErrorPages{templates}, nil
+}
+
+// Write writes the error response. It is safe to call on a nil *ErrorPages.
+func (p *ErrorPages) Write(rw http.ResponseWriter, req *http.Request, origin string, props ErrorPageProps) {
+	if props.Title == "" {
+		props.Title = http.StatusText(props.StatusCode)
+	}
+
+	if negotiateutil.WantsJSON(req) {
+		p.WriteJSON(rw, props)
+		return
+	}
+
+	rw.Header().Set("Cache-Control", "no-store")
+	rw.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if tmpl := p.customTemplate(origin, props.StatusCode); tmpl != nil {
+		buf := new(bytes.Buffer)
+		err := tmpl.Execute(buf, props)
+		if err == nil {
+			rw.WriteHeader(props.StatusCode)
+			rw.Write(buf.Bytes())
+			return
+		}
+		slog.Error(fmt.Errorf("failed to render custom error page: %w", err).Error(), slog.String("origin", origin))
+	}
+	rw.WriteHeader(props.StatusCode)
+	if err := ErrorPage(props).Render(rw); err != nil {
+		slog.Error(fmt.Errorf("failed render html: %w", err).Error())
+	}
+}
+
+// WriteJSON writes the error response as JSON regardless of the request. It is safe to call on a nil *ErrorPages.
+func (p *ErrorPages) WriteJSON(rw http.ResponseWriter, props ErrorPageProps) {
+	if props.Title == "" {
+		props.Title = http.StatusText(props.StatusCode)
+	}
+	rw.Header().Set("Cache-Control", "no-store")
+	rw.Header().Set("Content-Type", "application/json; charset=utf-8")
+	rw.WriteHeader(props.StatusCode)
+	if err := json.NewEncoder(rw).Encode(props); err != nil {
+		slog.Error(fmt.Errorf("failed to encode error response: %w", err).Error())
+	}
+}
+
+func (p *ErrorPages) customTemplate(origin string, statusCode int) *template.Template {
+	if p == nil {
+		return nil
+	}
+	return p.templates[origin][statusCode]
+}
@@ -0,0 +1,78 @@
+package ui
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/tingtt/oauth2rbac/internal/acl"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestErrorPages_Write(t *testing.T) {
+	t.Parallel()
+
+	tmpdir := t.TempDir()
+	os.WriteFile(tmpdir+"/403.html", []byte(`<p>{{.StatusCode}} {{.Email}}</p>`), 0644)
+	errorPages, err := LoadErrorPages(acl.Pool{
+		"https://custom.example.com/": {OriginConfig: acl.OriginConfig{
+			ErrorPages: map[int]string{http.StatusForbidden: tmpdir + "/403.html"},
+		}},
+	})
+	assert.NoError(t, err)
+
+	props := ErrorPageProps{StatusCode: http.StatusForbidden, Email: "user@example.com"}
+
+	t.Run("may respond JSON to API clients", func(t *testing.T) {
+		t.Parallel()
+		req := httptest.NewRequest(http.MethodGet, "https://example.com/", nil)
+		req.Header.Set("Accept", "application/json")
+		rec := httptest.NewRecorder()
+
+		errorPages.Write(rec, req, "https://example.com", props)
+
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+		assert.Equal(t, "application/json; charset=utf-8", rec.Header().Get("Content-Type"))
+		var got ErrorPageProps
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &got))
+		assert.Equal(t, "Forbidden", got.Title)
+		assert.Equal(t, "user@example.com", got.Email)
+	})
+
+	t.Run("may render default HTML page", func(t *testing.T) {
+		t.Parallel()
+		req := httptest.NewRequest(http.MethodGet, "https://example.com/", nil)
+		rec := httptest.NewRecorder()
+
+		errorPages.Write(rec, req, "https://example.com", props)
+
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+		assert.Equal(t, "text/html; charset=utf-8", rec.Header().Get("Content-Type"))
+		assert.Contains(t, rec.Body.String(), "user@example.com")
+	})
+
+	t.Run("may render custom page of the origin", func(t *testing.T) {
+		t.Parallel()
+		req := httptest.NewRequest(http.MethodGet, "https://custom.example.com/", nil)
+		rec := httptest.NewRecorder()
+
+		errorPages.Write(rec, req, "https://custom.example.com", props)
+
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+		assert.Equal(t, "<p>403 user@example.com</p>", rec.Body.String())
+	})
+
+	t.Run("nil ErrorPages may render default HTML page", func(t *testing.T) {
+		t.Parallel()
+		req := httptest.NewRequest(http.MethodGet, "https://custom.example.com/", nil)
+		rec := httptest.NewRecorder()
+
+		(*ErrorPages)(nil).Write(rec, req, "https://custom.example.com", props)
+
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+		assert.Contains(t, rec.Body.String(), "<!doctype html>")
+	})
+}
@@ -1,12 +1,20 @@
 package ui
 
 import (
+	"fmt"
+	"strconv"
+
 	"github.com/lithammer/dedent"
 	"maragu.dev/gomponents"
 	"maragu.dev/gomponents/html"
 )
 
 func layout(child gomponents.Node) gomponents.Node {
+	return layoutWithTitle("Sign in to ", child)
+}
+
+// layoutWithTitle renders the page titled with the prefix followed by the host.
+func layoutWithTitle(titlePrefix string, child gomponents.Node) gomponents.Node {
 	return html.Doctype(html.HTML(
 		html.StyleEl(gomponents.Text(dedent.Dedent(`
 			:root {
@@ -32,10 +40,10 @@ func layout(child gomponents.Node) gomponents.Node {
 				html.ID("title"),
 			),
 			html.Script(
-				gomponents.Raw(dedent.Dedent(`
-					const title = "Sign in to " + location.host;
+				gomponents.Raw(fmt.Sprintf(dedent.Dedent(`
+					const title = %s + location.host;
 					document.getElementById("title").innerText = title;
-				`)),
+				`), strconv.Quote(titlePrefix))),
 			),
 		),
 		html.Body(
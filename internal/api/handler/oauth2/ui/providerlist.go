@@ -3,16 +3,19 @@ package ui
 import (
 	"fmt"
 	"slices"
+	"strconv"
+	"strings"
 
 	"github.com/tingtt/oauth2rbac/internal/api/handler/oauth2/ui/assets"
-	"github.com/tingtt/oauth2rbac/internal/oauth2"
 
 	"github.com/lithammer/dedent"
 	"maragu.dev/gomponents"
 	"maragu.dev/gomponents/html"
 )
 
-func ProviderListUI(rawQuery string) gomponents.Node {
+// ProviderListUI renders the sign-in buttons of the configured providers.
+func ProviderListUI(rawQuery string, providers []Provider) gomponents.Node {
+	providers = slices.SortedFunc(slices.Values(providers), func(a, b Provider) int { return strings.Compare(a.Name, b.Name) })
 	return layout(html.Div(
 		html.Style(dedent.Dedent(`
 			max-width: 320px;
@@ -58,7 +61,7 @@ func ProviderListUI(rawQuery string) gomponents.Node {
 					outline: 1px solid var(--foreground);
 				}
 			`))),
-			gomponents.Map(providerNamesWithDisplayName(),
+			gomponents.Map(providers,
 				func(provider Provider) gomponents.Node {
 					url := fmt.Sprintf("/.auth/%s/login?%s", provider.Name, rawQuery)
 					return html.Div(html.A(
@@ -99,24 +102,21 @@ type Provider struct {
 	Icon              IconFunc
 }
 
-func providerNamesWithDisplayName() []Provider {
-	providerNames := oauth2.ProviderNames()
-	slices.Sort(providerNames)
-	providerNamesWithDisplayName := make([]Provider, 0, len(providerNames))
-	for i, providerName := range providerNames {
-		provider := oauth2.Providers[providerName]
-		providerNamesWithDisplayName = append(providerNamesWithDisplayName, Provider{
-			Name:        providerName,
-			DisplayName: provider.DisplayName,
-		})
-		if iconFunc, ok := ProviderIcons[providerName]; ok {
-			providerNamesWithDisplayName[i].Icon = iconFunc
+// NewProvider returns the provider with the icon of ProviderIcons, or the image of the URL if icon is not one of them.
+func NewProvider(name, displayName, icon string) Provider {
+	iconFunc, ok := ProviderIcons[icon]
+	if !ok {
+		iconFunc = func(width, height int) gomponents.Node {
+			return html.Img(html.Src(icon), html.Alt(""), html.Width(strconv.Itoa(width)), html.Height(strconv.Itoa(height)))
 		}
 	}
-	return providerNamesWithDisplayName
+	return Provider{Name: name, DisplayName: displayName, Icon: iconFunc}
 }
 
 var ProviderIcons = map[string]IconFunc{
-	"github": assets.SVGGitHub,
-	"google": assets.SVGGoogle,
+	"github":    assets.SVGGitHub,
+	"google":    assets.SVGGoogle,
+	"gitlab":    assets.SVGGitLab,
+	"entra":     assets.SVGMicrosoft,
+	"bitbucket": assets.SVGBitbucket,
 }
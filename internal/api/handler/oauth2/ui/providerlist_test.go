@@ -2,18 +2,31 @@ package ui
 
 import (
 	"testing"
+
+	"github.com/tingtt/oauth2rbac/internal/oauth2"
 )
 
-func Test_providerNamesWithDisplayName(t *testing.T) {
+func TestNewProvider(t *testing.T) {
 	t.Parallel()
 
 	t.Run("Icon is not nil", func(t *testing.T) {
 		t.Parallel()
-		got := providerNamesWithDisplayName()
-		for _, provider := range got {
+		for _, providerType := range oauth2.ProviderNames() {
+			if _, ok := ProviderIcons[providerType]; !ok {
+				t.Errorf("icon of provider type is not found (provider: %v)", providerType)
+			}
+			provider := NewProvider(providerType, oauth2.Providers[providerType].DisplayName, providerType)
 			if provider.Icon == nil {
 				t.Errorf("provider.Icon is nil (provider: %v)", provider.Name)
 			}
 		}
 	})
+
+	t.Run("Icon may be image URL", func(t *testing.T) {
+		t.Parallel()
+		provider := NewProvider("corp", "Corp", "https://example.com/icon.png")
+		if provider.Icon == nil {
+			t.Fatal("provider.Icon is nil")
+		}
+	})
 }
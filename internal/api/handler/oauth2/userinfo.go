@@ -0,0 +1,113 @@
+package oauth2handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/tingtt/oauth2rbac/internal/api/handler/oauth2/ui"
+	corsutil "github.com/tingtt/oauth2rbac/internal/api/handler/util/cors"
+	logutil "github.com/tingtt/oauth2rbac/internal/api/handler/util/log"
+	urlutil "github.com/tingtt/oauth2rbac/internal/api/handler/util/url"
+	jwtmiddleware "github.com/tingtt/oauth2rbac/internal/api/middleware/jwt"
+	"github.com/tingtt/oauth2rbac/internal/session"
+	"github.com/tingtt/oauth2rbac/pkg/jwtclaims"
+)
+
+type userInfo struct {
+	jwtclaims.Claims
+	IssuedAt  int64 `json:"iat"`
+	ExpiresAt int64 `json:"exp"`
+}
+
+func (h *handler) UserInfo(rw http.ResponseWriter, req *http.Request) {
+	reqURL := urlutil.RequestURL(*req.URL, urlutil.WithRequest(req), urlutil.WithXForwardedHeaders(req.Header))
+	res, logInfo := logutil.InfoLogger(reqURL, req.Method, rw, req)
+	origin := reqURL.Scheme + "://" + reqURL.Host
+
+	originConfig := h.acl.OriginConfig(&reqURL)
+	var corsAllowedOrigins []string
+	if originConfig != nil {
+		corsAllowedOrigins = originConfig.CORSAllowedOrigins
+	}
+	if /* preflight */ corsutil.SetHeaders(res, req, corsAllowedOrigins, http.MethodGet) {
+		res.WriteHeader(http.StatusNoContent)
+		logInfo("preflight")
+		return
+	}
+
+	token, err := h.jwt.Decode(jwtmiddleware.TokenFromRequest(req))
+	if /* unauthorized or token expired */ err != nil {
+		res.Header().Set("WWW-Authenticate", fmt.Sprintf("Bearer realm=%q", origin))
+		h.errorPages.WriteJSON(res, ui.ErrorPageProps{
+			StatusCode: http.StatusUnauthorized,
+			Message:    "Sign in required.",
+			LoginURL:   origin + "/.auth/login",
+		})
+		logInfo("unauthorized", slog.String("reason", err.Error()))
+		return
+	}
+
+	claimsJSON, _ := json.Marshal(token.PrivateClaims())
+	claims, err := jwtclaims.Unmarshal(claimsJSON)
+	if err != nil {
+		h.errorPages.WriteJSON(res, ui.ErrorPageProps{StatusCode: http.StatusInternalServerError})
+		logInfo("internal error", slog.String("err", err.Error()))
+		slog.Error("failed to unmarshal token claims", slog.String("err", err.Error()))
+		return
+	}
+	if /* session revoked */ err := session.Check(h.sessions, claims); err != nil {
+		res.Header().Set("WWW-Authenticate", fmt.Sprintf("Bearer realm=%q, error=\"invalid_token\"", origin))
+		h.errorPages.WriteJSON(res, ui.ErrorPageProps{
+			StatusCode: http.StatusUnauthorized,
+			Message:    "Sign in required.",
+			LoginURL:   origin + "/.auth/login",
+		})
+		logInfo("unauthorized", slog.String("reason", err.Error()))
+		return
+	}
+	if /* identity lost */ err := h.identity.Recheck(req.Context(), claims); err != nil {
+		res.Header().Set("WWW-Authenticate", fmt.Sprintf("Bearer realm=%q, error=\"invalid_token\"", origin))
+		h.errorPages.WriteJSON(res, ui.ErrorPageProps{
+			StatusCode: http.StatusUnauthorized,
+			Message:    "Sign in required.",
+			LoginURL:   origin + "/.auth/login",
+		})
+		logInfo("unauthorized", slog.String("reason", "identity recheck failed: "+err.Error()))
+		return
+	}
+	if maxAuthAge, ok := originConfig.MaxAuthAge(reqURL.Path); ok {
+		if /* signed in too long ago */ time.Since(time.Unix(claims.AuthTime, 0)) > maxAuthAge {
+			// RFC 9470 (OAuth 2.0 Step Up Authentication Challenge Protocol)
+			res.Header().Set("WWW-Authenticate", fmt.Sprintf(
+				`Bearer realm=%q, error="insufficient_user_authentication", max_age=%d`,
+				origin, int64(maxAuthAge.Seconds()),
+			))
+			h.errorPages.WriteJSON(res, ui.ErrorPageProps{
+				StatusCode: http.StatusUnauthorized,
+				Message:    "Sign in again required.",
+				LoginURL:   origin + "/.auth/login?prompt=login",
+			})
+			logInfo("unauthorized", slog.String("reason", "reauthentication required"))
+			return
+		}
+	}
+	// claims for the current origin
+	claims.AllowedScopes = h.acl.AllowedScopes(&reqURL, claims.Email, claims.Groups()...)
+	claims.Roles = h.acl.Roles(&reqURL, claims.Email, claims.Groups()...)
+
+	res.Header().Set("Cache-Control", "no-store")
+	res.Header().Set("Content-Type", "application/json; charset=utf-8")
+	res.WriteHeader(http.StatusOK)
+	err = json.NewEncoder(res).Encode(userInfo{
+		Claims:    claims,
+		IssuedAt:  token.IssuedAt().Unix(),
+		ExpiresAt: token.Expiration().Unix(),
+	})
+	if err != nil {
+		slog.Error(fmt.Errorf("failed to encode userinfo: %w", err).Error())
+	}
+	logInfo("")
+}
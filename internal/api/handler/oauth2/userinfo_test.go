@@ -0,0 +1,143 @@
+package oauth2handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/tingtt/oauth2rbac/internal/acl"
+	handleroption "github.com/tingtt/oauth2rbac/internal/api/handler/util/option"
+	"github.com/tingtt/oauth2rbac/internal/oauth2"
+	"github.com/tingtt/oauth2rbac/internal/session"
+	"github.com/tingtt/oauth2rbac/pkg/jwtclaims"
+
+	"github.com/stretchr/testify/assert"
+	xoauth2 "golang.org/x/oauth2"
+)
+
+func Test_handler_UserInfo(t *testing.T) {
+	t.Parallel()
+
+	option, _ := handleroption.New(
+		handleroption.WithJWTAuth("secret"),
+		handleroption.WithACL(acl.Pool{
+			"http://example.com": {
+				PathScopes: map[acl.Path][]acl.ScopePath{
+					"/": {{EmailRegexes: []acl.EmailRegex{"*@example.com"}, Methods: []acl.Method{"GET"}}},
+				},
+				Roles:        map[string][]acl.EmailRegex{"member": {"*@example.com"}},
+				OriginConfig: acl.OriginConfig{CORSAllowedOrigins: []string{"http://app.example.com"}},
+			},
+		}),
+		handleroption.WithSecureCookie(false),
+	)
+	h := New(nil, option)
+
+	reqURL, _ := url.Parse("http://example.com/")
+	_, tokenStr, _ := h.tokenIssuer.Issue(reqURL, jwtclaims.Claims{Email: "user@example.com"})
+
+	t.Run("may respond claims for the current origin", func(t *testing.T) {
+		t.Parallel()
+		req := httptest.NewRequest(http.MethodGet, "http://example.com/.auth/userinfo", nil)
+		req.Header.Set("Authorization", "Bearer "+tokenStr)
+		req.Header.Set("Origin", "http://app.example.com")
+		rec := httptest.NewRecorder()
+
+		h.UserInfo(rec, req)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "http://app.example.com", rec.Header().Get("Access-Control-Allow-Origin"))
+		var got userInfo
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &got))
+		assert.Equal(t, "user@example.com", got.Email)
+		assert.Equal(t, []string{"member"}, got.Roles)
+		assert.Equal(t, acl.AllowedScopes{"/": {"GET"}}, got.AllowedScopes)
+		assert.NotZero(t, got.ExpiresAt)
+	})
+
+	t.Run("may respond 401 if not signed in", func(t *testing.T) {
+		t.Parallel()
+		req := httptest.NewRequest(http.MethodGet, "http://example.com/.auth/userinfo", nil)
+		rec := httptest.NewRecorder()
+
+		h.UserInfo(rec, req)
+
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+		assert.NotEmpty(t, rec.Header().Get("WWW-Authenticate"))
+	})
+
+	t.Run("may not allow unconfigured origin", func(t *testing.T) {
+		t.Parallel()
+		req := httptest.NewRequest(http.MethodOptions, "http://example.com/.auth/userinfo", nil)
+		req.Header.Set("Origin", "http://evil.example.net")
+		req.Header.Set("Access-Control-Request-Method", http.MethodGet)
+		rec := httptest.NewRecorder()
+
+		h.UserInfo(rec, req)
+
+		assert.Empty(t, rec.Header().Get("Access-Control-Allow-Origin"))
+	})
+}
+
+func Test_handler_UserInfo_session(t *testing.T) {
+	t.Parallel()
+
+	maxSessionAge := acl.Duration(24 * time.Hour)
+	store := session.NewMemoryStore()
+	option, _ := handleroption.New(
+		handleroption.WithJWTAuth("secret"),
+		handleroption.WithACL(acl.Pool{
+			"http://example.com": {OriginConfig: acl.OriginConfig{MaxSessionAge: &maxSessionAge}},
+		}),
+		handleroption.WithSecureCookie(false),
+		handleroption.WithSessionStore(store),
+		handleroption.WithIdentityRecheckInterval(time.Hour),
+	)
+	h := New(map[string]oauth2.Service{"github": MockOAuth2Service{userInfo: oauth2.UserInfo{Email: "user@example.com"}}}, option)
+
+	reqURL, _ := url.Parse("http://example.com/")
+	type testJWT struct{ sessionID, tokenStr string }
+	newJWT := func(email string, authTime time.Time) testJWT {
+		sessionID := session.NewID()
+		store.Create(session.Session{
+			ID:            sessionID,
+			Email:         email,
+			Provider:      "github",
+			ExpiresAt:     time.Now().Add(time.Hour),
+			ProviderToken: &xoauth2.Token{AccessToken: "access-token"},
+			VerifiedAt:    time.Now().Add(-2 * time.Hour),
+		})
+		_, tokenStr, _ := h.tokenIssuer.Issue(reqURL, jwtclaims.Claims{Email: email, SessionID: sessionID, AuthTime: authTime.Unix()})
+		return testJWT{sessionID, tokenStr}
+	}
+
+	tests := []struct {
+		name                string
+		jwt                 testJWT
+		wantStatus          int
+		wantWWWAuthenticate string
+		wantRevoked         bool
+	}{
+		{"active session may be allowed", newJWT("user@example.com", time.Now()), http.StatusOK, "", false},
+		{"session signed in longer ago than max_session_age may be rejected", newJWT("user@example.com", time.Now().Add(-48*time.Hour)), http.StatusUnauthorized, `error="insufficient_user_authentication", max_age=86400`, false},
+		{"session lost the identity may be revoked", newJWT("former@example.com", time.Now()), http.StatusUnauthorized, `error="invalid_token"`, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			req := httptest.NewRequest(http.MethodGet, "http://example.com/.auth/userinfo", nil)
+			req.Header.Set("Authorization", "Bearer "+tt.jwt.tokenStr)
+			rec := httptest.NewRecorder()
+
+			h.UserInfo(rec, req)
+
+			assert.Equal(t, tt.wantStatus, rec.Code)
+			assert.Contains(t, rec.Header().Get("WWW-Authenticate"), tt.wantWWWAuthenticate)
+			_, err := store.Get(tt.jwt.sessionID)
+			assert.Equal(t, tt.wantRevoked, err != nil)
+		})
+	}
+}
@@ -6,28 +6,83 @@ import (
 	"net/http/httputil"
 	"net/url"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/tingtt/oauth2rbac/internal/acl"
+	"github.com/tingtt/oauth2rbac/internal/api/handler/oauth2/ui"
 	cookieutil "github.com/tingtt/oauth2rbac/internal/api/handler/util/cookie"
+	identityutil "github.com/tingtt/oauth2rbac/internal/api/handler/util/identity"
 	handleroption "github.com/tingtt/oauth2rbac/internal/api/handler/util/option"
+	tokenutil "github.com/tingtt/oauth2rbac/internal/api/handler/util/token"
 	urlutil "github.com/tingtt/oauth2rbac/internal/api/handler/util/url"
+	"github.com/tingtt/oauth2rbac/internal/apitoken"
+	"github.com/tingtt/oauth2rbac/internal/audit"
+	"github.com/tingtt/oauth2rbac/internal/metrics"
+	"github.com/tingtt/oauth2rbac/internal/oauth2"
+	"github.com/tingtt/oauth2rbac/internal/session"
 	"github.com/tingtt/oauth2rbac/internal/util/tree"
 
 	"github.com/go-chi/jwtauth/v5"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
 )
 
 type handler struct {
-	proxyMatchKeys          []string // need sorted in descending order by number of characters
-	proxies                 map[string]*httputil.ReverseProxy
+	routes                  *atomic.Pointer[routes]
 	jwt                     *jwtauth.JWTAuth
-	issuedJWTAvailableSince *time.Time
+	tokenIssuer             *tokenutil.Issuer
+	issuedJWTAvailableSince *atomic.Pointer[time.Time]
 	acl                     acl.Provider
 	cookie                  cookieutil.Controller
+	errorPages              *ui.ErrorPages
+	apiTokens               apitoken.Store
+	sessions                session.Store
+	identity                *identityutil.Rechecker
+	audit                   *audit.Logger
 }
 
-func NewReverseProxyHandler(config Config, option *handleroption.Option) *handler {
+// routes is the proxies by external URL, replaced as a whole on config updates.
+type routes struct {
+	proxyMatchKeys []string // need sorted in descending order by number of characters
+	proxies        map[string]*httputil.ReverseProxy
+	targets        []string
+}
+
+func NewReverseProxyHandler(config Config, oauth2 map[string]oauth2.Service, option *handleroption.Option) *handler {
+	h := &handler{
+		&atomic.Pointer[routes]{},
+		option.JWTAuth,
+		tokenutil.NewIssuer(option),
+		&atomic.Pointer[time.Time]{},
+		option.ACLProvider,
+		option.CookieController,
+		option.ErrorPages,
+		option.APITokenStore,
+		option.SessionStore,
+		identityutil.NewRechecker(oauth2, option),
+		option.AuditLogger,
+	}
+	h.UpdateConfig(config)
+	return h
+}
+
+// UpdateConfig replaces the proxies while serving requests. (e.g. Kubernetes Ingresses changed)
+// Requests being proxied are not interrupted.
+// The allowed scopes of JWTs issued before are re-evaluated with the ACL, so the ACL must be swapped before. (e.g. acl.SwappableProvider)
+func (h *handler) UpdateConfig(config Config) {
+	h.routes.Store(newRoutes(config, h.errorPages))
+	now := time.Now()
+	h.issuedJWTAvailableSince.Store(&now)
+}
+
+// Targets returns the target URLs of the current proxies.
+func (h *handler) Targets() []string {
+	return h.routes.Load().targets
+}
+
+func newRoutes(config Config, errorPages *ui.ErrorPages) *routes {
 	proxies := make(map[string]*httputil.ReverseProxy, len(config.Proxies))
+	targets := make([]string, 0, len(config.Proxies))
 	var rootProxyMatchKeys *tree.Node[string]
 	numberOfCharactersDescendinig := func(new, curr string) (isLeft bool) {
 		return len(new) > len(curr)
@@ -36,35 +91,31 @@ func NewReverseProxyHandler(config Config, option *handleroption.Option) *handle
 		targetURL, _ := url.Parse(proxy.Target.URL)    // format already checked in loading manifest
 		externalURL, _ := url.Parse(proxy.ExternalURL) // format already checked in loading manifest
 
-		proxies[proxy.ExternalURL] = newSingleHostReverseProxy(targetURL, externalURL.Path, proxy.SetHeaders)
+		proxies[proxy.ExternalURL] = newSingleHostReverseProxy(targetURL, externalURL.Path, proxy.SetHeaders, errorPages)
 		rootProxyMatchKeys = tree.Insert(rootProxyMatchKeys, proxy.ExternalURL, numberOfCharactersDescendinig)
+		targets = append(targets, proxy.Target.URL)
 	}
 	proxyMatchKeys := []string{}
 	tree.InOrderTraversal(rootProxyMatchKeys, &proxyMatchKeys)
-	issuedJWTAvailableSince := time.Now()
-	return &handler{
-		proxyMatchKeys,
-		proxies,
-		option.JWTAuth,
-		&issuedJWTAvailableSince,
-		option.ACLProvider,
-		option.CookieController,
-	}
+	return &routes{proxyMatchKeys, proxies, targets}
 }
 
-func newSingleHostReverseProxy(targetURL *url.URL, matchPath string, headers map[string][]string) *httputil.ReverseProxy {
+func newSingleHostReverseProxy(targetURL *url.URL, matchPath string, headers map[string][]string, errorPages *ui.ErrorPages) *httputil.ReverseProxy {
 	proxy := httputil.NewSingleHostReverseProxy(targetURL)
 	rewriteRequestURL := proxy.Director
 	proxy.Director = func(req *http.Request) {
 		trimBaseURLWithTrailingSlashTarget(req, targetURL.Path, matchPath)
 		rewriteRequestURL(req)
 		setHeaders(req, headers)
+		metrics.SetTarget(req.Context(), targetURL.String())
 	}
 	// proxy.ModifyResponse = func(res *http.Response) error {
 	// 	TODO: implement ModifyResponse
 	// 	return nil
 	// }
-	proxy.ErrorHandler = handleReverceProxyError
+	proxy.ErrorHandler = reverseProxyErrorHandler(targetURL, errorPages)
+	// spans of the upstream round trip, propagating W3C trace context to the upstream
+	proxy.Transport = otelhttp.NewTransport(http.DefaultTransport)
 	return proxy
 }
 
@@ -83,8 +134,14 @@ func setHeaders(req *http.Request, headers map[string][]string) {
 	}
 }
 
-func handleReverceProxyError(res http.ResponseWriter, inReq *http.Request, err error) {
-	inReqURL := urlutil.RequestURL(*inReq.URL, urlutil.WithRequest(inReq), urlutil.WithXForwardedHeaders(inReq.Header))
-	slog.Error("http: proxy error", slog.String("host", inReqURL.Host), slog.String("error", err.Error()))
-	res.WriteHeader(http.StatusBadGateway)
+func reverseProxyErrorHandler(targetURL *url.URL, errorPages *ui.ErrorPages) func(res http.ResponseWriter, inReq *http.Request, err error) {
+	return func(res http.ResponseWriter, inReq *http.Request, err error) {
+		metrics.UpstreamErrors.WithLabelValues(targetURL.String()).Inc()
+		inReqURL := urlutil.RequestURL(*inReq.URL, urlutil.WithRequest(inReq), urlutil.WithXForwardedHeaders(inReq.Header))
+		slog.Error("http: proxy error", slog.String("host", inReqURL.Host), slog.String("error", err.Error()))
+		errorPages.Write(res, inReq, inReqURL.Scheme+"://"+inReqURL.Host, ui.ErrorPageProps{
+			StatusCode: http.StatusBadGateway,
+			Message:    "The upstream server is unavailable. Please try again later.",
+		})
+	}
 }
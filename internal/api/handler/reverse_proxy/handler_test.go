@@ -1,6 +1,7 @@
 package reverseproxy
 
 import (
+	"net/url"
 	"testing"
 
 	handleroption "github.com/tingtt/oauth2rbac/internal/api/handler/util/option"
@@ -20,18 +21,30 @@ func TestNewReverseProxyHandler(t *testing.T) {
 
 	t.Run("proxyMatchKeys may sorted descending order by number of characters", func(t *testing.T) {
 		t.Parallel()
-		h := NewReverseProxyHandler(config, handlerOption)
+		h := NewReverseProxyHandler(config, nil, handlerOption)
+		routes := h.routes.Load()
 
-		assert.Equal(t, h.proxyMatchKeys, []string{
+		assert.Equal(t, routes.proxyMatchKeys, []string{
 			"http://example.com/-/healthz",
 			"http://example.com/base/",
 			"http://example.com/",
 		})
 
-		proxyMatchKeys := make([]string, 0, len(h.proxies))
-		for k := range h.proxies {
+		proxyMatchKeys := make([]string, 0, len(routes.proxies))
+		for k := range routes.proxies {
 			proxyMatchKeys = append(proxyMatchKeys, k)
 		}
-		assert.ElementsMatch(t, h.proxyMatchKeys, proxyMatchKeys)
+		assert.ElementsMatch(t, routes.proxyMatchKeys, proxyMatchKeys)
+	})
+
+	t.Run("proxies may be replaced by UpdateConfig", func(t *testing.T) {
+		t.Parallel()
+		h := NewReverseProxyHandler(config, nil, handlerOption)
+
+		h.UpdateConfig(Config{Proxies: []Proxy{{ExternalURL: "http://example.org/"}}})
+
+		assert.Equal(t, []string{"http://example.org/"}, h.routes.Load().proxyMatchKeys)
+		assert.Nil(t, h.matchProxy(url.URL{Scheme: "http", Host: "example.com", Path: "/"}))
+		assert.NotNil(t, h.matchProxy(url.URL{Scheme: "http", Host: "example.org", Path: "/"}))
 	})
 }
@@ -9,11 +9,12 @@ import (
 )
 
 func (h *handler) matchProxy(reqURL url.URL) (proxy *httputil.ReverseProxy) {
-	key := slices.Find(h.proxyMatchKeys, func(uriPrefix string) bool {
+	routes := h.routes.Load()
+	key := slices.Find(routes.proxyMatchKeys, func(uriPrefix string) bool {
 		return strings.HasPrefix(reqURL.String(), uriPrefix)
 	})
 	if key == nil {
 		return nil
 	}
-	return h.proxies[*key]
+	return routes.proxies[*key]
 }
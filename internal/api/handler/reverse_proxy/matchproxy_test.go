@@ -112,7 +112,7 @@ func Test_handler_matchProxy(t *testing.T) {
 				}(tt.req, tt.want)
 
 				proxy, mockTransport := func(config Config, option *handleroption.Option, reqURL url.URL) (*httputil.ReverseProxy, *MockTransport) {
-					proxy := NewReverseProxyHandler(config, option).matchProxy(reqURL)
+					proxy := NewReverseProxyHandler(config, nil, option).matchProxy(reqURL)
 					assert.NotNil(t, proxy)
 					mockTransport := new(MockTransport)
 					proxy.Transport = mockTransport
@@ -160,7 +160,7 @@ func Test_handler_matchProxy(t *testing.T) {
 
 				reqURL, _ := url.Parse(tt.req.url)
 
-				proxy := NewReverseProxyHandler(tt.config, tt.option).matchProxy(*reqURL)
+				proxy := NewReverseProxyHandler(tt.config, nil, tt.option).matchProxy(*reqURL)
 				assert.NotNil(t, proxy)
 				proxy.Director(&http.Request{
 					Method:     tt.req.method,
@@ -240,7 +240,7 @@ func Test_handler_matchProxy(t *testing.T) {
 
 				reqURL, _ := url.Parse(tt.req.url)
 
-				proxy := NewReverseProxyHandler(tt.config, tt.option).matchProxy(*reqURL)
+				proxy := NewReverseProxyHandler(tt.config, nil, tt.option).matchProxy(*reqURL)
 				assert.NotNil(t, proxy)
 				req := &http.Request{
 					Method:     tt.req.method,
@@ -7,18 +7,25 @@ import (
 	"log/slog"
 	"net/http"
 	"net/url"
-	"strings"
+	"slices"
 	"time"
 
-	oauth2handler "github.com/tingtt/oauth2rbac/internal/api/handler/oauth2"
+	"github.com/tingtt/oauth2rbac/internal/acl"
+	"github.com/tingtt/oauth2rbac/internal/api/handler/oauth2/ui"
 	logutil "github.com/tingtt/oauth2rbac/internal/api/handler/util/log"
+	negotiateutil "github.com/tingtt/oauth2rbac/internal/api/handler/util/negotiate"
 	urlutil "github.com/tingtt/oauth2rbac/internal/api/handler/util/url"
+	"github.com/tingtt/oauth2rbac/internal/apitoken"
+	"github.com/tingtt/oauth2rbac/internal/audit"
+	"github.com/tingtt/oauth2rbac/internal/metrics"
+	"github.com/tingtt/oauth2rbac/internal/session"
+	"github.com/tingtt/oauth2rbac/internal/tracing"
 	"github.com/tingtt/oauth2rbac/pkg/jwtclaims"
 
 	jwtmiddleware "github.com/tingtt/oauth2rbac/internal/api/middleware/jwt"
 
-	"github.com/go-chi/jwtauth/v5"
 	"github.com/lestrrat-go/jwx/v2/jwt"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 func (h *handler) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
@@ -29,11 +36,21 @@ func (h *handler) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
 		urlutil.WithXForwardedHeaders(req.Header),
 	)
 	res, logInfo := logutil.InfoLogger(reqURL, req.Method, rw, req)
+	originConfig := h.acl.OriginConfig(&reqURL)
+	if /* origin declared in ACL */ originConfig != nil {
+		metrics.SetOrigin(req.Context(), reqURL.Scheme+"://"+reqURL.Host)
+	}
+
+	_, aclSpan := tracing.Start(req.Context(), "acl.LoginRequired")
+	loginRequired := h.acl.LoginRequired(&reqURL, req.Method)
+	aclSpan.SetAttributes(attribute.Bool("oauth2rbac.login_required", loginRequired))
+	aclSpan.End()
 
-	if !h.acl.LoginRequired(&reqURL, req.Method) {
+	if !loginRequired {
+		metrics.ObserveAuthorization(req.Context(), metrics.OutcomePublic)
 		proxy := h.matchProxy(reqURL)
 		if proxy == nil {
-			http.Error(res, "Not Found", http.StatusNotFound)
+			h.writeError(res, req, reqURL, ui.ErrorPageProps{StatusCode: http.StatusNotFound})
 			logInfo("proxy target not found")
 			return
 		}
@@ -42,15 +59,22 @@ func (h *handler) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	token, err := h.jwt.Decode(tokenStrFromRequest(req))
+	if tokenStr := jwtmiddleware.TokenFromRequest(req); apitoken.IsAPIToken(tokenStr) {
+		h.serveHTTPWithAPIToken(res, req, reqURL, tokenStr, logInfo)
+		return
+	}
+
+	_, jwtSpan := tracing.Start(req.Context(), "jwt.Decode")
+	token, err := h.jwt.Decode(jwtmiddleware.TokenFromRequest(req))
+	tracing.End(jwtSpan, err)
 	if /* unauthorized or token expired */ err != nil {
-		redirectURL := loginURLWithRedirectURL(reqURL.String())
-		h.cookie.SetRedirectURLForAfterLogin(res, reqURL.String())
-		http.Redirect(res, req, redirectURL, http.StatusFound)
-		logInfo("request login", slog.String("reason", err.Error()))
+		h.requestLogin(res, req, reqURL, originConfig, err.Error(), logInfo)
 		if !errors.Is(err, jwt.ErrTokenExpired()) {
+			if /* token given */ jwtmiddleware.TokenFromRequest(req) != "" {
+				h.audit.LogRequest(req, reqURL, audit.Event{Type: audit.TypeAccessDenied, Reason: "invalid token: " + err.Error()})
+			}
 			slog.Error("failed to decode JWT", slog.String("err", err.Error()))
-			slog.Debug("failed to decode JWT", slog.String("jwt", tokenStrFromRequest(req)), slog.String("err", err.Error()))
+			slog.Debug("failed to decode JWT", slog.String("jwt", jwtmiddleware.TokenFromRequest(req)), slog.String("err", err.Error()))
 		}
 		return
 	}
@@ -58,51 +82,113 @@ func (h *handler) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
 	claimsJSON, _ := json.Marshal(token.PrivateClaims())
 	jwtPrivateClaims, err := jwtclaims.Unmarshal(claimsJSON)
 	if err != nil {
-		http.Error(res, "System Error. Please contact administrator.", http.StatusInternalServerError)
+		h.writeError(res, req, reqURL, ui.ErrorPageProps{
+			StatusCode: http.StatusInternalServerError,
+			Message:    "System Error. Please contact administrator.",
+			LoginURL:   loginURLWithRedirectURL(reqURL.String()),
+		})
 		logInfo("internal error", slog.String("err", err.Error()))
 		slog.Error("failed to unmarshal token claims", slog.String("err", err.Error()))
-		slog.Debug("failed to unmarshal token claims", slog.String("jwt", tokenStrFromRequest(req)), slog.String("err", err.Error()))
+		slog.Debug("failed to unmarshal token claims", slog.String("jwt", jwtmiddleware.TokenFromRequest(req)), slog.String("err", err.Error()))
+		return
+	}
+	tracing.SetUserEmail(req.Context(), jwtPrivateClaims.Email)
+
+	if /* session revoked */ err := session.Check(h.sessions, jwtPrivateClaims); err != nil {
+		h.requestLogin(res, req, reqURL, originConfig, err.Error(), logInfo)
+		h.audit.LogRequest(req, reqURL, audit.Event{
+			Type:      audit.TypeAccessDenied,
+			Actor:     jwtPrivateClaims.Email,
+			SessionID: jwtPrivateClaims.SessionID,
+			Reason:    err.Error(),
+		})
+		return
+	}
+	if /* identity lost */ err := h.identity.Recheck(req.Context(), jwtPrivateClaims); err != nil {
+		h.requestLogin(res, req, reqURL, originConfig, "identity recheck failed: "+err.Error(), logInfo)
+		h.audit.LogRequest(req, reqURL, audit.Event{
+			Type:      audit.TypeAccessDenied,
+			Actor:     jwtPrivateClaims.Email,
+			SessionID: jwtPrivateClaims.SessionID,
+			Reason:    "identity recheck failed: " + err.Error(),
+		})
 		return
 	}
 
+	if maxAuthAge, ok := originConfig.MaxAuthAge(reqURL.Path); ok {
+		if /* signed in too long ago */ time.Since(time.Unix(jwtPrivateClaims.AuthTime, 0)) > maxAuthAge {
+			h.requestReauthentication(res, req, reqURL, originConfig, maxAuthAge, logInfo)
+			return
+		}
+	}
+
+	_, aclSpan = tracing.Start(req.Context(), "acl.AllowedScopes")
 	allowedScopes := jwtPrivateClaims.AllowedScopes
 	roles := jwtPrivateClaims.Roles
-	if /* acl config reloaded */ token.IssuedAt().Before(*h.issuedJWTAvailableSince) {
+	if /* acl config reloaded */ token.IssuedAt().Before(*h.issuedJWTAvailableSince.Load()) ||
+		/* issued for another origin (e.g. client credentials grant on another origin) */ !slices.Contains(token.Audience(), reqURL.Scheme+"://"+reqURL.Host) {
 		// load acl config
-		allowedScopes = h.acl.AllowedScopes(&reqURL, jwtPrivateClaims.Email)
-		roles = h.acl.Roles(&reqURL, jwtPrivateClaims.Email)
+		allowedScopes = h.acl.AllowedScopes(&reqURL, jwtPrivateClaims.Email, jwtPrivateClaims.Groups()...)
+		roles = h.acl.Roles(&reqURL, jwtPrivateClaims.Email, jwtPrivateClaims.Groups()...)
 	}
+	allowed := allowedScopes.Match(reqURL.Path, req.Method)
+	aclSpan.SetAttributes(attribute.Bool("oauth2rbac.allowed", allowed))
+	aclSpan.End()
 
-	if /* forbidden */ !allowedScopes.Match(reqURL.Path, req.Method) {
-		http.Error(res, "Forbidden", http.StatusForbidden)
+	if /* forbidden */ !allowed {
+		if !originConfig.SkipRedirectAfterLogin(reqURL.Path) {
+			h.cookie.SetRedirectURLForAfterLogin(res, reqURL.String())
+		}
+		h.writeError(res, req, reqURL, ui.ErrorPageProps{
+			StatusCode: http.StatusForbidden,
+			Message:    "You do not have access to this page.",
+			Email:      jwtPrivateClaims.Email,
+			LoginURL:   loginURLWithRedirectURL(reqURL.String()),
+		})
+		metrics.ObserveAuthorization(req.Context(), metrics.OutcomeForbidden)
+		h.audit.LogRequest(req, reqURL, audit.Event{
+			Type:      audit.TypeAccessDenied,
+			Actor:     jwtPrivateClaims.Email,
+			SessionID: jwtPrivateClaims.SessionID,
+			Rule:      matchedRule(allowedScopes, reqURL.Path),
+			Reason:    "no access to the scope",
+		})
 		logInfo("no access to the scope")
 		return
 	}
+	metrics.ObserveAuthorization(req.Context(), metrics.OutcomeAllowed)
 
-	newPrivateClaims := oauth2handler.JWTClaims(jwtPrivateClaims)
+	newPrivateClaims := jwtPrivateClaims
 	newPrivateClaims.AllowedScopes = allowedScopes
 	newPrivateClaims.Roles = roles
-	tokenExpiryIn := jwtmiddleware.DefaultExpiry
-	originConfig := h.acl.OriginConfig(&reqURL)
-	if originConfig != nil {
-		if originConfig.JWTExpiryIn != nil {
-			tokenExpiryIn = time.Duration(*originConfig.JWTExpiryIn)
-		}
-	}
-	_, newTokenStr, err := renewJWT(newPrivateClaims.MapCollect(), h.jwt.Encode, tokenExpiryIn)
+	_, jwtSpan = tracing.Start(req.Context(), "jwt.Renew")
+	newToken, newTokenStr, err := h.tokenIssuer.Issue(&reqURL, newPrivateClaims)
+	tracing.End(jwtSpan, err)
 	if err != nil {
 		slog.Error("failed to renew jwt token", slog.String("err", err.Error()))
-		slog.Debug("failed to renew jwt token", slog.String("jwt", tokenStrFromRequest(req)), slog.String("err", err.Error()))
-		res.WriteHeader(http.StatusInternalServerError)
-		// TODO: error view
+		slog.Debug("failed to renew jwt token", slog.String("jwt", jwtmiddleware.TokenFromRequest(req)), slog.String("err", err.Error()))
+		h.writeError(res, req, reqURL, ui.ErrorPageProps{
+			StatusCode: http.StatusInternalServerError,
+			Message:    "System Error. Please contact administrator.",
+			Email:      jwtPrivateClaims.Email,
+		})
 		logInfo("failed to renew jwt token")
 		return
 	}
-	h.cookie.SetJWT(res, newTokenStr)
+	h.tokenIssuer.SetCookie(res, &reqURL, newToken, newTokenStr)
+	metrics.JWTRenewals.Inc()
+	if h.sessions != nil && jwtPrivateClaims.SessionID != "" {
+		if err := h.sessions.Touch(jwtPrivateClaims.SessionID, newToken.IssuedAt(), newToken.Expiration()); err != nil {
+			slog.Error("failed to extend session", slog.String("err", err.Error()))
+		}
+	}
 
 	proxy := h.matchProxy(reqURL)
 	if proxy == nil {
-		http.Error(res, "Not Found", http.StatusNotFound)
+		h.writeError(res, req, reqURL, ui.ErrorPageProps{
+			StatusCode: http.StatusNotFound,
+			Email:      jwtPrivateClaims.Email,
+		})
 		logInfo("proxy target not found")
 		return
 	}
@@ -110,12 +196,86 @@ func (h *handler) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
 	logInfo("proxy successful (authorized)")
 }
 
-func tokenStrFromRequest(req *http.Request) string {
-	authorizationToken, _ := strings.CutPrefix(req.Header.Get("Authorization"), "Bearer ")
-	if authorizationToken != "" {
-		return authorizationToken
+func (h *handler) writeError(res http.ResponseWriter, req *http.Request, reqURL url.URL, props ui.ErrorPageProps) {
+	props.Method = req.Method
+	props.URL = reqURL.String()
+	props.LogoutURL = "/.auth/logout"
+	h.errorPages.Write(res, req, reqURL.Scheme+"://"+reqURL.Host, props)
+}
+
+// requestLogin redirects to the login page, or responds 401 to API or XHR clients.
+func (h *handler) requestLogin(
+	res *logutil.CustomResponseWriter, req *http.Request, reqURL url.URL, originConfig *acl.OriginConfig, reason string,
+	logInfo func(msg string, args ...slog.Attr),
+) {
+	metrics.ObserveAuthorization(req.Context(), metrics.OutcomeLoginRedirect)
+	if /* API or XHR client */ negotiateutil.WantsJSON(req) || originConfig.IsAPIPath(reqURL.Path) {
+		h.writeUnauthorized(res, reqURL, req.Method, jwtmiddleware.TokenFromRequest(req) != "")
+		logInfo("unauthorized", slog.String("reason", reason))
+		return
+	}
+	redirectURL := loginURLWithRedirectURL(reqURL.String())
+	if !originConfig.SkipRedirectAfterLogin(reqURL.Path) {
+		h.cookie.SetRedirectURLForAfterLogin(res, reqURL.String())
+	}
+	http.Redirect(res, req, redirectURL, http.StatusFound)
+	logInfo("request login", slog.String("reason", reason))
+}
+
+// requestReauthentication requests login with `prompt=login`, when the user signed in longer ago than maxAuthAge.
+func (h *handler) requestReauthentication(
+	res *logutil.CustomResponseWriter, req *http.Request, reqURL url.URL, originConfig *acl.OriginConfig, maxAuthAge time.Duration,
+	logInfo func(msg string, args ...slog.Attr),
+) {
+	metrics.ObserveAuthorization(req.Context(), metrics.OutcomeLoginRedirect)
+	loginURL := loginURLWithRedirectURL(reqURL.String()) + "&prompt=login"
+	if /* API or XHR client */ negotiateutil.WantsJSON(req) || originConfig.IsAPIPath(reqURL.Path) {
+		// RFC 9470 (OAuth 2.0 Step Up Authentication Challenge Protocol)
+		res.Header().Set("WWW-Authenticate", fmt.Sprintf(
+			`Bearer realm=%q, error="insufficient_user_authentication", max_age=%d`,
+			reqURL.Scheme+"://"+reqURL.Host, int64(maxAuthAge.Seconds()),
+		))
+		h.errorPages.WriteJSON(res, ui.ErrorPageProps{
+			StatusCode: http.StatusUnauthorized,
+			Message:    "Sign in again required.",
+			Method:     req.Method,
+			URL:        reqURL.String(),
+			LoginURL:   reqURL.Scheme + "://" + reqURL.Host + loginURL,
+		})
+		logInfo("unauthorized", slog.String("reason", "reauthentication required"))
+		return
+	}
+	if !originConfig.SkipRedirectAfterLogin(reqURL.Path) {
+		h.cookie.SetRedirectURLForAfterLogin(res, reqURL.String())
 	}
-	return jwtauth.TokenFromCookie(req)
+	http.Redirect(res, req, loginURL, http.StatusFound)
+	logInfo("request login", slog.String("reason", "reauthentication required"))
+}
+
+// writeUnauthorized responds 401 with the login URL instead of redirecting to the login page.
+func (h *handler) writeUnauthorized(res http.ResponseWriter, reqURL url.URL, method string, tokenGiven bool) {
+	origin := reqURL.Scheme + "://" + reqURL.Host
+	wwwAuthenticate := fmt.Sprintf("Bearer realm=%q", origin)
+	if tokenGiven {
+		wwwAuthenticate += `, error="invalid_token"`
+	}
+	res.Header().Set("WWW-Authenticate", wwwAuthenticate)
+	h.errorPages.WriteJSON(res, ui.ErrorPageProps{
+		StatusCode: http.StatusUnauthorized,
+		Message:    "Sign in required.",
+		Method:     method,
+		URL:        reqURL.String(),
+		LoginURL:   origin + loginURLWithRedirectURL(reqURL.String()),
+	})
+}
+
+// matchedRule returns the scope matched the path regardless of the method, for audit logs. (nil if no path matched)
+func matchedRule(allowedScopes acl.AllowedScopes, path string) *audit.Rule {
+	matchedPath, ok := allowedScopes.MatchedPath(path)
+	if !ok {
+		return nil
+	}
+	return &audit.Rule{Path: matchedPath, Methods: allowedScopes[matchedPath]}
 }
 
 func loginURLWithRedirectURL(redirectURL string) string {
@@ -124,17 +284,3 @@ func loginURLWithRedirectURL(redirectURL string) string {
 		url.QueryEscape(redirectURL),
 	)
 }
-
-func renewJWT(
-	claim map[string]interface{},
-	encodeFunc func(claims map[string]interface{}) (t jwt.Token, tokenString string, err error),
-	expiryIn time.Duration,
-) (t jwt.Token, tokenString string, err error) {
-	jwtauth.SetIssuedNow(claim)
-	jwtauth.SetExpiryIn(claim, expiryIn)
-	t, str, err := encodeFunc(claim)
-	if err != nil {
-		return nil, "", fmt.Errorf("failed to encode jwt token: %w", err)
-	}
-	return t, str, nil
-}
@@ -0,0 +1,100 @@
+package reverseproxy
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/tingtt/oauth2rbac/internal/api/handler/oauth2/ui"
+	"github.com/tingtt/oauth2rbac/internal/apitoken"
+	"github.com/tingtt/oauth2rbac/internal/audit"
+	"github.com/tingtt/oauth2rbac/internal/metrics"
+	"github.com/tingtt/oauth2rbac/internal/tracing"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// serveHTTPWithAPIToken authorizes the request with the API token given in `Authorization: Bearer`.
+// The request is allowed only if both the token scopes and the owner's current allowed scopes match.
+// (Scopes allowed by groups of the owner are not included, as the groups are resolved only on sign-in.)
+func (h *handler) serveHTTPWithAPIToken(
+	res http.ResponseWriter, req *http.Request, reqURL url.URL, tokenStr string,
+	logInfo func(msg string, args ...slog.Attr),
+) {
+	if /* api tokens disabled */ h.apiTokens == nil {
+		h.writeUnauthorized(res, reqURL, req.Method, true)
+		metrics.ObserveAuthorization(req.Context(), metrics.OutcomeLoginRedirect)
+		logInfo("unauthorized", slog.String("reason", "api tokens disabled"))
+		return
+	}
+
+	token, err := h.apiTokens.FindByHash(apitoken.Hash(tokenStr))
+	if err != nil {
+		if !errors.Is(err, apitoken.ErrNotFound) {
+			slog.Error("failed to find api token", slog.String("err", err.Error()))
+		}
+		h.writeUnauthorized(res, reqURL, req.Method, true)
+		metrics.ObserveAuthorization(req.Context(), metrics.OutcomeLoginRedirect)
+		h.audit.LogRequest(req, reqURL, audit.Event{Type: audit.TypeAccessDenied, Reason: "invalid api token: " + err.Error()})
+		logInfo("unauthorized", slog.String("reason", err.Error()))
+		return
+	}
+	if token.Expired(time.Now()) {
+		h.writeUnauthorized(res, reqURL, req.Method, true)
+		metrics.ObserveAuthorization(req.Context(), metrics.OutcomeLoginRedirect)
+		h.audit.LogRequest(req, reqURL, audit.Event{
+			Type:    audit.TypeAccessDenied,
+			Actor:   token.Email,
+			Reason:  "api token expired",
+			Details: map[string]any{"token_id": token.ID},
+		})
+		logInfo("unauthorized", slog.String("reason", "api token expired"), slog.String("token_id", token.ID))
+		return
+	}
+
+	tracing.SetUserEmail(req.Context(), token.Email)
+
+	_, aclSpan := tracing.Start(req.Context(), "acl.AllowedScopes")
+	origin := reqURL.Scheme + "://" + reqURL.Host
+	ownerScopes := h.acl.AllowedScopes(&reqURL, token.Email)
+	allowed := token.Scopes[origin].Match(reqURL.Path, req.Method) && ownerScopes.Match(reqURL.Path, req.Method)
+	aclSpan.SetAttributes(attribute.Bool("oauth2rbac.allowed", allowed))
+	aclSpan.End()
+	if /* forbidden */ !allowed {
+		h.errorPages.WriteJSON(res, ui.ErrorPageProps{
+			StatusCode: http.StatusForbidden,
+			Message:    "The API token has no access to the scope.",
+			Email:      token.Email,
+			Method:     req.Method,
+			URL:        reqURL.String(),
+		})
+		metrics.ObserveAuthorization(req.Context(), metrics.OutcomeForbidden)
+		reason, rule := "api token has no access to the scope", matchedRule(token.Scopes[origin], reqURL.Path)
+		if /* owner lost the access */ token.Scopes[origin].Match(reqURL.Path, req.Method) {
+			reason, rule = "api token owner has no access to the scope", matchedRule(ownerScopes, reqURL.Path)
+		}
+		h.audit.LogRequest(req, reqURL, audit.Event{
+			Type:    audit.TypeAccessDenied,
+			Actor:   token.Email,
+			Rule:    rule,
+			Reason:  reason,
+			Details: map[string]any{"token_id": token.ID},
+		})
+		logInfo("no access to the scope", slog.String("token_id", token.ID))
+		return
+	}
+	metrics.ObserveAuthorization(req.Context(), metrics.OutcomeAllowed)
+
+	proxy := h.matchProxy(reqURL)
+	if proxy == nil {
+		h.errorPages.WriteJSON(res, ui.ErrorPageProps{StatusCode: http.StatusNotFound, Method: req.Method, URL: reqURL.String()})
+		logInfo("proxy target not found")
+		return
+	}
+	// do not pass the API token to the upstream
+	req.Header.Del("Authorization")
+	proxy.ServeHTTP(res, req)
+	logInfo("proxy successful (api token)", slog.String("token_id", token.ID))
+}
@@ -0,0 +1,517 @@
+package reverseproxy
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tingtt/oauth2rbac/internal/acl"
+	handleroption "github.com/tingtt/oauth2rbac/internal/api/handler/util/option"
+	"github.com/tingtt/oauth2rbac/internal/apitoken"
+	"github.com/tingtt/oauth2rbac/internal/audit"
+	"github.com/tingtt/oauth2rbac/internal/session"
+	"github.com/tingtt/oauth2rbac/pkg/jwtclaims"
+
+	"github.com/go-chi/jwtauth/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func Test_handler_ServeHTTP_unauthenticated(t *testing.T) {
+	t.Parallel()
+
+	config := Config{Proxies: []Proxy{
+		{ExternalURL: "http://example.com/", Target: Target{"http://web:80"}},
+	}}
+	option, _ := handleroption.New(
+		handleroption.WithJWTAuth("secret"),
+		handleroption.WithACL(acl.Pool{
+			"http://example.com": {
+				PathScopes: map[acl.Path][]acl.ScopePath{
+					"/": {{EmailRegexes: []acl.EmailRegex{"*"}, Methods: []acl.Method{"*"}}},
+				},
+				OriginConfig: acl.OriginConfig{APIPaths: []acl.PathPattern{"/api/"}},
+			},
+		}),
+		handleroption.WithSecureCookie(false),
+	)
+	h := NewReverseProxyHandler(config, nil, option)
+
+	tests := []struct {
+		name       string
+		path       string
+		header     http.Header
+		wantStatus int
+	}{
+		{
+			name:       "browser may be redirected to login page",
+			path:       "/dashboard",
+			header:     http.Header{"Accept": {"text/html"}},
+			wantStatus: http.StatusFound,
+		},
+		{
+			name:       "JSON client may receive 401",
+			path:       "/dashboard",
+			header:     http.Header{"Accept": {"application/json"}},
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:       "XHR may receive 401",
+			path:       "/dashboard",
+			header:     http.Header{"X-Requested-With": {"XMLHttpRequest"}},
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:       "configured API path may receive 401",
+			path:       "/api/users",
+			header:     http.Header{},
+			wantStatus: http.StatusUnauthorized,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			req := httptest.NewRequest(http.MethodGet, "http://example.com"+tt.path, nil)
+			req.Header = tt.header
+			rec := httptest.NewRecorder()
+
+			h.ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.wantStatus, rec.Code)
+			if tt.wantStatus == http.StatusUnauthorized {
+				assert.Equal(t, `Bearer realm="http://example.com"`, rec.Header().Get("WWW-Authenticate"))
+				assert.Contains(t, rec.Body.String(), `"login_url":"http://example.com/.auth/login?redirect_url=`)
+			}
+		})
+	}
+}
+
+func Test_handler_ServeHTTP_apiToken(t *testing.T) {
+	t.Parallel()
+
+	// token created with the group snapshot of the owner (e.g. before leaving the organization)
+	groupTokenStr, groupHash := apitoken.Generate()
+	storePath := t.TempDir() + "/tokens.json"
+	os.WriteFile(storePath, []byte(`[{"id":"group-token","email":"user@example.com","groups":["github:acme"],"hash":"`+groupHash+`","scopes":{"http://example.com":{"/team/":["GET"]}}}]`), 0o600)
+	store, _ := apitoken.NewFileStore(storePath)
+	tokenStr, hash := apitoken.Generate()
+	store.Create(apitoken.Token{
+		ID:     apitoken.NewID(),
+		Email:  "user@example.com",
+		Hash:   hash,
+		Scopes: map[string]acl.AllowedScopes{"http://example.com": {"/api/": {"GET"}}},
+	})
+	expiredTokenStr, expiredHash := apitoken.Generate()
+	expiredAt := time.Now().Add(-time.Minute)
+	store.Create(apitoken.Token{
+		ID:        apitoken.NewID(),
+		Email:     "user@example.com",
+		Hash:      expiredHash,
+		Scopes:    map[string]acl.AllowedScopes{"http://example.com": {"/api/": {"GET"}}},
+		ExpiresAt: &expiredAt,
+	})
+
+	config := Config{Proxies: []Proxy{
+		{ExternalURL: "http://example.com/", Target: Target{"http://web:80"}},
+	}}
+	option, _ := handleroption.New(
+		handleroption.WithJWTAuth("secret"),
+		handleroption.WithACL(acl.Pool{
+			"http://example.com": {
+				PathScopes: map[acl.Path][]acl.ScopePath{
+					"/":      {{EmailRegexes: []acl.EmailRegex{"*@example.com"}, Methods: []acl.Method{"*"}}},
+					"/team/": {{EmailRegexes: []acl.EmailRegex{"github:acme"}, Methods: []acl.Method{"*"}}},
+				},
+			},
+		}),
+		handleroption.WithSecureCookie(false),
+		handleroption.WithAPITokenStore(store),
+	)
+	h := NewReverseProxyHandler(config, nil, option)
+	mockTransport := new(MockTransport)
+	mockTransport.On("RoundTrip", mock.MatchedBy(func(req *http.Request) bool {
+		return req.Header.Get("Authorization") == ""
+	})).Return(&http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(""))}, nil)
+	h.routes.Load().proxies["http://example.com/"].Transport = mockTransport
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		tokenStr   string
+		wantStatus int
+	}{
+		{"token scope may be allowed", http.MethodGet, "/api/users", tokenStr, http.StatusOK},
+		{"out of token scope may be forbidden", http.MethodPost, "/api/users", tokenStr, http.StatusForbidden},
+		{"out of token path may be forbidden", http.MethodGet, "/dashboard", tokenStr, http.StatusForbidden},
+		{"scope allowed by groups of the owner may be forbidden", http.MethodGet, "/team/", groupTokenStr, http.StatusForbidden},
+		{"expired token may be unauthorized", http.MethodGet, "/api/users", expiredTokenStr, http.StatusUnauthorized},
+		{"unknown token may be unauthorized", http.MethodGet, "/api/users", apitoken.Prefix + "unknown", http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			req := httptest.NewRequest(tt.method, "http://example.com"+tt.path, nil)
+			req.Header.Set("Authorization", "Bearer "+tt.tokenStr)
+			rec := httptest.NewRecorder()
+
+			h.ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.wantStatus, rec.Code)
+		})
+	}
+}
+
+func Test_handler_ServeHTTP_session(t *testing.T) {
+	t.Parallel()
+
+	store := session.NewMemoryStore()
+	config := Config{Proxies: []Proxy{
+		{ExternalURL: "http://example.com/", Target: Target{"http://web:80"}},
+	}}
+	option, _ := handleroption.New(
+		handleroption.WithJWTAuth("secret"),
+		handleroption.WithACL(acl.Pool{
+			"http://example.com": {
+				PathScopes: map[acl.Path][]acl.ScopePath{
+					"/": {{EmailRegexes: []acl.EmailRegex{"*@example.com"}, Methods: []acl.Method{"*"}}},
+				},
+			},
+		}),
+		handleroption.WithSecureCookie(false),
+		handleroption.WithSessionStore(store),
+	)
+	h := NewReverseProxyHandler(config, nil, option)
+	mockTransport := new(MockTransport)
+	mockTransport.On("RoundTrip", mock.Anything).
+		Return(&http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(""))}, nil)
+	h.routes.Load().proxies["http://example.com/"].Transport = mockTransport
+
+	newJWT := func(sid string) string {
+		claims := map[string]interface{}{
+			"email":          "user@example.com",
+			"allowed_scopes": map[string][]string{"/": {"*"}},
+			"sid":            sid,
+		}
+		jwtauth.SetIssuedNow(claims)
+		jwtauth.SetExpiryIn(claims, time.Hour)
+		_, tokenStr, _ := option.JWTAuth.Encode(claims)
+		return tokenStr
+	}
+	activeSessionID := session.NewID()
+	store.Create(session.Session{ID: activeSessionID, Email: "user@example.com", ExpiresAt: time.Now().Add(time.Hour)})
+	revokedSessionID := session.NewID()
+	store.Create(session.Session{ID: revokedSessionID, Email: "user@example.com", ExpiresAt: time.Now().Add(time.Hour)})
+	store.Revoke(revokedSessionID)
+
+	tests := []struct {
+		name       string
+		tokenStr   string
+		wantStatus int
+	}{
+		{"active session may be allowed", newJWT(activeSessionID), http.StatusOK},
+		{"revoked session may be requested to sign in again", newJWT(revokedSessionID), http.StatusUnauthorized},
+		{"token without session may be requested to sign in again", newJWT(""), http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			req := httptest.NewRequest(http.MethodGet, "http://example.com/dashboard", nil)
+			req.Header.Set("Accept", "application/json")
+			req.Header.Set("Authorization", "Bearer "+tt.tokenStr)
+			rec := httptest.NewRecorder()
+
+			h.ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.wantStatus, rec.Code)
+		})
+	}
+}
+
+func Test_handler_ServeHTTP_crossOrigin(t *testing.T) {
+	t.Parallel()
+
+	config := Config{Proxies: []Proxy{
+		{ExternalURL: "http://a.example.com/", Target: Target{"http://a:80"}},
+		{ExternalURL: "http://b.example.com/", Target: Target{"http://b:80"}},
+	}}
+	option, _ := handleroption.New(
+		handleroption.WithJWTAuth("secret"),
+		handleroption.WithACL(acl.Pool{
+			"http://a.example.com": {
+				PathScopes: map[acl.Path][]acl.ScopePath{
+					"/": {{EmailRegexes: []acl.EmailRegex{"serviceaccount:ci"}, Methods: []acl.Method{"*"}}},
+				},
+			},
+			"http://b.example.com": {
+				PathScopes: map[acl.Path][]acl.ScopePath{
+					"/": {{EmailRegexes: []acl.EmailRegex{"*@example.com"}, Methods: []acl.Method{"*"}}},
+				},
+			},
+		}),
+		handleroption.WithSecureCookie(false),
+	)
+	h := NewReverseProxyHandler(config, nil, option)
+	for _, externalURL := range []string{"http://a.example.com/", "http://b.example.com/"} {
+		mockTransport := new(MockTransport)
+		mockTransport.On("RoundTrip", mock.Anything).
+			Return(&http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(""))}, nil)
+		h.routes.Load().proxies[externalURL].Transport = mockTransport
+	}
+	// JWTs issued below are after the config loaded
+	loadedAt := time.Now().Add(-time.Minute)
+	h.issuedJWTAvailableSince.Store(&loadedAt)
+
+	// issued by the client credentials grant on a.example.com
+	issuedURL, _ := url.Parse("http://a.example.com/.auth/token")
+	_, tokenStr, err := h.tokenIssuer.Issue(issuedURL, jwtclaims.Claims{
+		AllowedScopes:  acl.AllowedScopes{"/": {"*"}},
+		Email:          "serviceaccount:ci",
+		ServiceAccount: &jwtclaims.ClaimsServiceAccount{ClientID: "ci"},
+	})
+	assert.NoError(t, err)
+
+	tests := []struct {
+		name       string
+		url        string
+		wantStatus int
+	}{
+		{"origin the token issued for may be allowed", "http://a.example.com/admin", http.StatusOK},
+		{"other origin may be forbidden by its own ACL", "http://b.example.com/admin", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			req := httptest.NewRequest(http.MethodDelete, tt.url, nil)
+			req.Header.Set("Accept", "application/json")
+			req.Header.Set("Authorization", "Bearer "+tokenStr)
+			rec := httptest.NewRecorder()
+
+			h.ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.wantStatus, rec.Code)
+		})
+	}
+}
+
+func Test_handler_ServeHTTP_maxAuthAge(t *testing.T) {
+	t.Parallel()
+
+	maxSessionAge := acl.Duration(24 * time.Hour)
+	config := Config{Proxies: []Proxy{
+		{ExternalURL: "http://example.com/", Target: Target{"http://web:80"}},
+	}}
+	option, _ := handleroption.New(
+		handleroption.WithJWTAuth("secret"),
+		handleroption.WithACL(acl.Pool{
+			"http://example.com": {
+				PathScopes: map[acl.Path][]acl.ScopePath{
+					"/": {{EmailRegexes: []acl.EmailRegex{"*@example.com"}, Methods: []acl.Method{"*"}}},
+				},
+				OriginConfig: acl.OriginConfig{
+					MaxSessionAge: &maxSessionAge,
+					StepUpPaths:   []acl.StepUpPath{{Path: "/admin/", MaxAuthAge: acl.Duration(10 * time.Minute)}},
+				},
+			},
+		}),
+		handleroption.WithSecureCookie(false),
+	)
+	h := NewReverseProxyHandler(config, nil, option)
+	mockTransport := new(MockTransport)
+	mockTransport.On("RoundTrip", mock.Anything).
+		Return(&http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(""))}, nil)
+	h.routes.Load().proxies["http://example.com/"].Transport = mockTransport
+
+	newJWT := func(authTime time.Time) string {
+		claims := map[string]interface{}{
+			"email":          "user@example.com",
+			"allowed_scopes": map[string][]string{"/": {"*"}},
+			"auth_time":      authTime.Unix(),
+		}
+		jwtauth.SetIssuedNow(claims)
+		jwtauth.SetExpiryIn(claims, time.Hour)
+		_, tokenStr, _ := option.JWTAuth.Encode(claims)
+		return tokenStr
+	}
+
+	tests := []struct {
+		name       string
+		path       string
+		authTime   time.Time
+		wantStatus int
+	}{
+		{"recent sign-in may be allowed", "/admin/users", time.Now().Add(-time.Minute), http.StatusOK},
+		{"step-up path may require recent sign-in", "/admin/users", time.Now().Add(-time.Hour), http.StatusFound},
+		{"non step-up path may be allowed", "/dashboard", time.Now().Add(-time.Hour), http.StatusOK},
+		{"exceeded max session age may require sign-in", "/dashboard", time.Now().Add(-25 * time.Hour), http.StatusFound},
+		{"token without auth_time may require sign-in", "/dashboard", time.Unix(0, 0), http.StatusFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			req := httptest.NewRequest(http.MethodGet, "http://example.com"+tt.path, nil)
+			req.Header.Set("Accept", "text/html")
+			req.AddCookie(&http.Cookie{Name: "jwt", Value: newJWT(tt.authTime)})
+			rec := httptest.NewRecorder()
+
+			h.ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.wantStatus, rec.Code)
+			if tt.wantStatus == http.StatusFound {
+				assert.Contains(t, rec.Header().Get("Location"), "&prompt=login")
+			}
+		})
+	}
+
+	t.Run("JSON client may receive step-up challenge", func(t *testing.T) {
+		t.Parallel()
+		req := httptest.NewRequest(http.MethodGet, "http://example.com/admin/users", nil)
+		req.Header.Set("Accept", "application/json")
+		req.Header.Set("Authorization", "Bearer "+newJWT(time.Now().Add(-time.Hour)))
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+		assert.Equal(t, `Bearer realm="http://example.com", error="insufficient_user_authentication", max_age=600`, rec.Header().Get("WWW-Authenticate"))
+	})
+}
+
+func Test_handler_ServeHTTP_audit(t *testing.T) {
+	t.Parallel()
+
+	auditLogPath := t.TempDir() + "/audit.log"
+	sink, _ := audit.NewFileSink(auditLogPath, 0, 0)
+	auditLogger := audit.New(sink)
+	config := Config{Proxies: []Proxy{
+		{ExternalURL: "http://example.com/", Target: Target{"http://web:80"}},
+	}}
+	option, _ := handleroption.New(
+		handleroption.WithJWTAuth("secret"),
+		handleroption.WithACL(acl.Pool{
+			"http://example.com": {
+				PathScopes: map[acl.Path][]acl.ScopePath{
+					"/": {{EmailRegexes: []acl.EmailRegex{"*@example.com"}, Methods: []acl.Method{"GET"}}},
+				},
+			},
+		}),
+		handleroption.WithSecureCookie(false),
+		handleroption.WithAuditLogger(auditLogger),
+	)
+	h := NewReverseProxyHandler(config, nil, option)
+
+	claims := map[string]interface{}{
+		"email":          "user@example.com",
+		"allowed_scopes": map[string][]string{"/": {"GET"}},
+	}
+	jwtauth.SetIssuedNow(claims)
+	jwtauth.SetExpiryIn(claims, time.Hour)
+	_, tokenStr, _ := option.JWTAuth.Encode(claims)
+
+	requests := []struct {
+		method   string
+		tokenStr string
+	}{
+		{http.MethodDelete, tokenStr},
+		{http.MethodGet, "invalid"},
+		// not recorded, as no credentials are given
+		{http.MethodGet, ""},
+	}
+	for _, r := range requests {
+		req := httptest.NewRequest(r.method, "http://example.com/posts/1?q=secret", nil)
+		req.Header.Set("Accept", "application/json")
+		if r.tokenStr != "" {
+			req.Header.Set("Authorization", "Bearer "+r.tokenStr)
+		}
+		h.ServeHTTP(httptest.NewRecorder(), req)
+	}
+	assert.NoError(t, auditLogger.Close(context.Background()))
+
+	data, _ := os.ReadFile(auditLogPath)
+	events := []audit.Event{}
+	for _, line := range strings.Split(strings.TrimSuffix(string(data), "\n"), "\n") {
+		var event audit.Event
+		assert.NoError(t, json.Unmarshal([]byte(line), &event))
+		event.Time = time.Time{}
+		events = append(events, event)
+	}
+	if assert.Len(t, events, 2) {
+		assert.Equal(t, audit.Event{
+			Version:    audit.SchemaVersion,
+			Type:       audit.TypeAccessDenied,
+			Actor:      "user@example.com",
+			RemoteAddr: "192.0.2.1:1234",
+			Method:     http.MethodDelete,
+			URL:        "http://example.com/posts/1",
+			Rule:       &audit.Rule{Path: "/", Methods: []string{"GET"}},
+			Reason:     "no access to the scope",
+		}, events[0])
+		assert.Equal(t, audit.TypeAccessDenied, events[1].Type)
+		assert.Empty(t, events[1].Actor)
+		assert.Contains(t, events[1].Reason, "invalid token: ")
+	}
+}
+
+func Test_handler_UpdateConfig_aclSwapped(t *testing.T) {
+	t.Parallel()
+
+	pool := func(emails ...acl.EmailRegex) acl.Pool {
+		return acl.Pool{
+			"http://example.com": {
+				PathScopes: map[acl.Path][]acl.ScopePath{
+					"/": {{EmailRegexes: emails, Methods: []acl.Method{"*"}}},
+				},
+			},
+		}
+	}
+	aclProvider := acl.NewSwappableProvider(pool("user@example.com", "admin@example.com"))
+	config := Config{Proxies: []Proxy{
+		{ExternalURL: "http://example.com/", Target: Target{"http://web:80"}},
+	}}
+	option, _ := handleroption.New(
+		handleroption.WithJWTAuth("secret"),
+		handleroption.WithACLProvider(aclProvider),
+		handleroption.WithSecureCookie(false),
+	)
+	h := NewReverseProxyHandler(config, nil, option)
+	// JWTs issued below are after the config loaded
+	loadedAt := time.Now().Add(-time.Minute)
+	h.issuedJWTAvailableSince.Store(&loadedAt)
+
+	claims := map[string]interface{}{
+		"email":          "user@example.com",
+		"allowed_scopes": map[string][]string{"/": {"*"}},
+		"iat":            time.Now().Add(-time.Second).Unix(),
+	}
+	jwtauth.SetExpiryIn(claims, time.Hour)
+	_, tokenStr, _ := option.JWTAuth.Encode(claims)
+
+	serve := func() int {
+		mockTransport := new(MockTransport)
+		mockTransport.On("RoundTrip", mock.Anything).
+			Return(&http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(""))}, nil)
+		h.routes.Load().proxies["http://example.com/"].Transport = mockTransport
+
+		req := httptest.NewRequest(http.MethodGet, "http://example.com/dashboard", nil)
+		req.Header.Set("Accept", "application/json")
+		req.Header.Set("Authorization", "Bearer "+tokenStr)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	assert.Equal(t, http.StatusOK, serve())
+
+	// the user is removed from the ACL (e.g. `oauth2rbac/allow` of the Ingress changed)
+	aclProvider.Swap(pool("admin@example.com"))
+	h.UpdateConfig(config)
+
+	assert.Equal(t, http.StatusForbidden, serve(), "JWT issued before the ACL swapped may be re-evaluated")
+}
@@ -0,0 +1,40 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// StartShutdown fails the health check, so that load balancers stop sending requests before the server is shut down.
+func (h *Handler) StartShutdown() {
+	h.shuttingDown.Store(true)
+}
+
+// InFlightRequests returns the number of requests being served.
+func (h *Handler) InFlightRequests() int64 {
+	return h.inFlight.Load()
+}
+
+// WaitRequests waits until all requests are served, or the context is done.
+// Upgraded connections (e.g. WebSocket) are not waited by http.Server.Shutdown, but are served until closed.
+func (h *Handler) WaitRequests(ctx context.Context) error {
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+	for h.inFlight.Load() != 0 {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+func (h *Handler) trackRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h.inFlight.Add(1)
+		defer h.inFlight.Add(-1)
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,68 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/tingtt/oauth2rbac/internal/acl"
+	reverseproxy "github.com/tingtt/oauth2rbac/internal/api/handler/reverse_proxy"
+	handleroption "github.com/tingtt/oauth2rbac/internal/api/handler/util/option"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHandler_StartShutdown(t *testing.T) {
+	t.Parallel()
+
+	h, err := New(nil, reverseproxy.Config{},
+		handleroption.WithJWTAuth("secret"),
+		handleroption.WithACL(acl.Pool{}),
+		handleroption.WithSecureCookie(false),
+	)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	rw := httptest.NewRecorder()
+	h.ServeHTTP(rw, httptest.NewRequest(http.MethodGet, "http://example.com/healthz", nil))
+	assert.Equal(t, http.StatusOK, rw.Code)
+
+	h.StartShutdown()
+
+	rw = httptest.NewRecorder()
+	h.ServeHTTP(rw, httptest.NewRequest(http.MethodGet, "http://example.com/healthz", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, rw.Code)
+	assert.Equal(t, "shutting down", rw.Body.String())
+}
+
+func TestHandler_WaitRequests(t *testing.T) {
+	t.Parallel()
+
+	h := &Handler{}
+	release := make(chan struct{})
+	served := make(chan struct{})
+	handler := h.trackRequests(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	go func() {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "http://example.com/ws", nil))
+		close(served)
+	}()
+	assert.Eventually(t, func() bool { return h.InFlightRequests() == 1 }, time.Second, 10*time.Millisecond)
+
+	t.Run("may time out with requests in flight", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+		defer cancel()
+		assert.ErrorIs(t, h.WaitRequests(ctx), context.DeadlineExceeded)
+	})
+
+	t.Run("may return when requests are served", func(t *testing.T) {
+		close(release)
+		<-served
+		assert.NoError(t, h.WaitRequests(context.Background()))
+		assert.Equal(t, int64(0), h.InFlightRequests())
+	})
+}
@@ -0,0 +1,81 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/tingtt/oauth2rbac/internal/acl"
+	reverseproxy "github.com/tingtt/oauth2rbac/internal/api/handler/reverse_proxy"
+	handleroption "github.com/tingtt/oauth2rbac/internal/api/handler/util/option"
+	"github.com/tingtt/oauth2rbac/internal/tracing"
+
+	"github.com/go-chi/jwtauth/v5"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+// not parallel, as the provider is registered globally
+func TestHandler_tracing(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	provider, _ := tracing.NewTracerProvider(context.Background(), exporter)
+	tracing.Register(provider, tracing.Option{RecordUserEmail: true})
+	t.Cleanup(func() { tracing.Register(noop.NewTracerProvider(), tracing.Option{}) })
+
+	traceparents := make(chan string, 1)
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparents <- r.Header.Get("traceparent")
+	}))
+	t.Cleanup(upstream.Close)
+
+	h, err := New(nil, reverseproxy.Config{Proxies: []reverseproxy.Proxy{
+		{ExternalURL: "http://tracing.example.com/", Target: reverseproxy.Target{URL: upstream.URL}},
+	}},
+		handleroption.WithJWTAuth("secret"),
+		handleroption.WithACL(acl.Pool{"http://tracing.example.com": {PathScopes: map[acl.Path][]acl.ScopePath{
+			"/": {{Methods: []acl.Method{"GET"}, EmailRegexes: []acl.EmailRegex{"*@example.com"}}},
+		}}}),
+		handleroption.WithSecureCookie(false),
+	)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	claims := map[string]interface{}{"email": "user@example.com", "allowed_scopes": map[string][]string{"/": {"GET"}}}
+	jwtauth.SetIssuedNow(claims)
+	jwtauth.SetExpiryIn(claims, time.Hour)
+	_, tokenStr, _ := jwtauth.New("HS256", []byte("secret"), nil).Encode(claims)
+
+	req := httptest.NewRequest(http.MethodGet, "http://tracing.example.com/", nil)
+	req.Header.Set("Authorization", "Bearer "+tokenStr)
+	req.Header.Set("traceparent", "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01")
+	rw := httptest.NewRecorder()
+	h.ServeHTTP(rw, req)
+	assert.Equal(t, http.StatusOK, rw.Code)
+	provider.ForceFlush(context.Background())
+
+	traceID, _ := trace.TraceIDFromHex("0af7651916cd43dd8448eb211c80319c")
+	names := []string{}
+	for _, span := range exporter.GetSpans() {
+		if span.SpanContext.TraceID() != traceID {
+			continue
+		}
+		names = append(names, span.Name)
+		if span.SpanKind == trace.SpanKindServer {
+			assert.Contains(t, span.Attributes, semconv.EnduserID("user@example.com"))
+		}
+	}
+	assert.ElementsMatch(t, []string{"GET", "acl.LoginRequired", "jwt.Decode", "acl.AllowedScopes", "jwt.Renew", "HTTP GET"}, names)
+
+	select {
+	case traceparent := <-traceparents:
+		assert.Regexp(t, "^00-0af7651916cd43dd8448eb211c80319c-[0-9a-f]{16}-01$", traceparent, "propagated to the upstream")
+	default:
+		t.Fatal("upstream not requested")
+	}
+}
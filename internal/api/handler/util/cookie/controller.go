@@ -2,8 +2,6 @@ package cookieutil
 
 import (
 	"net/http"
-	"net/url"
-	"strings"
 	"time"
 
 	logutil "github.com/tingtt/oauth2rbac/internal/api/handler/util/log"
@@ -15,7 +13,9 @@ const (
 
 type Controller interface {
 	SetRedirectURLForAfterLogin(res *logutil.CustomResponseWriter, reqURL string)
-	SetJWT(rw http.ResponseWriter, jwt string)
+	// SetJWT sets the JWT cookie expiring at expiresAt. If expiresAt is zero, it sets a session cookie.
+	SetJWT(rw http.ResponseWriter, jwt string, expiresAt time.Time)
+	DeleteJWT(rw http.ResponseWriter)
 }
 
 func NewController(secure bool) Controller {
@@ -27,10 +27,6 @@ type controller struct {
 }
 
 func (c *controller) SetRedirectURLForAfterLogin(res *logutil.CustomResponseWriter, reqURL string) {
-	if c.skipSetRedirectURLForAfterLogin(reqURL) {
-		return
-	}
-
 	http.SetCookie(res, &http.Cookie{
 		Name:     COOKIE_KEY_REDIRECT_URL_FOR_AFTER_LOGIN,
 		Value:    reqURL,
@@ -43,22 +39,30 @@ func (c *controller) SetRedirectURLForAfterLogin(res *logutil.CustomResponseWrit
 	})
 }
 
-func (c *controller) skipSetRedirectURLForAfterLogin(reqURL string) bool {
-	url, _ := url.Parse(reqURL)
-	return url.Path == "/favicon.ico" ||
-		strings.HasPrefix(url.Path, "/api/") ||
-		strings.HasPrefix(url.Path, "/.well-known/") ||
-		strings.HasPrefix(url.Path, "/_next/") ||
-		strings.HasSuffix(url.Path, ".svg")
+func (c *controller) SetJWT(rw http.ResponseWriter, jwt string, expiresAt time.Time) {
+	maxAge := 0 /* session cookie */
+	if !expiresAt.IsZero() {
+		maxAge = max(int(time.Until(expiresAt)/time.Second), 1)
+	}
+	http.SetCookie(rw, &http.Cookie{
+		Name:     "jwt",
+		Value:    jwt,
+		Path:     "/",
+		Domain:   "",
+		MaxAge:   maxAge,
+		Secure:   c.useSecure,
+		HttpOnly: true,
+		SameSite: http.SameSiteStrictMode,
+	})
 }
 
-func (c *controller) SetJWT(rw http.ResponseWriter, jwt string) {
+func (c *controller) DeleteJWT(rw http.ResponseWriter) {
 	http.SetCookie(rw, &http.Cookie{
 		Name:     "jwt",
-		Value:    jwt,
+		Value:    "",
 		Path:     "/",
 		Domain:   "",
-		MaxAge:   int(time.Hour / time.Second),
+		MaxAge:   -1,
 		Secure:   c.useSecure,
 		HttpOnly: true,
 		SameSite: http.SameSiteStrictMode,
@@ -0,0 +1,27 @@
+package corsutil
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// SetHeaders sets the CORS response headers if the request origin is allowed.
+// It reports whether the request is a preflight request, which requires no further response.
+func SetHeaders(rw http.ResponseWriter, req *http.Request, allowedOrigins []string, allowedMethods ...string) (preflight bool) {
+	rw.Header().Add("Vary", "Origin")
+	origin := req.Header.Get("Origin")
+	if origin == "" || !slices.Contains(allowedOrigins, origin) {
+		return false
+	}
+
+	rw.Header().Set("Access-Control-Allow-Origin", origin)
+	rw.Header().Set("Access-Control-Allow-Credentials", "true")
+	if req.Method != http.MethodOptions || req.Header.Get("Access-Control-Request-Method") == "" {
+		return false
+	}
+	rw.Header().Set("Access-Control-Allow-Methods", strings.Join(allowedMethods, ", "))
+	rw.Header().Set("Access-Control-Allow-Headers", "Authorization, X-Requested-With")
+	rw.Header().Set("Access-Control-Max-Age", "600")
+	return true
+}
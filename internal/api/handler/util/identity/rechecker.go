@@ -0,0 +1,108 @@
+package identityutil
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"time"
+
+	handleroption "github.com/tingtt/oauth2rbac/internal/api/handler/util/option"
+	"github.com/tingtt/oauth2rbac/internal/oauth2"
+	"github.com/tingtt/oauth2rbac/internal/oauth2/userinfo"
+	"github.com/tingtt/oauth2rbac/internal/session"
+	"github.com/tingtt/oauth2rbac/pkg/jwtclaims"
+
+	xoauth2 "golang.org/x/oauth2"
+	"golang.org/x/sync/singleflight"
+)
+
+var (
+	errProviderTokenNotFound = errors.New("provider token not found")
+	errEmailChanged          = errors.New("email changed")
+)
+
+// recheckGroup is shared by the Recheckers of the handlers (e.g. the proxies and `/.auth/userinfo`),
+// so that requests of the same session share the recheck, not to refresh the provider token concurrently.
+var recheckGroup singleflight.Group
+
+// Rechecker re-validates identities of sessions with the OAuth2 providers, on requests with JWTs of the sessions.
+type Rechecker struct {
+	sessions session.Store
+	oauth2   map[string]oauth2.Service
+	interval time.Duration
+}
+
+func NewRechecker(oauth2 map[string]oauth2.Service, option *handleroption.Option) *Rechecker {
+	return &Rechecker{option.SessionStore, oauth2, option.IdentityRecheckInterval}
+}
+
+// Recheck re-validates the identity of the session with the OAuth2 provider,
+// once the interval has passed since the last validation.
+// The provider token is refreshed, and the session is revoked if the user lost the account or the email.
+// Transient errors (e.g. network errors, 5xx of the provider) do not fail the recheck, and it is retried on the next request.
+func (r *Rechecker) Recheck(ctx context.Context, claims jwtclaims.Claims) error {
+	if r.sessions == nil || r.interval == 0 || claims.ServiceAccount != nil {
+		return nil
+	}
+	if s, err := r.sessions.Get(claims.SessionID); err != nil || time.Since(s.VerifiedAt) < r.interval {
+		return err
+	}
+
+	_, err, _ := recheckGroup.Do(claims.SessionID, func() (any, error) {
+		s, err := r.sessions.Get(claims.SessionID)
+		if err != nil {
+			return nil, err
+		}
+		if /* already re-validated by other request */ time.Since(s.VerifiedAt) < r.interval {
+			return nil, nil
+		}
+
+		err = r.verify(ctx, s)
+		if err != nil && identityLost(err) {
+			if err := r.sessions.Revoke(s.ID); err != nil {
+				slog.Error("failed to revoke session", slog.String("sid", s.ID), slog.String("err", err.Error()))
+			}
+		}
+		return nil, err
+	})
+	if err != nil && !identityLost(err) && !errors.Is(err, session.ErrNotFound) {
+		slog.Warn("identity recheck skipped", slog.String("sid", claims.SessionID), slog.String("err", err.Error()))
+		return nil
+	}
+	return err
+}
+
+func (r *Rechecker) verify(ctx context.Context, s *session.Session) error {
+	provider, supported := r.oauth2[s.Provider]
+	if !supported || s.ProviderToken == nil || (s.ProviderToken.RefreshToken == "" && !s.ProviderToken.Valid()) {
+		return fmt.Errorf("%w (provider: `%s`)", errProviderTokenNotFound, s.Provider)
+	}
+	providerToken, err := provider.TokenSource(ctx, s.ProviderToken).Token()
+	if err != nil {
+		return fmt.Errorf("failed to refresh provider token: %w", err)
+	}
+	userInfo, err := provider.GetUserInfo(ctx, providerToken)
+	if err != nil {
+		return fmt.Errorf("failed to get userinfo: %w", err)
+	}
+	if userInfo.Email != s.Email && !slices.Contains(userInfo.SecondaryEmails, s.Email) {
+		return errEmailChanged
+	}
+	return r.sessions.Verify(s.ID, providerToken, time.Now())
+}
+
+// identityLost reports whether the recheck failed definitively. (e.g. `invalid_grant` on refresh, 401 on userinfo, changed email)
+func identityLost(err error) bool {
+	var retrieveErr *xoauth2.RetrieveError
+	if errors.As(err, &retrieveErr) {
+		return retrieveErr.ErrorCode == "invalid_grant"
+	}
+	var statusErr userinfo.StatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode == http.StatusUnauthorized
+	}
+	return errors.Is(err, errProviderTokenNotFound) || errors.Is(err, errEmailChanged)
+}
@@ -0,0 +1,175 @@
+package identityutil
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/tingtt/oauth2rbac/internal/acl"
+	handleroption "github.com/tingtt/oauth2rbac/internal/api/handler/util/option"
+	"github.com/tingtt/oauth2rbac/internal/oauth2"
+	"github.com/tingtt/oauth2rbac/internal/oauth2/userinfo"
+	"github.com/tingtt/oauth2rbac/internal/session"
+	"github.com/tingtt/oauth2rbac/pkg/jwtclaims"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	xoauth2 "golang.org/x/oauth2"
+)
+
+type MockOAuth2Service struct {
+	oauth2.Service
+	mock.Mock
+}
+
+func (m *MockOAuth2Service) TokenSource(ctx context.Context, token *xoauth2.Token) xoauth2.TokenSource {
+	if token.AccessToken == "refresh-token-revoked" {
+		return tokenSourceFunc(func() (*xoauth2.Token, error) {
+			return nil, &xoauth2.RetrieveError{ErrorCode: "invalid_grant"}
+		})
+	}
+	return xoauth2.StaticTokenSource(token)
+}
+
+type tokenSourceFunc func() (*xoauth2.Token, error)
+
+func (f tokenSourceFunc) Token() (*xoauth2.Token, error) { return f() }
+
+func (m *MockOAuth2Service) GetUserInfo(ctx context.Context, token *xoauth2.Token) (oauth2.UserInfo, error) {
+	args := m.Called(token.AccessToken)
+	return oauth2.UserInfo{Username: args.String(0), Email: args.String(1)}, args.Error(2)
+}
+
+func TestRechecker(t *testing.T) {
+	t.Parallel()
+
+	service := new(MockOAuth2Service)
+	service.On("GetUserInfo", "active").Return("user", "user@example.com", nil)
+	service.On("GetUserInfo", "email-changed").Return("user", "other@example.com", nil)
+	service.On("GetUserInfo", "disabled").Return("", "", fmt.Errorf("failed to get email: %w", userinfo.StatusError{StatusCode: 401}))
+	service.On("GetUserInfo", "provider-unavailable").Return("", "", userinfo.StatusError{StatusCode: 503})
+	service.On("GetUserInfo", "network-error").Return("", "", errors.New("dial tcp: connection refused"))
+
+	store := session.NewMemoryStore()
+	option, _ := handleroption.New(
+		handleroption.WithJWTAuth("secret"),
+		handleroption.WithACL(acl.Pool{}),
+		handleroption.WithSecureCookie(false),
+		handleroption.WithSessionStore(store),
+		handleroption.WithIdentityRecheckInterval(time.Hour),
+	)
+	r := NewRechecker(map[string]oauth2.Service{"google": service}, option)
+
+	newSession := func(accessToken string, verifiedAt time.Time) string {
+		id := session.NewID()
+		store.Create(session.Session{
+			ID:            id,
+			Email:         "user@example.com",
+			Provider:      "google",
+			ExpiresAt:     time.Now().Add(time.Hour),
+			ProviderToken: &xoauth2.Token{AccessToken: accessToken},
+			VerifiedAt:    verifiedAt,
+		})
+		return id
+	}
+
+	tests := []struct {
+		name         string
+		sessionID    string
+		wantErr      bool
+		wantRevoked  bool
+		wantVerified bool
+	}{
+		{"recently verified session may not be rechecked", newSession("disabled", time.Now()), false, false, true},
+		{"active account may be verified", newSession("active", time.Now().Add(-2*time.Hour)), false, false, true},
+		{"changed email may revoke session", newSession("email-changed", time.Now().Add(-2*time.Hour)), true, true, false},
+		{"disabled account may revoke session", newSession("disabled", time.Now().Add(-2*time.Hour)), true, true, false},
+		{"revoked refresh token may revoke session", newSession("refresh-token-revoked", time.Now().Add(-2*time.Hour)), true, true, false},
+		{"5xx of provider may not revoke session", newSession("provider-unavailable", time.Now().Add(-2*time.Hour)), false, false, false},
+		{"network error may not revoke session", newSession("network-error", time.Now().Add(-2*time.Hour)), false, false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			err := r.Recheck(context.Background(), jwtclaims.Claims{Email: "user@example.com", SessionID: tt.sessionID})
+
+			assert.Equal(t, tt.wantErr, err != nil)
+			s, err := store.Get(tt.sessionID)
+			if tt.wantRevoked {
+				assert.ErrorIs(t, err, session.ErrNotFound)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantVerified, time.Since(s.VerifiedAt) < time.Minute)
+		})
+	}
+}
+
+type blockingOAuth2Service struct {
+	MockOAuth2Service
+	calls   atomic.Int32
+	started chan struct{}
+	unblock chan struct{}
+}
+
+func (m *blockingOAuth2Service) GetUserInfo(ctx context.Context, token *xoauth2.Token) (oauth2.UserInfo, error) {
+	m.calls.Add(1)
+	if token.AccessToken == "slow-provider" {
+		m.started <- struct{}{}
+		<-m.unblock
+	}
+	return oauth2.UserInfo{Username: "user", Email: "user@example.com"}, nil
+}
+
+func TestRechecker_concurrent(t *testing.T) {
+	t.Parallel()
+
+	service := &blockingOAuth2Service{started: make(chan struct{}), unblock: make(chan struct{})}
+	store := session.NewMemoryStore()
+	option, _ := handleroption.New(
+		handleroption.WithJWTAuth("secret"),
+		handleroption.WithACL(acl.Pool{}),
+		handleroption.WithSecureCookie(false),
+		handleroption.WithSessionStore(store),
+		handleroption.WithIdentityRecheckInterval(time.Hour),
+	)
+	r := NewRechecker(map[string]oauth2.Service{"google": service}, option)
+
+	newSession := func(accessToken string) string {
+		id := session.NewID()
+		store.Create(session.Session{
+			ID:            id,
+			Email:         "user@example.com",
+			Provider:      "google",
+			ExpiresAt:     time.Now().Add(time.Hour),
+			ProviderToken: &xoauth2.Token{AccessToken: accessToken},
+			VerifiedAt:    time.Now().Add(-2 * time.Hour),
+		})
+		return id
+	}
+	slowSessionID, sessionID := newSession("slow-provider"), newSession("active")
+
+	var wg sync.WaitGroup
+	for range 5 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, r.Recheck(context.Background(), jwtclaims.Claims{Email: "user@example.com", SessionID: slowSessionID}))
+		}()
+	}
+	<-service.started
+
+	t.Run("other sessions may not wait for the recheck in flight", func(t *testing.T) {
+		assert.NoError(t, r.Recheck(context.Background(), jwtclaims.Claims{Email: "user@example.com", SessionID: sessionID}))
+	})
+
+	close(service.unblock)
+	wg.Wait()
+	t.Run("requests of the same session may share the recheck", func(t *testing.T) {
+		assert.Equal(t, int32(2), service.calls.Load())
+	})
+}
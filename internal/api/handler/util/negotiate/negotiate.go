@@ -0,0 +1,46 @@
+package negotiateutil
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// WantsJSON reports whether the client expects a JSON response instead of an HTML page.
+// (e.g. `fetch`/XHR calls from frontends, or API clients)
+func WantsJSON(req *http.Request) bool {
+	if req.Header.Get("X-Requested-With") != "" {
+		return true
+	}
+	return prefersJSON(req.Header.Get("Accept"))
+}
+
+func prefersJSON(accept string) bool {
+	var jsonQ, htmlQ float64
+	for _, mediaRange := range strings.Split(accept, ",") {
+		mediaType, q := parseMediaRange(mediaRange)
+		switch {
+		case mediaType == "application/json" || strings.HasSuffix(mediaType, "+json"):
+			jsonQ = max(jsonQ, q)
+		case mediaType == "text/html" || mediaType == "application/xhtml+xml":
+			htmlQ = max(htmlQ, q)
+		}
+	}
+	return jsonQ > 0 && jsonQ >= htmlQ
+}
+
+func parseMediaRange(mediaRange string) (mediaType string, q float64) {
+	params := strings.Split(mediaRange, ";")
+	mediaType = strings.ToLower(strings.TrimSpace(params[0]))
+	q = 1
+	for _, param := range params[1:] {
+		key, value, found := strings.Cut(strings.TrimSpace(param), "=")
+		if !found || strings.ToLower(strings.TrimSpace(key)) != "q" {
+			continue
+		}
+		if parsed, err := strconv.ParseFloat(strings.TrimSpace(value), 64); err == nil {
+			q = parsed
+		}
+	}
+	return mediaType, q
+}
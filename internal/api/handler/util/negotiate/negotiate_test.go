@@ -0,0 +1,56 @@
+package negotiateutil
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWantsJSON(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		header http.Header
+		want   bool
+	}{
+		{
+			name:   "browser navigation",
+			header: http.Header{"Accept": {"text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8"}},
+			want:   false,
+		},
+		{
+			name:   "no accept header",
+			header: http.Header{},
+			want:   false,
+		},
+		{
+			name:   "accept json",
+			header: http.Header{"Accept": {"application/json"}},
+			want:   true,
+		},
+		{
+			name:   "accept json suffix",
+			header: http.Header{"Accept": {"application/problem+json"}},
+			want:   true,
+		},
+		{
+			name:   "prefer html over json",
+			header: http.Header{"Accept": {"application/json;q=0.5, text/html"}},
+			want:   false,
+		},
+		{
+			name:   "xhr",
+			header: http.Header{"X-Requested-With": {"XMLHttpRequest"}},
+			want:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			req := &http.Request{Header: tt.header}
+			assert.Equal(t, tt.want, WantsJSON(req))
+		})
+	}
+}
@@ -3,10 +3,17 @@ package handleroption
 import (
 	"errors"
 	"log/slog"
+	"time"
 
 	"github.com/tingtt/oauth2rbac/internal/acl"
+	"github.com/tingtt/oauth2rbac/internal/api/handler/oauth2/ui"
 	cookieutil "github.com/tingtt/oauth2rbac/internal/api/handler/util/cookie"
 	"github.com/tingtt/oauth2rbac/internal/api/middleware/jwt"
+	"github.com/tingtt/oauth2rbac/internal/apitoken"
+	"github.com/tingtt/oauth2rbac/internal/audit"
+	"github.com/tingtt/oauth2rbac/internal/health"
+	"github.com/tingtt/oauth2rbac/internal/serviceaccount"
+	"github.com/tingtt/oauth2rbac/internal/session"
 
 	"github.com/go-chi/jwtauth/v5"
 	"github.com/tingtt/options"
@@ -31,6 +38,19 @@ type Option struct {
 	JWTAuth          *jwtauth.JWTAuth
 	ACLProvider      acl.Provider
 	CookieController cookieutil.Controller
+	ErrorPages       *ui.ErrorPages
+	APITokenStore    apitoken.Store
+	ServiceAccounts  serviceaccount.Pool
+	SessionStore     session.Store
+	Admins           []acl.EmailRegex
+	// IdentityRecheckInterval is the interval to re-validate identities of sessions with OAuth2 providers.
+	IdentityRecheckInterval time.Duration
+	// ReadinessChecks is the checks of `/readyz` added to the checks of the handler. (e.g. config loaded)
+	ReadinessChecks health.All
+	// CheckUpstreams enables to check the connections to the targets of the proxies in `/readyz`.
+	CheckUpstreams bool
+	// AuditLogger records authentication and authorization decisions, and admin actions. (discarded if nil)
+	AuditLogger *audit.Logger
 }
 
 type Applier = options.Applier[Option]
@@ -41,9 +61,39 @@ func WithJWTAuth(jwtSecret string) Applier {
 func WithACL(allowlist acl.Pool) Applier {
 	return func(o *Option) { o.ACLProvider = acl.NewProvider(allowlist) }
 }
+func WithACLProvider(provider acl.Provider) Applier {
+	return func(o *Option) { o.ACLProvider = provider }
+}
 func WithSecureCookie(useSecure bool) Applier {
 	if !useSecure {
 		slog.Warn("using insecure Cookie")
 	}
 	return func(o *Option) { o.CookieController = cookieutil.NewController(useSecure) }
 }
+func WithErrorPages(errorPages *ui.ErrorPages) Applier {
+	return func(o *Option) { o.ErrorPages = errorPages }
+}
+func WithAPITokenStore(store apitoken.Store) Applier {
+	return func(o *Option) { o.APITokenStore = store }
+}
+func WithServiceAccounts(serviceAccounts serviceaccount.Pool) Applier {
+	return func(o *Option) { o.ServiceAccounts = serviceAccounts }
+}
+func WithSessionStore(store session.Store) Applier {
+	return func(o *Option) { o.SessionStore = store }
+}
+func WithAdmins(admins []acl.EmailRegex) Applier {
+	return func(o *Option) { o.Admins = admins }
+}
+func WithIdentityRecheckInterval(interval time.Duration) Applier {
+	return func(o *Option) { o.IdentityRecheckInterval = interval }
+}
+func WithReadinessChecks(checks health.All) Applier {
+	return func(o *Option) { o.ReadinessChecks = checks }
+}
+func WithUpstreamHealthCheck(enabled bool) Applier {
+	return func(o *Option) { o.CheckUpstreams = enabled }
+}
+func WithAuditLogger(logger *audit.Logger) Applier {
+	return func(o *Option) { o.AuditLogger = logger }
+}
@@ -0,0 +1,76 @@
+package tokenutil
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/tingtt/oauth2rbac/internal/acl"
+	cookieutil "github.com/tingtt/oauth2rbac/internal/api/handler/util/cookie"
+	handleroption "github.com/tingtt/oauth2rbac/internal/api/handler/util/option"
+	jwtmiddleware "github.com/tingtt/oauth2rbac/internal/api/middleware/jwt"
+	"github.com/tingtt/oauth2rbac/pkg/jwtclaims"
+
+	"github.com/go-chi/jwtauth/v5"
+	"github.com/lestrrat-go/jwx/v2/jwt"
+)
+
+// Issuer issues JWTs on login and renewal, with the expiry configured for the origin.
+type Issuer struct {
+	jwt    *jwtauth.JWTAuth
+	acl    acl.Provider
+	cookie cookieutil.Controller
+}
+
+func NewIssuer(option *handleroption.Option) *Issuer {
+	return &Issuer{option.JWTAuth, option.ACLProvider, option.CookieController}
+}
+
+// ExpiryIn returns `jwt_expiry_in` of the origin, or jwtmiddleware.DefaultExpiry if not configured.
+func (i *Issuer) ExpiryIn(reqURL *url.URL) time.Duration {
+	if originConfig := i.acl.OriginConfig(reqURL); originConfig != nil && originConfig.JWTExpiryIn != nil {
+		return time.Duration(*originConfig.JWTExpiryIn)
+	}
+	return jwtmiddleware.DefaultExpiry
+}
+
+// Issue signs the claims for the origin, issued now and expiring in `jwt_expiry_in` of the origin.
+// The origin is set to `aud`, since the allowed scopes and roles in the claims are only valid for the origin.
+func (i *Issuer) Issue(reqURL *url.URL, claims jwtclaims.Claims) (jwt.Token, string, error) {
+	claimsMap, err := mapCollect(claims)
+	if err != nil {
+		return nil, "", err
+	}
+	claimsMap[jwt.AudienceKey] = reqURL.Scheme + "://" + reqURL.Host
+	jwtauth.SetIssuedNow(claimsMap)
+	jwtauth.SetExpiryIn(claimsMap, i.ExpiryIn(reqURL))
+	token, tokenStr, err := i.jwt.Encode(claimsMap)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to encode jwt token: %w", err)
+	}
+	return token, tokenStr, nil
+}
+
+// SetCookie sets the token to the cookie expiring with the token.
+// If `session_cookie` of the origin is enabled, the cookie is removed when the browser is closed instead.
+func (i *Issuer) SetCookie(rw http.ResponseWriter, reqURL *url.URL, token jwt.Token, tokenStr string) {
+	if originConfig := i.acl.OriginConfig(reqURL); originConfig != nil && originConfig.SessionCookie {
+		i.cookie.SetJWT(rw, tokenStr, time.Time{})
+		return
+	}
+	i.cookie.SetJWT(rw, tokenStr, token.Expiration())
+}
+
+func mapCollect(claims jwtclaims.Claims) (map[string]any, error) {
+	bytes, err := json.Marshal(claims)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal claims to json: %w", err)
+	}
+	mapped := map[string]any{}
+	if err := json.Unmarshal(bytes, &mapped); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal json to map: %w", err)
+	}
+	return mapped, nil
+}
@@ -0,0 +1,59 @@
+package tokenutil
+
+import (
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/tingtt/oauth2rbac/internal/acl"
+	handleroption "github.com/tingtt/oauth2rbac/internal/api/handler/util/option"
+	jwtmiddleware "github.com/tingtt/oauth2rbac/internal/api/middleware/jwt"
+	"github.com/tingtt/oauth2rbac/pkg/jwtclaims"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIssuer(t *testing.T) {
+	t.Parallel()
+
+	expiryIn := acl.JWTExpiryIn(12 * time.Hour)
+	option, _ := handleroption.New(
+		handleroption.WithJWTAuth("secret"),
+		handleroption.WithACL(acl.Pool{
+			"http://example.com":         {OriginConfig: acl.OriginConfig{JWTExpiryIn: &expiryIn}},
+			"http://session.example.com": {OriginConfig: acl.OriginConfig{SessionCookie: true}},
+		}),
+		handleroption.WithSecureCookie(false),
+	)
+	issuer := NewIssuer(option)
+
+	tests := []struct {
+		name         string
+		url          string
+		wantExpiryIn time.Duration
+		wantMaxAge   int
+	}{
+		{"may honor jwt_expiry_in", "http://example.com/", 12 * time.Hour, int(12 * time.Hour / time.Second)},
+		{"may use default expiry", "http://default.example.com/", jwtmiddleware.DefaultExpiry, int(jwtmiddleware.DefaultExpiry / time.Second)},
+		{"may set session cookie", "http://session.example.com/", jwtmiddleware.DefaultExpiry, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			reqURL, _ := url.Parse(tt.url)
+
+			token, tokenStr, err := issuer.Issue(reqURL, jwtclaims.Claims{Email: "user@example.com"})
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantExpiryIn, token.Expiration().Sub(token.IssuedAt()))
+
+			rec := httptest.NewRecorder()
+			issuer.SetCookie(rec, reqURL, token, tokenStr)
+			cookies := rec.Result().Cookies()
+			if assert.Len(t, cookies, 1) {
+				assert.Equal(t, tokenStr, cookies[0].Value)
+				assert.InDelta(t, tt.wantMaxAge, cookies[0].MaxAge, 1)
+			}
+		})
+	}
+}
@@ -1,6 +1,8 @@
 package jwt
 
 import (
+	"net/http"
+	"strings"
 	"time"
 
 	"github.com/go-chi/jwtauth/v5"
@@ -11,3 +13,12 @@ func NewAuth(signKey string) *jwtauth.JWTAuth {
 }
 
 var DefaultExpiry = time.Hour * 3
+
+// TokenFromRequest returns the token string from the `Authorization: Bearer` header or the `jwt` cookie.
+func TokenFromRequest(req *http.Request) string {
+	authorizationToken, _ := strings.CutPrefix(req.Header.Get("Authorization"), "Bearer ")
+	if authorizationToken != "" {
+		return authorizationToken
+	}
+	return jwtauth.TokenFromCookie(req)
+}
@@ -0,0 +1,65 @@
+package apitoken
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/tingtt/oauth2rbac/internal/acl"
+)
+
+// Prefix is the prefix of API token strings, distinguishing them from JWTs.
+const Prefix = "oauth2rbac_"
+
+var ErrNotFound = errors.New("api token not found")
+
+// Token is a user-managed long-lived API token.
+// Only the hash of the token string is stored.
+type Token struct {
+	ID        string                                     `json:"id"`
+	Name      string                                     `json:"name"`
+	Email     string                                     `json:"email"`
+	Hash      string                                     `json:"hash,omitempty"`
+	Scopes    map[ /* origin */ string]acl.AllowedScopes `json:"scopes"`
+	CreatedAt time.Time                                  `json:"created_at"`
+	ExpiresAt *time.Time                                 `json:"expires_at,omitempty"`
+}
+
+func (t Token) Expired(now time.Time) bool {
+	return t.ExpiresAt != nil && !now.Before(*t.ExpiresAt)
+}
+
+// Store persists API tokens.
+type Store interface {
+	Create(token Token) error
+	List(email string) ([]Token, error)
+	FindByHash(hash string) (*Token, error)
+	Revoke(email, id string) error
+}
+
+// Generate returns a new token string and its hash.
+func Generate() (tokenStr string, hash string) {
+	b := make([]byte, 32)
+	rand.Read(b)
+	tokenStr = Prefix + base64.RawURLEncoding.EncodeToString(b)
+	return tokenStr, Hash(tokenStr)
+}
+
+func Hash(tokenStr string) string {
+	sum := sha256.Sum256([]byte(tokenStr))
+	return hex.EncodeToString(sum[:])
+}
+
+func IsAPIToken(tokenStr string) bool {
+	return strings.HasPrefix(tokenStr, Prefix)
+}
+
+func NewID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,111 @@
+package apitoken
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+)
+
+// NewFileStore returns a Store persisting tokens to the JSON file.
+// The file is created if not exists.
+func NewFileStore(filePath string) (Store, error) {
+	s := &fileStore{filePath: filePath}
+	if err := s.load(); err != nil {
+		return nil, fmt.Errorf("failed to load api tokens: %w", err)
+	}
+	return s, nil
+}
+
+type fileStore struct {
+	filePath string
+	mu       sync.RWMutex
+	tokens   []Token
+}
+
+func (s *fileStore) load() error {
+	data, err := os.ReadFile(s.filePath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, &s.tokens)
+}
+
+// save writes tokens to the file atomically. The caller must hold the lock.
+func (s *fileStore) save(tokens []Token) error {
+	data, err := json.MarshalIndent(tokens, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(s.filePath), filepath.Base(s.filePath)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0600); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), s.filePath); err != nil {
+		return err
+	}
+	s.tokens = tokens
+	return nil
+}
+
+// Create implements Store.
+func (s *fileStore) Create(token Token) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.save(append(slices.Clone(s.tokens), token))
+}
+
+// List implements Store.
+func (s *fileStore) List(email string) ([]Token, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	tokens := []Token{}
+	for _, token := range s.tokens {
+		if token.Email == email {
+			tokens = append(tokens, token)
+		}
+	}
+	return tokens, nil
+}
+
+// FindByHash implements Store.
+func (s *fileStore) FindByHash(hash string) (*Token, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, token := range s.tokens {
+		if token.Hash == hash {
+			return &token, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+// Revoke implements Store.
+func (s *fileStore) Revoke(email, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := slices.IndexFunc(s.tokens, func(token Token) bool {
+		return token.Email == email && token.ID == id
+	})
+	if i == -1 {
+		return ErrNotFound
+	}
+	return s.save(slices.Delete(slices.Clone(s.tokens), i, i+1))
+}
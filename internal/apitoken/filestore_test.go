@@ -0,0 +1,57 @@
+package apitoken
+
+import (
+	"testing"
+	"time"
+
+	"github.com/tingtt/oauth2rbac/internal/acl"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFileStore(t *testing.T) {
+	t.Parallel()
+
+	filePath := t.TempDir() + "/tokens.json"
+	store, err := NewFileStore(filePath)
+	assert.NoError(t, err)
+
+	tokenStr, hash := Generate()
+	assert.True(t, IsAPIToken(tokenStr))
+	token := Token{
+		ID:        NewID(),
+		Name:      "ci",
+		Email:     "user@example.com",
+		Hash:      hash,
+		Scopes:    map[string]acl.AllowedScopes{"https://example.com": {"/api/": {"GET"}}},
+		CreatedAt: time.Now().UTC().Truncate(time.Second),
+	}
+	assert.NoError(t, store.Create(token))
+
+	t.Run("may persist tokens to the file", func(t *testing.T) {
+		reloaded, err := NewFileStore(filePath)
+		assert.NoError(t, err)
+		got, err := reloaded.FindByHash(Hash(tokenStr))
+		assert.NoError(t, err)
+		assert.Equal(t, token, *got)
+	})
+
+	t.Run("may list tokens of the user", func(t *testing.T) {
+		got, err := store.List("user@example.com")
+		assert.NoError(t, err)
+		assert.Len(t, got, 1)
+		got, err = store.List("other@example.com")
+		assert.NoError(t, err)
+		assert.Empty(t, got)
+	})
+
+	t.Run("may not revoke tokens of other users", func(t *testing.T) {
+		assert.ErrorIs(t, store.Revoke("other@example.com", token.ID), ErrNotFound)
+	})
+
+	t.Run("may revoke tokens", func(t *testing.T) {
+		assert.NoError(t, store.Revoke("user@example.com", token.ID))
+		_, err := store.FindByHash(hash)
+		assert.ErrorIs(t, err, ErrNotFound)
+	})
+}
@@ -0,0 +1,119 @@
+// Package audit is the audit log of authentication and authorization decisions, separate from access logs.
+package audit
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// SchemaVersion is the version of the Event schema.
+// Fields may be added within a version, but are never renamed or removed.
+const SchemaVersion = 1
+
+type Type string
+
+const (
+	TypeLoginSucceeded Type = "login.succeeded"
+	TypeLoginFailed    Type = "login.failed"
+	// TypeAccessDenied is a request denied with credentials. (Requests without credentials are not recorded.)
+	TypeAccessDenied Type = "access.denied"
+	TypeConfigLoaded Type = "config.loaded"
+	// TypeConfigReloaded is the config updated while serving. (e.g. Kubernetes Ingresses changed)
+	TypeConfigReloaded  Type = "config.reloaded"
+	TypeSessionRevoked  Type = "admin.session.revoked"
+	TypeSessionsRevoked Type = "admin.sessions.revoked"
+	TypeAPITokenCreated Type = "api_token.created"
+	TypeAPITokenRevoked Type = "api_token.revoked"
+)
+
+// Event is a record of the audit log, written as a line of JSON.
+type Event struct {
+	Version int       `json:"version"`
+	Time    time.Time `json:"time"`
+	Type    Type      `json:"type"`
+	// Actor is the email of the user, or the client ID of the service account. (empty if unknown)
+	Actor     string `json:"actor,omitempty"`
+	Provider  string `json:"provider,omitempty"`
+	SessionID string `json:"session_id,omitempty"`
+
+	RemoteAddr    string `json:"remote_addr,omitempty"`
+	XForwardedFor string `json:"x_forwarded_for,omitempty"`
+	Method        string `json:"method,omitempty"`
+	URL           string `json:"url,omitempty"`
+
+	// Rule is the scope of the actor matched the request path. (nil if no path matched)
+	Rule   *Rule  `json:"rule,omitempty"`
+	Reason string `json:"reason,omitempty"`
+	// Target is the subject of the action. (e.g. the session ID revoked, the API token ID created)
+	Target string `json:"target,omitempty"`
+	// Details is the additional fields by type. (e.g. the number of sessions revoked)
+	Details map[string]any `json:"details,omitempty"`
+}
+
+// Rule is the scope matched the request path, without the method requested.
+type Rule struct {
+	Path    string   `json:"path"`
+	Methods []string `json:"methods"`
+}
+
+// Sink writes events. (e.g. file, webhook)
+type Sink interface {
+	Write(event Event) error
+	// Close flushes the events written until the context is done, and drops the rest.
+	Close(ctx context.Context) error
+}
+
+// Logger writes events to the sinks. A nil Logger discards events.
+type Logger struct {
+	sinks []Sink
+	now   func() time.Time
+}
+
+func New(sinks ...Sink) *Logger {
+	return &Logger{sinks, time.Now}
+}
+
+// Log writes the event to the sinks, with the schema version and the time.
+// Failures are logged, and do not fail the request recorded.
+func (l *Logger) Log(event Event) {
+	if l == nil {
+		return
+	}
+	event.Version = SchemaVersion
+	event.Time = l.now().UTC()
+	for _, sink := range l.sinks {
+		if err := sink.Write(event); err != nil {
+			slog.Error("failed to write audit event", slog.String("type", string(event.Type)), slog.String("err", err.Error()))
+		}
+	}
+}
+
+// LogRequest writes the event with the client and the request URL.
+// The query of the URL is not recorded, not to leak secrets. (e.g. authorization codes)
+func (l *Logger) LogRequest(req *http.Request, reqURL url.URL, event Event) {
+	if l == nil {
+		return
+	}
+	reqURL.RawQuery = ""
+	event.RemoteAddr = req.RemoteAddr
+	event.XForwardedFor = req.Header.Get("X-Forwarded-For")
+	event.Method = req.Method
+	event.URL = reqURL.String()
+	l.Log(event)
+}
+
+// Close flushes and closes the sinks. Events not flushed until the context is done are dropped.
+func (l *Logger) Close(ctx context.Context) error {
+	if l == nil {
+		return nil
+	}
+	var errs []error
+	for _, sink := range l.sinks {
+		errs = append(errs, sink.Close(ctx))
+	}
+	return errors.Join(errs...)
+}
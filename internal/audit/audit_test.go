@@ -0,0 +1,62 @@
+package audit
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type recorder struct {
+	events []Event
+}
+
+func (r *recorder) Write(event Event) error     { r.events = append(r.events, event); return nil }
+func (r *recorder) Close(context.Context) error { return nil }
+
+func TestLogger_LogRequest(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2024, 1, 2, 15, 4, 5, 0, time.FixedZone("JST", 9*60*60))
+	sink := &recorder{}
+	logger := New(sink)
+	logger.now = func() time.Time { return now }
+
+	req := httptest.NewRequest(http.MethodDelete, "/admin/", nil)
+	req.Header.Set("X-Forwarded-For", "192.0.2.1")
+	reqURL, _ := url.Parse("https://example.com/admin/?token=secret")
+	logger.LogRequest(req, *reqURL, Event{
+		Type:   TypeAccessDenied,
+		Actor:  "user@example.com",
+		Rule:   &Rule{Path: "/", Methods: []string{"GET"}},
+		Reason: "no access to the scope",
+	})
+
+	assert.Equal(t, []Event{{
+		Version:       SchemaVersion,
+		Time:          now.UTC(),
+		Type:          TypeAccessDenied,
+		Actor:         "user@example.com",
+		RemoteAddr:    "192.0.2.1:1234",
+		XForwardedFor: "192.0.2.1",
+		Method:        http.MethodDelete,
+		URL:           "https://example.com/admin/",
+		Rule:          &Rule{Path: "/", Methods: []string{"GET"}},
+		Reason:        "no access to the scope",
+	}}, sink.events)
+}
+
+func TestLogger_nil(t *testing.T) {
+	t.Parallel()
+
+	var logger *Logger
+	assert.NotPanics(t, func() {
+		logger.Log(Event{Type: TypeConfigLoaded})
+		logger.LogRequest(httptest.NewRequest(http.MethodGet, "/", nil), url.URL{}, Event{Type: TypeAccessDenied})
+	})
+	assert.NoError(t, logger.Close(context.Background()))
+}
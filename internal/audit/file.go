@@ -0,0 +1,124 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+)
+
+// rotatedFileTimeFormat is the suffix of rotated files, sorted by time in lexical order.
+const rotatedFileTimeFormat = "20060102T150405.000000000Z"
+
+// NewFileSink returns a Sink appending events to the file as JSON lines.
+// When the file exceeds maxSize bytes, it is renamed with the time suffix (e.g. `audit.log.20240102T150405.000000000Z`)
+// and a new file is created. Rotated files are never rewritten, and the oldest ones over maxBackups are removed.
+// (not rotated if maxSize is zero, and all rotated files are kept if maxBackups is zero)
+// The file is written with mode 0600.
+func NewFileSink(filePath string, maxSize int64, maxBackups int) (Sink, error) {
+	s := &fileSink{filePath: filePath, maxSize: maxSize, maxBackups: maxBackups, now: time.Now}
+	if err := s.open(); err != nil {
+		return nil, fmt.Errorf("failed to open audit log: %w", err)
+	}
+	return s, nil
+}
+
+type fileSink struct {
+	mu         sync.Mutex
+	file       *os.File
+	size       int64
+	filePath   string
+	maxSize    int64
+	maxBackups int
+	now        func() time.Time
+}
+
+func (s *fileSink) open() error {
+	file, err := os.OpenFile(s.filePath, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	s.file, s.size = file, info.Size()
+	return nil
+}
+
+func (s *fileSink) Write(event Event) error {
+	line, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.file == nil {
+		return os.ErrClosed
+	}
+	var rotateErr error
+	if /* exceeds max size */ s.maxSize > 0 && s.size > 0 && s.size+int64(len(line)) > s.maxSize {
+		if err := s.rotate(); err != nil {
+			// the event is still written to the current file
+			rotateErr = fmt.Errorf("failed to rotate audit log: %w", err)
+		}
+	}
+	n, err := s.file.Write(line)
+	s.size += int64(n)
+	return errors.Join(rotateErr, err)
+}
+
+// rotate renames the file while it is open, so that events are kept written to it if a new file cannot be created.
+func (s *fileSink) rotate() error {
+	if err := os.Rename(s.filePath, s.filePath+"."+s.now().UTC().Format(rotatedFileTimeFormat)); err != nil {
+		return err
+	}
+	rotated := s.file
+	if err := s.open(); err != nil {
+		return err
+	}
+	rotated.Close()
+	return s.removeOldBackups()
+}
+
+func (s *fileSink) removeOldBackups() error {
+	if s.maxBackups <= 0 {
+		return nil
+	}
+	backups, err := filepath.Glob(s.filePath + ".*")
+	if err != nil {
+		return err
+	}
+	backups = slices.DeleteFunc(backups, func(backup string) bool {
+		_, err := time.Parse(rotatedFileTimeFormat, backup[len(s.filePath)+1:])
+		return err != nil
+	})
+	slices.Sort(backups)
+	for len(backups) > s.maxBackups {
+		if err := os.Remove(backups[0]); err != nil {
+			return err
+		}
+		backups = backups[1:]
+	}
+	return nil
+}
+
+// Close implements Sink. Events are written synchronously, so that nothing is dropped.
+func (s *fileSink) Close(context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.file == nil {
+		return nil
+	}
+	err := s.file.Close()
+	s.file = nil
+	return err
+}
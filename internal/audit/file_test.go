@@ -0,0 +1,83 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFileSink(t *testing.T) {
+	t.Parallel()
+
+	t.Run("may append events as JSON lines", func(t *testing.T) {
+		t.Parallel()
+		filePath := filepath.Join(t.TempDir(), "audit.log")
+		sink, err := NewFileSink(filePath, 0, 0)
+		assert.NoError(t, err)
+		assert.NoError(t, sink.Write(Event{Version: SchemaVersion, Type: TypeLoginSucceeded, Actor: "user@example.com", Provider: "github"}))
+		assert.NoError(t, sink.Close(context.Background()))
+
+		// appended after reopened
+		sink, err = NewFileSink(filePath, 0, 0)
+		assert.NoError(t, err)
+		assert.NoError(t, sink.Write(Event{Version: SchemaVersion, Type: TypeLoginFailed, Provider: "github", Reason: "invalid code"}))
+		assert.NoError(t, sink.Close(context.Background()))
+
+		data, err := os.ReadFile(filePath)
+		assert.NoError(t, err)
+		lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+		if assert.Len(t, lines, 2) {
+			var event Event
+			assert.NoError(t, json.Unmarshal([]byte(lines[1]), &event))
+			assert.Equal(t, TypeLoginFailed, event.Type)
+			assert.JSONEq(t,
+				`{"version":1,"time":"0001-01-01T00:00:00Z","type":"login.succeeded","actor":"user@example.com","provider":"github"}`,
+				lines[0],
+			)
+		}
+		info, err := os.Stat(filePath)
+		assert.NoError(t, err)
+		assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+	})
+
+	t.Run("may rotate the file and remove old ones", func(t *testing.T) {
+		t.Parallel()
+		dir := t.TempDir()
+		filePath := filepath.Join(dir, "audit.log")
+		_sink, err := NewFileSink(filePath, 100, 2)
+		assert.NoError(t, err)
+		sink := _sink.(*fileSink)
+		now := time.Date(2024, 1, 2, 15, 4, 5, 0, time.UTC)
+		sink.now = func() time.Time { now = now.Add(time.Second); return now }
+		// not removed, as it is not a rotated file
+		assert.NoError(t, os.WriteFile(filePath+".bak", nil, 0600))
+
+		for range 4 {
+			// about 80 bytes, rotated on every write but the first
+			assert.NoError(t, sink.Write(Event{Version: SchemaVersion, Type: TypeConfigReloaded, Reason: "rotation test"}))
+		}
+		assert.NoError(t, sink.Close(context.Background()))
+
+		files, _ := filepath.Glob(filepath.Join(dir, "*"))
+		assert.Equal(t, []string{
+			filePath,
+			filePath + ".20240102T150407.000000000Z",
+			filePath + ".20240102T150408.000000000Z",
+			filePath + ".bak",
+		}, files)
+	})
+
+	t.Run("may fail to write after closed", func(t *testing.T) {
+		t.Parallel()
+		sink, err := NewFileSink(filepath.Join(t.TempDir(), "audit.log"), 0, 0)
+		assert.NoError(t, err)
+		assert.NoError(t, sink.Close(context.Background()))
+		assert.ErrorIs(t, sink.Write(Event{Type: TypeConfigLoaded}), os.ErrClosed)
+	})
+}
@@ -0,0 +1,130 @@
+package audit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	webhookBufferSize = 1024
+	webhookTimeout    = 5 * time.Second
+)
+
+var ErrWebhookBufferFull = errors.New("webhook buffer full, event dropped")
+
+// NewWebhookSink returns a Sink posting each event as JSON to the URL.
+// Events are posted in the background not to delay requests, and dropped if the buffer is full.
+// Close waits for the buffered events to be posted until the context is done.
+func NewWebhookSink(url string, client *http.Client) Sink {
+	if client == nil {
+		client = &http.Client{Timeout: webhookTimeout}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &webhookSink{url: url, client: client, events: make(chan Event, webhookBufferSize), ctx: ctx, cancel: cancel}
+	s.wg.Add(1)
+	go s.run()
+	return s
+}
+
+type webhookSink struct {
+	url    string
+	client *http.Client
+	events chan Event
+	wg     sync.WaitGroup
+	// ctx is canceled to drop the buffered events on Close.
+	ctx    context.Context
+	cancel context.CancelFunc
+	// dropped is the number of events not posted, as Close timed out.
+	dropped atomic.Int64
+
+	mu     sync.RWMutex
+	closed bool
+}
+
+func (s *webhookSink) Write(event Event) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.closed {
+		return errors.New("webhook sink closed")
+	}
+	select {
+	case s.events <- event:
+		return nil
+	default:
+		return ErrWebhookBufferFull
+	}
+}
+
+func (s *webhookSink) run() {
+	defer s.wg.Done()
+	for event := range s.events {
+		if /* Close timed out */ s.ctx.Err() != nil {
+			s.dropped.Add(1)
+			continue
+		}
+		if err := s.post(event); err != nil {
+			if s.ctx.Err() != nil {
+				s.dropped.Add(1)
+				continue
+			}
+			slog.Error("failed to post audit event", slog.String("type", string(event.Type)), slog.String("err", err.Error()))
+		}
+	}
+}
+
+func (s *webhookSink) post(event Event) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(s.ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	res, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return fmt.Errorf("webhook responded %s", res.Status)
+	}
+	return nil
+}
+
+// Close implements Sink. The events not posted until the context is done are dropped, and counted in the error.
+func (s *webhookSink) Close(ctx context.Context) error {
+	s.mu.Lock()
+	if !s.closed {
+		s.closed = true
+		close(s.events)
+	}
+	s.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		// aborts the post in flight, and drops the rest
+		s.cancel()
+		<-done
+	}
+	s.cancel()
+	if dropped := s.dropped.Load(); dropped > 0 {
+		return fmt.Errorf("%d audit events dropped, not posted to the webhook until closed", dropped)
+	}
+	return nil
+}
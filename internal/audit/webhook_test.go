@@ -0,0 +1,74 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWebhookSink(t *testing.T) {
+	t.Parallel()
+
+	var mu sync.Mutex
+	var received []Event
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var event Event
+		if r.Header.Get("Content-Type") != "application/json" || json.NewDecoder(r.Body).Decode(&event) != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		mu.Lock()
+		received = append(received, event)
+		mu.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	t.Cleanup(server.Close)
+
+	sink := NewWebhookSink(server.URL, nil)
+	assert.NoError(t, sink.Write(Event{Version: SchemaVersion, Type: TypeSessionRevoked, Actor: "admin@example.com", Target: "sid"}))
+	assert.NoError(t, sink.Write(Event{Version: SchemaVersion, Type: TypeSessionsRevoked, Actor: "admin@example.com"}))
+	// waits for the events to be posted
+	assert.NoError(t, sink.Close(context.Background()))
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, []Event{
+		{Version: SchemaVersion, Type: TypeSessionRevoked, Actor: "admin@example.com", Target: "sid"},
+		{Version: SchemaVersion, Type: TypeSessionsRevoked, Actor: "admin@example.com"},
+	}, received)
+	assert.Error(t, sink.Write(Event{Type: TypeConfigLoaded}))
+}
+
+func TestWebhookSink_Close_timeout(t *testing.T) {
+	t.Parallel()
+
+	unblock := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// unreachable webhook
+		select {
+		case <-unblock:
+		case <-r.Context().Done():
+		}
+	}))
+	t.Cleanup(server.Close)
+	t.Cleanup(func() { close(unblock) })
+
+	sink := NewWebhookSink(server.URL, nil)
+	for range 3 {
+		assert.NoError(t, sink.Write(Event{Version: SchemaVersion, Type: TypeAccessDenied}))
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	err := sink.Close(ctx)
+
+	assert.Less(t, time.Since(start), time.Second)
+	assert.EqualError(t, err, "3 audit events dropped, not posted to the webhook until closed")
+}
@@ -0,0 +1,224 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// Timeout is the timeout of each check.
+const Timeout = 2 * time.Second
+
+const (
+	StatusOK     = "ok"
+	StatusFailed = "failed"
+	// StatusDegraded is the failed Optional check, not failing the parent checks.
+	StatusDegraded = "degraded"
+)
+
+// Status is the result of the check, with the results of the sub-checks.
+type Status struct {
+	Status string            `json:"status"`
+	Error  string            `json:"error,omitempty"`
+	Checks map[string]Status `json:"checks,omitempty"`
+}
+
+func (s Status) OK() bool {
+	return s.Status == StatusOK || s.Status == StatusDegraded
+}
+
+type Checker interface {
+	Check(ctx context.Context) Status
+}
+
+// CheckFunc is the Checker failing with the error.
+type CheckFunc func(ctx context.Context) error
+
+func (f CheckFunc) Check(ctx context.Context) Status {
+	ctx, cancel := context.WithTimeout(ctx, Timeout)
+	defer cancel()
+	if err := f(ctx); err != nil {
+		return Status{Status: StatusFailed, Error: err.Error()}
+	}
+	return Status{Status: StatusOK}
+}
+
+// All is the Checker failing if any of the checks fails.
+type All map[string]Checker
+
+func (c All) Check(ctx context.Context) Status {
+	status := Status{Status: StatusOK, Checks: checkAll(ctx, c)}
+	for _, s := range status.Checks {
+		if !s.OK() {
+			status.Status = StatusFailed
+		}
+		if s.Status == StatusDegraded && status.Status == StatusOK {
+			status.Status = StatusDegraded
+		}
+	}
+	return status
+}
+
+// Any is the Checker failing if all of the checks fail. (e.g. all upstreams are down)
+type Any map[string]Checker
+
+func (c Any) Check(ctx context.Context) Status {
+	status := Status{Status: StatusFailed, Checks: checkAll(ctx, c)}
+	if len(c) == 0 {
+		status.Status = StatusOK
+	}
+	for _, s := range status.Checks {
+		if s.OK() {
+			status.Status = StatusOK
+		}
+	}
+	return status
+}
+
+// checkAll runs the checks concurrently.
+func checkAll(ctx context.Context, checks map[string]Checker) map[string]Status {
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	statuses := make(map[string]Status, len(checks))
+	for name, checker := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			status := checker.Check(ctx)
+			mu.Lock()
+			defer mu.Unlock()
+			statuses[name] = status
+		}()
+	}
+	wg.Wait()
+	return statuses
+}
+
+// Optional is the Checker degraded instead of failing. (e.g. dependencies needed only on sign-in)
+type Optional struct {
+	Checker
+}
+
+func (c Optional) Check(ctx context.Context) Status {
+	status := c.Checker.Check(ctx)
+	if !status.OK() {
+		status.Status = StatusDegraded
+	}
+	return status
+}
+
+// Cached returns the Checker reusing the status for the TTL, not to run the checks on every request.
+// Concurrent requests wait for the check in progress.
+func Cached(checker Checker, ttl time.Duration) Checker {
+	return &cached{checker: checker, ttl: ttl}
+}
+
+type cached struct {
+	checker   Checker
+	ttl       time.Duration
+	mu        sync.Mutex
+	status    Status
+	checkedAt time.Time
+}
+
+func (c *cached) Check(ctx context.Context) Status {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if time.Since(c.checkedAt) < c.ttl {
+		return c.status
+	}
+	// not to cache the status of the canceled request
+	c.status = c.checker.Check(context.WithoutCancel(ctx))
+	c.checkedAt = time.Now()
+	return c.status
+}
+
+// Dynamic is the Checker of the checks built on each check. (e.g. upstreams of the current proxies)
+type Dynamic func() Checker
+
+func (f Dynamic) Check(ctx context.Context) Status {
+	return f().Check(ctx)
+}
+
+// Upstreams returns the Checker connecting to the hosts of the target URLs, failing if all of them are unreachable.
+func Upstreams(targetURLs []string) Checker {
+	checks := Any{}
+	for _, targetURL := range targetURLs {
+		u, err := url.Parse(targetURL)
+		if err != nil {
+			continue
+		}
+		addr := u.Host
+		if u.Port() == "" {
+			port := "80"
+			if u.Scheme == "https" {
+				port = "443"
+			}
+			addr = net.JoinHostPort(u.Hostname(), port)
+		}
+		checks[addr] = CheckFunc(func(ctx context.Context) error {
+			conn, err := (&net.Dialer{}).DialContext(ctx, "tcp", addr)
+			if err != nil {
+				return err
+			}
+			return conn.Close()
+		})
+	}
+	return checks
+}
+
+// OIDCDiscovery returns the CheckFunc getting the OpenID Connect discovery document.
+func OIDCDiscovery(client *http.Client, discoveryURL string) CheckFunc {
+	return func(ctx context.Context) error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, discoveryURL, nil)
+		if err != nil {
+			return err
+		}
+		res, err := client.Do(req)
+		if err != nil {
+			return err
+		}
+		defer res.Body.Close()
+		if res.StatusCode != http.StatusOK {
+			return fmt.Errorf("unexpected response `%s` from `%s`", res.Status, discoveryURL)
+		}
+		var document struct {
+			Issuer string `json:"issuer"`
+		}
+		if err := json.NewDecoder(res.Body).Decode(&document); err != nil || document.Issuer == "" {
+			return fmt.Errorf("invalid discovery document from `%s`", discoveryURL)
+		}
+		return nil
+	}
+}
+
+// Handler responds the status in JSON, with `503 Service Unavailable` if the check fails. (not if degraded)
+// The results of the sub-checks are omitted, not to expose the internals to the public. (e.g. addresses of upstreams)
+func Handler(checker Checker) http.HandlerFunc {
+	return handler(checker, false)
+}
+
+// DetailsHandler is the Handler responding the results of the sub-checks with the errors. (e.g. on the admin listener)
+func DetailsHandler(checker Checker) http.HandlerFunc {
+	return handler(checker, true)
+}
+
+func handler(checker Checker, details bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		status := checker.Check(r.Context())
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		if !status.OK() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		if !details {
+			status = Status{Status: status.Status}
+		}
+		json.NewEncoder(w).Encode(status)
+	}
+}
@@ -0,0 +1,172 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChecker(t *testing.T) {
+	t.Parallel()
+
+	ok := CheckFunc(func(context.Context) error { return nil })
+	failed := CheckFunc(func(context.Context) error { return errors.New("down") })
+
+	tests := []struct {
+		name    string
+		checker Checker
+		want    Status
+	}{
+		{
+			name:    "All may fail if any of the checks fails",
+			checker: All{"a": ok, "b": failed},
+			want: Status{Status: StatusFailed, Checks: map[string]Status{
+				"a": {Status: StatusOK},
+				"b": {Status: StatusFailed, Error: "down"},
+			}},
+		},
+		{
+			name:    "Any may succeed if any of the checks succeeds",
+			checker: Any{"a": ok, "b": failed},
+			want: Status{Status: StatusOK, Checks: map[string]Status{
+				"a": {Status: StatusOK},
+				"b": {Status: StatusFailed, Error: "down"},
+			}},
+		},
+		{
+			name:    "Any may fail if all of the checks fail",
+			checker: Any{"a": failed},
+			want: Status{Status: StatusFailed, Checks: map[string]Status{
+				"a": {Status: StatusFailed, Error: "down"},
+			}},
+		},
+		{
+			name:    "Any may succeed without checks",
+			checker: Any{},
+			want:    Status{Status: StatusOK, Checks: map[string]Status{}},
+		},
+		{
+			name:    "All may be degraded if optional checks fail",
+			checker: All{"a": ok, "b": Optional{failed}},
+			want: Status{Status: StatusDegraded, Checks: map[string]Status{
+				"a": {Status: StatusOK},
+				"b": {Status: StatusDegraded, Error: "down"},
+			}},
+		},
+		{
+			name:    "All may fail if any of the checks fails even if degraded",
+			checker: All{"a": failed, "b": Optional{failed}},
+			want: Status{Status: StatusFailed, Checks: map[string]Status{
+				"a": {Status: StatusFailed, Error: "down"},
+				"b": {Status: StatusDegraded, Error: "down"},
+			}},
+		},
+		{
+			name:    "may be nested",
+			checker: All{"upstreams": Any{"a": ok}},
+			want: Status{Status: StatusOK, Checks: map[string]Status{
+				"upstreams": {Status: StatusOK, Checks: map[string]Status{"a": {Status: StatusOK}}},
+			}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, tt.want, tt.checker.Check(context.Background()))
+		})
+	}
+}
+
+func TestCached(t *testing.T) {
+	t.Parallel()
+
+	var checked atomic.Int32
+	checker := Cached(CheckFunc(func(context.Context) error {
+		checked.Add(1)
+		return nil
+	}), 100*time.Millisecond)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	for range 3 {
+		assert.Equal(t, Status{Status: StatusOK}, checker.Check(ctx), "not failed with the canceled request")
+	}
+	assert.Equal(t, int32(1), checked.Load())
+
+	time.Sleep(100 * time.Millisecond)
+	checker.Check(context.Background())
+	assert.Equal(t, int32(2), checked.Load(), "checked again after the TTL")
+}
+
+func TestUpstreams(t *testing.T) {
+	t.Parallel()
+
+	listener, _ := net.Listen("tcp", "127.0.0.1:0")
+	defer listener.Close()
+	closed, _ := net.Listen("tcp", "127.0.0.1:0")
+	closed.Close()
+
+	status := Upstreams([]string{
+		"http://" + listener.Addr().String() + "/",
+		"http://" + closed.Addr().String() + "/",
+	}).Check(context.Background())
+
+	assert.Equal(t, StatusOK, status.Status)
+	assert.Equal(t, StatusOK, status.Checks[listener.Addr().String()].Status)
+	assert.Equal(t, StatusFailed, status.Checks[closed.Addr().String()].Status)
+}
+
+func TestOIDCDiscovery(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/.well-known/openid-configuration" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(`{"issuer":"https://idp.example.com"}`))
+	}))
+	t.Cleanup(server.Close)
+
+	assert.NoError(t, OIDCDiscovery(server.Client(), server.URL+"/.well-known/openid-configuration")(context.Background()))
+	assert.ErrorContains(t, OIDCDiscovery(server.Client(), server.URL+"/")(context.Background()), "404 Not Found")
+}
+
+func TestHandler(t *testing.T) {
+	t.Parallel()
+
+	checker := All{"config": CheckFunc(func(context.Context) error { return errors.New("not loaded") })}
+
+	rw := httptest.NewRecorder()
+	Handler(checker).ServeHTTP(rw, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+
+	assert.Equal(t, http.StatusServiceUnavailable, rw.Code)
+	assert.Equal(t, "application/json", rw.Header().Get("Content-Type"))
+	var status Status
+	json.NewDecoder(rw.Body).Decode(&status)
+	assert.Equal(t, Status{Status: StatusFailed}, status, "results of the sub-checks omitted")
+
+	rw = httptest.NewRecorder()
+	DetailsHandler(checker).ServeHTTP(rw, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+
+	assert.Equal(t, http.StatusServiceUnavailable, rw.Code)
+	status = Status{}
+	json.NewDecoder(rw.Body).Decode(&status)
+	assert.Equal(t, Status{Status: StatusFailed, Checks: map[string]Status{
+		"config": {Status: StatusFailed, Error: "not loaded"},
+	}}, status)
+
+	rw = httptest.NewRecorder()
+	Handler(All{"oauth2_providers": Optional{CheckFunc(func(context.Context) error { return errors.New("unavailable") })}}).
+		ServeHTTP(rw, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+
+	assert.Equal(t, http.StatusOK, rw.Code, "not failed if degraded")
+}
@@ -0,0 +1,218 @@
+package ingress
+
+import (
+	"bytes"
+	"cmp"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/tingtt/oauth2rbac/internal/acl"
+	reverseproxy "github.com/tingtt/oauth2rbac/internal/api/handler/reverse_proxy"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// AnnotationACL is the ACL of the hosts of the Ingress in YAML, as an origin of `acl` in the manifest. (e.g. `paths`, `roles`)
+	AnnotationACL = "oauth2rbac/acl"
+	// AnnotationAllow is the emails (comma-separated) allowed all methods of the paths of the Ingress, instead of AnnotationACL.
+	AnnotationAllow = "oauth2rbac/allow"
+	// annotationIngressClass is the ingress class of Ingresses without `spec.ingressClassName`.
+	annotationIngressClass = "kubernetes.io/ingress.class"
+)
+
+// Config is the proxies and the ACL built from Ingresses.
+type Config struct {
+	ReverseProxy reverseproxy.Config
+	ACL          acl.Pool
+}
+
+// Validator returns the semantic errors of the config. (e.g. invalid HTTP methods in ACL)
+type Validator func(reverseproxy.Config, acl.Pool) []string
+
+// Build returns the config of the Ingresses of the class merged into the static config.
+// Ingresses with ACL are applied first, from the oldest. Ingresses without ACL are applied to the origins with ACL.
+// Hosts in `spec.tls` of any Ingress are served with HTTPS.
+// Invalid Ingresses, or conflicting with the config applied (e.g. duplicated external URLs or origins), are skipped with the errors.
+func Build(ingresses []Ingress, ingressClass string, static Config, validate Validator) (Config, []error) {
+	ingresses = slices.DeleteFunc(slices.Clone(ingresses), func(ingress Ingress) bool {
+		return !ingress.hasClass(ingressClass)
+	})
+	slices.SortStableFunc(ingresses, func(a, b Ingress) int {
+		return cmp.Or(
+			-compareBool(a.hasACL(), b.hasACL()),
+			a.Metadata.CreationTimestamp.Compare(b.Metadata.CreationTimestamp),
+			strings.Compare(a.key(), b.key()),
+		)
+	})
+
+	tlsHosts := []string{}
+	for _, ingress := range ingresses {
+		for _, tls := range ingress.Spec.TLS {
+			tlsHosts = append(tlsHosts, tls.Hosts...)
+		}
+	}
+
+	config := Config{
+		ReverseProxy: reverseproxy.Config{Proxies: slices.Clone(static.ReverseProxy.Proxies)},
+		ACL:          maps.Clone(static.ACL),
+	}
+	if config.ACL == nil {
+		config.ACL = acl.Pool{}
+	}
+	var errs []error
+	for _, ingress := range ingresses {
+		applied, err := apply(config, ingress, tlsHosts, validate)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("ingress `%s`: %w", ingress.key(), err))
+			continue
+		}
+		config = applied
+	}
+	return config, errs
+}
+
+// apply returns the config with the proxies and the ACL of the Ingress.
+func apply(config Config, ingress Ingress, tlsHosts []string, validate Validator) (Config, error) {
+	proxies, pool, err := ingress.config(tlsHosts)
+	if err != nil {
+		return Config{}, err
+	}
+
+	applied := Config{
+		ReverseProxy: reverseproxy.Config{Proxies: slices.Clone(config.ReverseProxy.Proxies)},
+		ACL:          maps.Clone(config.ACL),
+	}
+	for _, proxy := range proxies {
+		if slices.ContainsFunc(applied.ReverseProxy.Proxies, func(applied reverseproxy.Proxy) bool {
+			return applied.ExternalURL == proxy.ExternalURL
+		}) {
+			return Config{}, fmt.Errorf("external URL `%s` is already declared", proxy.ExternalURL)
+		}
+		applied.ReverseProxy.Proxies = append(applied.ReverseProxy.Proxies, proxy)
+	}
+	for origin, scope := range pool {
+		_, declared := applied.ACL[origin]
+		_, declaredWithTrailingSlash := applied.ACL[origin+"/"]
+		if declared || declaredWithTrailingSlash {
+			return Config{}, fmt.Errorf("ACL of origin `%s` is already declared", origin)
+		}
+		applied.ACL[origin] = scope
+	}
+
+	if errs := validate(applied.ReverseProxy, applied.ACL); len(errs) != 0 {
+		return Config{}, errors.New(strings.Join(errs, ", "))
+	}
+	return applied, nil
+}
+
+// config returns the proxies to the Services, and the ACL of the hosts.
+func (i Ingress) config(tlsHosts []string) ([]reverseproxy.Proxy, acl.Pool, error) {
+	var proxies []reverseproxy.Proxy
+	var origins, paths []string
+	for _, rule := range i.Spec.Rules {
+		if rule.Host == "" || rule.HTTP == nil {
+			continue
+		}
+		if strings.Contains(rule.Host, "*") {
+			return nil, nil, fmt.Errorf("wildcard host `%s` is not supported", rule.Host)
+		}
+		scheme := "http"
+		if slices.Contains(tlsHosts, rule.Host) {
+			scheme = "https"
+		}
+		origin := scheme + "://" + rule.Host
+		origins = append(origins, origin)
+
+		for _, path := range rule.HTTP.Paths {
+			service := path.Backend.Service
+			if service == nil {
+				return nil, nil, errors.New("backends other than Services are not supported")
+			}
+			if service.Port.Number == 0 {
+				return nil, nil, fmt.Errorf("named port `%s` of Service `%s` is not supported (use port number)", service.Port.Name, service.Name)
+			}
+			p := cmp.Or(path.Path, "/")
+			paths = append(paths, p)
+			proxies = append(proxies, reverseproxy.Proxy{
+				ExternalURL: origin + p,
+				// the request path is not cut, as Ingresses do
+				Target: reverseproxy.Target{URL: fmt.Sprintf("http://%s.%s.svc:%d", service.Name, i.Metadata.Namespace, service.Port.Number)},
+			})
+		}
+	}
+
+	scope, hasACL, err := i.scopeOrigin(paths)
+	if err != nil {
+		return nil, nil, err
+	}
+	pool := acl.Pool{}
+	if hasACL {
+		for _, origin := range origins {
+			pool[origin] = scope
+		}
+	}
+	return proxies, pool, nil
+}
+
+// scopeOrigin returns the ACL of the annotations.
+func (i Ingress) scopeOrigin(paths []string) (acl.ScopeOrigin, bool, error) {
+	rawACL, hasACL := i.Metadata.Annotations[AnnotationACL]
+	rawAllow, hasAllow := i.Metadata.Annotations[AnnotationAllow]
+	switch {
+	case hasACL && hasAllow:
+		return acl.ScopeOrigin{}, false, fmt.Errorf("annotations `%s` and `%s` cannot be used together", AnnotationACL, AnnotationAllow)
+	case hasACL:
+		var scope acl.ScopeOrigin
+		decoder := yaml.NewDecoder(bytes.NewReader([]byte(rawACL)))
+		decoder.KnownFields(true)
+		if err := decoder.Decode(&scope); err != nil {
+			return acl.ScopeOrigin{}, false, fmt.Errorf("annotation `%s`: %w", AnnotationACL, err)
+		}
+		if len(scope.ErrorPages) != 0 {
+			return acl.ScopeOrigin{}, false, fmt.Errorf("annotation `%s`: error_pages is not supported in Ingresses", AnnotationACL)
+		}
+		return scope, true, nil
+	case hasAllow:
+		var emails []acl.EmailRegex
+		for _, email := range strings.Split(rawAllow, ",") {
+			if email = strings.TrimSpace(email); email != "" {
+				emails = append(emails, acl.EmailRegex(email))
+			}
+		}
+		scope := acl.ScopeOrigin{PathScopes: map[acl.Path][]acl.ScopePath{}}
+		for _, path := range paths {
+			scope.PathScopes[path] = []acl.ScopePath{{Methods: []acl.Method{"*"}, EmailRegexes: emails}}
+		}
+		return scope, true, nil
+	default:
+		return acl.ScopeOrigin{}, false, nil
+	}
+}
+
+func (i Ingress) hasClass(ingressClass string) bool {
+	if i.Spec.IngressClassName != nil {
+		return *i.Spec.IngressClassName == ingressClass
+	}
+	return i.Metadata.Annotations[annotationIngressClass] == ingressClass
+}
+
+func (i Ingress) hasACL() bool {
+	_, hasACL := i.Metadata.Annotations[AnnotationACL]
+	_, hasAllow := i.Metadata.Annotations[AnnotationAllow]
+	return hasACL || hasAllow
+}
+
+func compareBool(a, b bool) int {
+	switch {
+	case a == b:
+		return 0
+	case a:
+		return 1
+	default:
+		return -1
+	}
+}
@@ -0,0 +1,132 @@
+package ingress
+
+import (
+	"fmt"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/tingtt/oauth2rbac/internal/acl"
+	reverseproxy "github.com/tingtt/oauth2rbac/internal/api/handler/reverse_proxy"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// validateProxiedOrigins is the Validator checking that all proxies have ACL.
+func validateProxiedOrigins(config reverseproxy.Config, pool acl.Pool) []string {
+	var errs []string
+	for _, proxy := range config.Proxies {
+		u, _ := url.Parse(proxy.ExternalURL)
+		if _, ok := pool.Sanitized()[u.Scheme+"://"+u.Host]; !ok {
+			errs = append(errs, fmt.Sprintf("no ACL of the origin `%s` found in acl", u.Scheme+"://"+u.Host))
+		}
+	}
+	return errs
+}
+
+func newIngress(name string, created time.Time, annotations map[string]string, host string, paths ...string) Ingress {
+	ingressClass := "oauth2rbac"
+	ingress := Ingress{
+		Metadata: ObjectMeta{Name: name, Namespace: "default", CreationTimestamp: created, Annotations: annotations},
+		Spec: IngressSpec{
+			IngressClassName: &ingressClass,
+			Rules:            []IngressRule{{Host: host, HTTP: &HTTPIngressRuleValue{}}},
+		},
+	}
+	for _, path := range paths {
+		ingress.Spec.Rules[0].HTTP.Paths = append(ingress.Spec.Rules[0].HTTP.Paths, HTTPIngressPath{
+			Path:    path,
+			Backend: IngressBackend{Service: &IngressServiceBackend{Name: name, Port: ServiceBackendPort{Number: 8080}}},
+		})
+	}
+	return ingress
+}
+
+func TestBuild(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+	static := Config{
+		ReverseProxy: reverseproxy.Config{Proxies: []reverseproxy.Proxy{{ExternalURL: "http://static.example.com/", Target: reverseproxy.Target{URL: "http://static:80/"}}}},
+		ACL:          acl.Pool{"http://static.example.com/": {}},
+	}
+
+	t.Run("may build proxies and ACL of annotations", func(t *testing.T) {
+		t.Parallel()
+		www := newIngress("www", now, map[string]string{AnnotationACL: `
+paths:
+  "/":
+    - methods: ["GET"]
+      emails: ["-"]
+jwt_expiry_in: 1h
+`}, "www.example.com", "/")
+		www.Spec.TLS = []IngressTLS{{Hosts: []string{"www.example.com"}}}
+		api := newIngress("api", now, nil, "www.example.com", "/api")
+		admin := newIngress("admin", now, map[string]string{AnnotationAllow: "admin@example.com, github:acme/sre"}, "admin.example.com", "/", "/metrics")
+
+		got, errs := Build([]Ingress{api, www, admin}, "oauth2rbac", static, validateProxiedOrigins)
+		assert.Empty(t, errs)
+		assert.Equal(t, []reverseproxy.Proxy{
+			{ExternalURL: "http://static.example.com/", Target: reverseproxy.Target{URL: "http://static:80/"}},
+			{ExternalURL: "http://admin.example.com/", Target: reverseproxy.Target{URL: "http://admin.default.svc:8080"}},
+			{ExternalURL: "http://admin.example.com/metrics", Target: reverseproxy.Target{URL: "http://admin.default.svc:8080"}},
+			{ExternalURL: "https://www.example.com/", Target: reverseproxy.Target{URL: "http://www.default.svc:8080"}},
+			{ExternalURL: "https://www.example.com/api", Target: reverseproxy.Target{URL: "http://api.default.svc:8080"}},
+		}, got.ReverseProxy.Proxies)
+
+		jwtExpiryIn := acl.JWTExpiryIn(time.Hour)
+		allowAdmin := []acl.ScopePath{{Methods: []string{"*"}, EmailRegexes: []acl.EmailRegex{"admin@example.com", "github:acme/sre"}}}
+		assert.Equal(t, acl.Pool{
+			"http://static.example.com/": {},
+			"https://www.example.com": {
+				PathScopes:   map[string][]acl.ScopePath{"/": {{Methods: []string{"GET"}, EmailRegexes: []acl.EmailRegex{"-"}}}},
+				OriginConfig: acl.OriginConfig{JWTExpiryIn: &jwtExpiryIn},
+			},
+			"http://admin.example.com": {PathScopes: map[string][]acl.ScopePath{"/": allowAdmin, "/metrics": allowAdmin}},
+		}, got.ACL)
+	})
+
+	t.Run("may skip Ingresses of other classes", func(t *testing.T) {
+		t.Parallel()
+		other := newIngress("other", now, map[string]string{AnnotationAllow: "*"}, "other.example.com", "/")
+		other.Spec.IngressClassName = nil
+		other.Metadata.Annotations["kubernetes.io/ingress.class"] = "nginx"
+		legacy := newIngress("legacy", now, map[string]string{AnnotationAllow: "*", "kubernetes.io/ingress.class": "oauth2rbac"}, "legacy.example.com", "/")
+		legacy.Spec.IngressClassName = nil
+
+		got, errs := Build([]Ingress{other, legacy}, "oauth2rbac", Config{}, validateProxiedOrigins)
+		assert.Empty(t, errs)
+		assert.Equal(t, []reverseproxy.Proxy{
+			{ExternalURL: "http://legacy.example.com/", Target: reverseproxy.Target{URL: "http://legacy.default.svc:8080"}},
+		}, got.ReverseProxy.Proxies)
+	})
+
+	t.Run("may skip conflicting and invalid Ingresses", func(t *testing.T) {
+		t.Parallel()
+		older := newIngress("older", now.Add(-time.Hour), map[string]string{AnnotationAllow: "*"}, "www.example.com", "/")
+		newer := newIngress("newer", now, map[string]string{AnnotationAllow: "*"}, "www.example.com", "/new")
+		duplicated := newIngress("duplicated", now, nil, "www.example.com", "/")
+		withStatic := newIngress("with-static", now, map[string]string{AnnotationAllow: "*"}, "static.example.com", "/ingress")
+		withoutACL := newIngress("without-acl", now, nil, "unknown.example.com", "/")
+		invalidACL := newIngress("invalid-acl", now, map[string]string{AnnotationACL: "mothods: []"}, "invalid.example.com", "/")
+		namedPort := newIngress("named-port", now, map[string]string{AnnotationAllow: "*"}, "named.example.com", "/")
+		namedPort.Spec.Rules[0].HTTP.Paths[0].Backend.Service.Port = ServiceBackendPort{Name: "http"}
+
+		got, errs := Build([]Ingress{newer, older, duplicated, withStatic, withoutACL, invalidACL, namedPort}, "oauth2rbac", static, validateProxiedOrigins)
+		assert.Len(t, got.ReverseProxy.Proxies, 2)
+		assert.Contains(t, got.ACL, "http://www.example.com")
+
+		var msgs []string
+		for _, err := range errs {
+			msgs = append(msgs, err.Error())
+		}
+		assert.ElementsMatch(t, []string{
+			"ingress `default/newer`: ACL of origin `http://www.example.com` is already declared",
+			"ingress `default/duplicated`: external URL `http://www.example.com/` is already declared",
+			"ingress `default/with-static`: ACL of origin `http://static.example.com` is already declared",
+			"ingress `default/without-acl`: no ACL of the origin `http://unknown.example.com` found in acl",
+			"ingress `default/invalid-acl`: annotation `oauth2rbac/acl`: yaml: unmarshal errors:\n  line 1: field mothods not found in type acl.ScopeOrigin",
+			"ingress `default/named-port`: named port `http` of Service `named-port` is not supported (use port number)",
+		}, msgs)
+	})
+}
@@ -0,0 +1,183 @@
+package ingress
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// Client lists and watches Ingresses. It is implemented with the Kubernetes API, and faked in tests.
+type Client interface {
+	// List returns the Ingresses, with the resource version to watch from.
+	List(ctx context.Context) (*IngressList, error)
+	// Watch returns the events of Ingresses after the resource version.
+	// The channel is closed when the context is canceled or the watch is closed by the server.
+	Watch(ctx context.Context, resourceVersion string) (<-chan Event, error)
+}
+
+type EventType string
+
+const (
+	EventAdded    EventType = "ADDED"
+	EventModified EventType = "MODIFIED"
+	EventDeleted  EventType = "DELETED"
+	// EventBookmark only updates the resource version.
+	EventBookmark EventType = "BOOKMARK"
+	// EventError requires to list again. (e.g. the resource version is too old)
+	EventError EventType = "ERROR"
+)
+
+type Event struct {
+	Type    EventType
+	Ingress Ingress
+	// Err is the error of EventError.
+	Err error
+}
+
+// serviceAccountDir is the directory of the service account token and the CA certificate mounted in Pods.
+const serviceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount"
+
+// NewInClusterClient returns the client with the service account of the Pod.
+// Ingresses of all namespaces are watched if the namespace is empty.
+func NewInClusterClient(namespace string) (Client, error) {
+	host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+	if host == "" || port == "" {
+		return nil, errors.New("not running in Kubernetes cluster (KUBERNETES_SERVICE_HOST and KUBERNETES_SERVICE_PORT are not set)")
+	}
+	ca, err := os.ReadFile(serviceAccountDir + "/ca.crt")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CA certificate of Kubernetes API: %w", err)
+	}
+	rootCAs := x509.NewCertPool()
+	if !rootCAs.AppendCertsFromPEM(ca) {
+		return nil, errors.New("invalid CA certificate of Kubernetes API")
+	}
+	httpClient := &http.Client{Transport: &http.Transport{
+		Proxy:           http.ProxyFromEnvironment,
+		TLSClientConfig: &tls.Config{RootCAs: rootCAs, MinVersion: tls.VersionTLS12},
+	}}
+	return newRESTClient("https://"+net.JoinHostPort(host, port), namespace, serviceAccountDir+"/token", httpClient), nil
+}
+
+type restClient struct {
+	baseURL   string
+	namespace string
+	// tokenFile is read on every request, as service account tokens are rotated.
+	tokenFile  string
+	httpClient *http.Client
+}
+
+func newRESTClient(baseURL, namespace, tokenFile string, httpClient *http.Client) *restClient {
+	return &restClient{baseURL, namespace, tokenFile, httpClient}
+}
+
+// List implements Client.
+func (c *restClient) List(ctx context.Context) (*IngressList, error) {
+	res, err := c.get(ctx, url.Values{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list ingresses: %w", err)
+	}
+	defer res.Body.Close()
+
+	var list IngressList
+	if err := json.NewDecoder(res.Body).Decode(&list); err != nil {
+		return nil, fmt.Errorf("failed to list ingresses: %w", err)
+	}
+	return &list, nil
+}
+
+// Watch implements Client.
+func (c *restClient) Watch(ctx context.Context, resourceVersion string) (<-chan Event, error) {
+	res, err := c.get(ctx, url.Values{
+		"watch":               {"true"},
+		"resourceVersion":     {resourceVersion},
+		"allowWatchBookmarks": {"true"},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to watch ingresses: %w", err)
+	}
+
+	events := make(chan Event)
+	go func() {
+		defer close(events)
+		defer res.Body.Close()
+
+		decoder := json.NewDecoder(res.Body)
+		for {
+			var watchEvent struct {
+				Type   EventType       `json:"type"`
+				Object json.RawMessage `json:"object"`
+			}
+			if err := decoder.Decode(&watchEvent); err != nil {
+				if ctx.Err() == nil && !errors.Is(err, io.EOF) {
+					send(ctx, events, Event{Type: EventError, Err: fmt.Errorf("failed to watch ingresses: %w", err)})
+				}
+				return
+			}
+
+			event := Event{Type: watchEvent.Type}
+			if watchEvent.Type == EventError {
+				var status struct {
+					Code    int    `json:"code"`
+					Message string `json:"message"`
+				}
+				json.Unmarshal(watchEvent.Object, &status)
+				event.Err = fmt.Errorf("failed to watch ingresses: %s (code %d)", status.Message, status.Code)
+			} else if err := json.Unmarshal(watchEvent.Object, &event.Ingress); err != nil {
+				event = Event{Type: EventError, Err: fmt.Errorf("failed to watch ingresses: %w", err)}
+			}
+			if !send(ctx, events, event) {
+				return
+			}
+		}
+	}()
+	return events, nil
+}
+
+func send(ctx context.Context, events chan<- Event, event Event) bool {
+	select {
+	case events <- event:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+func (c *restClient) get(ctx context.Context, query url.Values) (*http.Response, error) {
+	path := "/apis/networking.k8s.io/v1/ingresses"
+	if c.namespace != "" {
+		path = "/apis/networking.k8s.io/v1/namespaces/" + url.PathEscape(c.namespace) + "/ingresses"
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+path+"?"+query.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	if c.tokenFile != "" {
+		token, err := os.ReadFile(c.tokenFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read service account token: %w", err)
+		}
+		req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(token)))
+	}
+
+	res, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode != http.StatusOK {
+		defer res.Body.Close()
+		body, _ := io.ReadAll(io.LimitReader(res.Body, 1024))
+		return nil, fmt.Errorf("unexpected response `%s`: %s", res.Status, strings.TrimSpace(string(body)))
+	}
+	return res, nil
+}
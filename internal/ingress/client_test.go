@@ -0,0 +1,72 @@
+package ingress
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_restClient(t *testing.T) {
+	t.Parallel()
+
+	tokenFile := t.TempDir() + "/token"
+	os.WriteFile(tokenFile, []byte("token\n"), 0600)
+
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if req.Header.Get("Authorization") != "Bearer token" {
+			http.Error(rw, `{"kind":"Status","code":401}`, http.StatusUnauthorized)
+			return
+		}
+		if req.URL.Path != "/apis/networking.k8s.io/v1/namespaces/web/ingresses" {
+			http.NotFound(rw, req)
+			return
+		}
+		if req.URL.Query().Get("watch") != "true" {
+			fmt.Fprint(rw, `{"metadata":{"resourceVersion":"10"},"items":[{"metadata":{"name":"www","namespace":"web","annotations":{"oauth2rbac/allow":"*"}},"spec":{"ingressClassName":"oauth2rbac","rules":[{"host":"www.example.com","http":{"paths":[{"path":"/","backend":{"service":{"name":"www","port":{"number":80}}}}]}}]}}]}`)
+			return
+		}
+		assert.Equal(t, "10", req.URL.Query().Get("resourceVersion"))
+		fmt.Fprintln(rw, `{"type":"DELETED","object":{"metadata":{"name":"www","namespace":"web","resourceVersion":"11"}}}`)
+		fmt.Fprintln(rw, `{"type":"ERROR","object":{"kind":"Status","code":410,"message":"too old resource version"}}`)
+	}))
+	t.Cleanup(server.Close)
+
+	t.Run("may list and watch Ingresses", func(t *testing.T) {
+		t.Parallel()
+		client := newRESTClient(server.URL, "web", tokenFile, server.Client())
+
+		list, err := client.List(context.Background())
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Equal(t, "10", list.Metadata.ResourceVersion)
+		assert.Equal(t, "web/www", list.Items[0].key())
+		assert.Equal(t, int32(80), list.Items[0].Spec.Rules[0].HTTP.Paths[0].Backend.Service.Port.Number)
+
+		events, err := client.Watch(context.Background(), list.Metadata.ResourceVersion)
+		if !assert.NoError(t, err) {
+			return
+		}
+		event := <-events
+		assert.Equal(t, EventDeleted, event.Type)
+		assert.Equal(t, "11", event.Ingress.Metadata.ResourceVersion)
+		event = <-events
+		assert.Equal(t, EventError, event.Type)
+		assert.ErrorContains(t, event.Err, "too old resource version (code 410)")
+		_, open := <-events
+		assert.False(t, open)
+	})
+
+	t.Run("may fail without permission", func(t *testing.T) {
+		t.Parallel()
+		client := newRESTClient(server.URL, "web", "", server.Client())
+
+		_, err := client.List(context.Background())
+		assert.ErrorContains(t, err, "401 Unauthorized")
+	})
+}
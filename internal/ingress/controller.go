@@ -0,0 +1,131 @@
+package ingress
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"maps"
+	"reflect"
+	"slices"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	minRetryInterval = time.Second
+	maxRetryInterval = 30 * time.Second
+)
+
+type Option struct {
+	// IngressClass is the class of Ingresses to serve. (`spec.ingressClassName`, or annotation `kubernetes.io/ingress.class`)
+	IngressClass string
+	// Static is the config of the manifest, merged with Ingresses.
+	Static Config
+	// ReloadStatic re-reads Static on changes of Ingresses. (e.g. rotated secret files of set_headers, not reloaded if nil)
+	// Static is kept as before on errors.
+	ReloadStatic func() (Config, error)
+	// Validate checks the config built from Ingresses.
+	Validate Validator
+}
+
+// Controller watches Ingresses, and builds the proxies and the ACL on changes.
+type Controller struct {
+	client    Client
+	option    Option
+	ingresses map[ /* namespace/name */ string]Ingress
+	current   *Config
+	synced    atomic.Bool
+}
+
+func NewController(client Client, option Option) *Controller {
+	return &Controller{client: client, option: option, ingresses: map[string]Ingress{}}
+}
+
+// Synced reports whether the Ingresses are listed and applied at least once.
+func (c *Controller) Synced() bool {
+	return c.synced.Load()
+}
+
+// Run watches Ingresses until the context is canceled, and calls onUpdate with the config built on changes.
+// Errors of the Kubernetes API are retried with backoff.
+func (c *Controller) Run(ctx context.Context, onUpdate func(Config)) {
+	retryInterval := minRetryInterval
+	for {
+		listed, err := c.listAndWatch(ctx, onUpdate)
+		if ctx.Err() != nil {
+			return
+		}
+		if listed {
+			retryInterval = minRetryInterval
+		}
+		if err != nil {
+			slog.Error(err.Error(), slog.Duration("retry_in", retryInterval))
+			select {
+			case <-time.After(retryInterval):
+			case <-ctx.Done():
+				return
+			}
+			retryInterval = min(retryInterval*2, maxRetryInterval)
+		}
+	}
+}
+
+// listAndWatch lists Ingresses, and watches them until the watch fails.
+func (c *Controller) listAndWatch(ctx context.Context, onUpdate func(Config)) (listed bool, err error) {
+	list, err := c.client.List(ctx)
+	if err != nil {
+		return false, err
+	}
+	c.ingresses = make(map[string]Ingress, len(list.Items))
+	for _, ingress := range list.Items {
+		c.ingresses[ingress.key()] = ingress
+	}
+	c.update(onUpdate)
+	c.synced.Store(true)
+
+	resourceVersion := list.Metadata.ResourceVersion
+	for {
+		events, err := c.client.Watch(ctx, resourceVersion)
+		if err != nil {
+			return true, err
+		}
+		for event := range events {
+			switch event.Type {
+			case EventAdded, EventModified:
+				c.ingresses[event.Ingress.key()] = event.Ingress
+			case EventDeleted:
+				delete(c.ingresses, event.Ingress.key())
+			case EventError:
+				return true, event.Err
+			}
+			resourceVersion = event.Ingress.Metadata.ResourceVersion
+			if event.Type != EventBookmark {
+				c.update(onUpdate)
+			}
+		}
+		if ctx.Err() != nil {
+			return true, nil
+		}
+	}
+}
+
+// update builds the config, and calls onUpdate if it is changed.
+func (c *Controller) update(onUpdate func(Config)) {
+	if c.option.ReloadStatic != nil {
+		if static, err := c.option.ReloadStatic(); err != nil {
+			slog.Error(fmt.Errorf("failed to reload manifest, kept as before: %w", err).Error())
+		} else {
+			c.option.Static = static
+		}
+	}
+	config, errs := Build(slices.Collect(maps.Values(c.ingresses)), c.option.IngressClass, c.option.Static, c.option.Validate)
+	for _, err := range errs {
+		slog.Error(fmt.Errorf("skipped invalid ingress: %w", err).Error())
+	}
+	if c.current != nil && reflect.DeepEqual(*c.current, config) {
+		return
+	}
+	c.current = &config
+	slog.Info("ingresses applied", slog.Int("proxies", len(config.ReverseProxy.Proxies)), slog.Int("origins", len(config.ACL)))
+	onUpdate(config)
+}
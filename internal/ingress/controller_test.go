@@ -0,0 +1,173 @@
+package ingress
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/tingtt/oauth2rbac/internal/acl"
+	reverseproxy "github.com/tingtt/oauth2rbac/internal/api/handler/reverse_proxy"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeClient is the Client of which the Ingresses are listed from items, and watched from events.
+type fakeClient struct {
+	mu      sync.Mutex
+	items   []Ingress
+	events  chan Event
+	listErr error
+	lists   int
+}
+
+func (c *fakeClient) List(ctx context.Context) (*IngressList, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.lists++
+	if c.listErr != nil {
+		return nil, c.listErr
+	}
+	return &IngressList{Metadata: ListMeta{ResourceVersion: "1"}, Items: c.items}, nil
+}
+
+func (c *fakeClient) Watch(ctx context.Context, resourceVersion string) (<-chan Event, error) {
+	events := make(chan Event)
+	go func() {
+		defer close(events)
+		for {
+			select {
+			case event := <-c.events:
+				if !send(ctx, events, event) {
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return events, nil
+}
+
+func TestController_Run(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+	www := newIngress("www", now, map[string]string{AnnotationAllow: "*"}, "www.example.com", "/")
+	docs := newIngress("docs", now, map[string]string{AnnotationAllow: "*"}, "docs.example.com", "/")
+
+	t.Run("may update config on changes of Ingresses", func(t *testing.T) {
+		t.Parallel()
+		client := &fakeClient{items: []Ingress{www}, events: make(chan Event)}
+		updates := make(chan Config)
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		controller := NewController(client, Option{IngressClass: "oauth2rbac", Validate: validateProxiedOrigins})
+		assert.False(t, controller.Synced())
+		go controller.Run(ctx, func(config Config) {
+			updates <- config
+		})
+
+		assert.Len(t, (<-updates).ReverseProxy.Proxies, 1)
+		assert.Eventually(t, controller.Synced, time.Second, 10*time.Millisecond)
+
+		client.events <- Event{Type: EventAdded, Ingress: docs}
+		assert.Len(t, (<-updates).ReverseProxy.Proxies, 2)
+
+		client.events <- Event{Type: EventBookmark}
+		client.events <- Event{Type: EventModified, Ingress: docs} // not changed
+		client.events <- Event{Type: EventDeleted, Ingress: www}
+		config := <-updates
+		assert.Len(t, config.ReverseProxy.Proxies, 1)
+		assert.Contains(t, config.ACL, "http://docs.example.com")
+	})
+
+	t.Run("may list again on watch errors", func(t *testing.T) {
+		t.Parallel()
+		client := &fakeClient{items: []Ingress{www}, events: make(chan Event)}
+		updates := make(chan Config, 10)
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go NewController(client, Option{IngressClass: "oauth2rbac", Validate: validateProxiedOrigins}).Run(ctx, func(config Config) {
+			updates <- config
+		})
+		<-updates
+
+		client.mu.Lock()
+		client.items = []Ingress{www, docs}
+		client.mu.Unlock()
+		client.events <- Event{Type: EventError, Err: errors.New("too old resource version")}
+
+		select {
+		case config := <-updates:
+			assert.Len(t, config.ReverseProxy.Proxies, 2)
+		case <-time.After(5 * time.Second):
+			t.Fatal("config not updated after watch error")
+		}
+	})
+
+	t.Run("may reload static config on changes of Ingresses", func(t *testing.T) {
+		t.Parallel()
+		client := &fakeClient{items: []Ingress{www}, events: make(chan Event)}
+		updates := make(chan Config)
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		static := func(apiKey string) Config {
+			return Config{ReverseProxy: reverseproxy.Config{Proxies: []reverseproxy.Proxy{{
+				ExternalURL: "http://static.example.com/",
+				Target:      reverseproxy.Target{URL: "http://static:80/"},
+				SetHeaders:  map[string][]string{"X-Api-Key": {apiKey}},
+			}}}, ACL: acl.Pool{"http://static.example.com": {}}}
+		}
+		reloads := make(chan func() (Config, error), 1)
+		controller := NewController(client, Option{
+			IngressClass: "oauth2rbac",
+			Static:       static("key"),
+			ReloadStatic: func() (Config, error) { return (<-reloads)() },
+			Validate:     validateProxiedOrigins,
+		})
+		go controller.Run(ctx, func(config Config) {
+			updates <- config
+		})
+		staticAPIKey := func(config Config) string {
+			for _, proxy := range config.ReverseProxy.Proxies {
+				if proxy.ExternalURL == "http://static.example.com/" {
+					return proxy.SetHeaders["X-Api-Key"][0]
+				}
+			}
+			return ""
+		}
+
+		reloads <- func() (Config, error) { return static("key"), nil }
+		assert.Equal(t, "key", staticAPIKey(<-updates))
+
+		reloads <- func() (Config, error) { return static("rotated-key"), nil }
+		client.events <- Event{Type: EventModified, Ingress: www} // not changed
+		assert.Equal(t, "rotated-key", staticAPIKey(<-updates))
+
+		reloads <- func() (Config, error) { return Config{}, errors.New("secret file not found") }
+		client.events <- Event{Type: EventAdded, Ingress: docs}
+		config := <-updates
+		assert.Len(t, config.ReverseProxy.Proxies, 3)
+		assert.Equal(t, "rotated-key", staticAPIKey(config), "kept as before")
+	})
+
+	t.Run("may stop on context canceled", func(t *testing.T) {
+		t.Parallel()
+		client := &fakeClient{listErr: errors.New("forbidden")}
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan struct{})
+		go func() {
+			NewController(client, Option{IngressClass: "oauth2rbac", Validate: validateProxiedOrigins}).Run(ctx, func(Config) {})
+			close(done)
+		}()
+
+		cancel()
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			t.Fatal("controller not stopped")
+		}
+	})
+}
@@ -0,0 +1,69 @@
+package ingress
+
+import "time"
+
+// Ingress is the subset of networking.k8s.io/v1 Ingress used to build the proxies and the ACL.
+type Ingress struct {
+	Metadata ObjectMeta  `json:"metadata"`
+	Spec     IngressSpec `json:"spec"`
+}
+
+type IngressList struct {
+	Metadata ListMeta  `json:"metadata"`
+	Items    []Ingress `json:"items"`
+}
+
+type ObjectMeta struct {
+	Name              string            `json:"name"`
+	Namespace         string            `json:"namespace"`
+	ResourceVersion   string            `json:"resourceVersion"`
+	CreationTimestamp time.Time         `json:"creationTimestamp"`
+	Annotations       map[string]string `json:"annotations"`
+}
+
+type ListMeta struct {
+	ResourceVersion string `json:"resourceVersion"`
+}
+
+type IngressSpec struct {
+	IngressClassName *string       `json:"ingressClassName"`
+	TLS              []IngressTLS  `json:"tls"`
+	Rules            []IngressRule `json:"rules"`
+}
+
+type IngressTLS struct {
+	Hosts []string `json:"hosts"`
+}
+
+type IngressRule struct {
+	Host string                `json:"host"`
+	HTTP *HTTPIngressRuleValue `json:"http"`
+}
+
+type HTTPIngressRuleValue struct {
+	Paths []HTTPIngressPath `json:"paths"`
+}
+
+type HTTPIngressPath struct {
+	Path    string         `json:"path"`
+	Backend IngressBackend `json:"backend"`
+}
+
+type IngressBackend struct {
+	Service *IngressServiceBackend `json:"service"`
+}
+
+type IngressServiceBackend struct {
+	Name string             `json:"name"`
+	Port ServiceBackendPort `json:"port"`
+}
+
+type ServiceBackendPort struct {
+	Name   string `json:"name"`
+	Number int32  `json:"number"`
+}
+
+// key returns `<namespace>/<name>` of the Ingress.
+func (i Ingress) key() string {
+	return i.Metadata.Namespace + "/" + i.Metadata.Name
+}
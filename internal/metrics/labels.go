@@ -0,0 +1,86 @@
+package metrics
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// labels is the labels of the request, set while serving the request.
+type labels struct {
+	mu     sync.Mutex
+	origin string
+	target string
+}
+
+type labelsKey struct{}
+
+func labelsFrom(ctx context.Context) *labels {
+	l, _ := ctx.Value(labelsKey{}).(*labels)
+	return l
+}
+
+// SetOrigin sets the origin label of the request. Only origins declared in ACL should be set, to bound the cardinality.
+func SetOrigin(ctx context.Context, origin string) {
+	if l := labelsFrom(ctx); l != nil {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		l.origin = origin
+	}
+}
+
+// SetTarget sets the proxy target label of the request.
+func SetTarget(ctx context.Context, target string) {
+	if l := labelsFrom(ctx); l != nil {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		l.target = target
+	}
+}
+
+// ObserveAuthorization counts the authorization outcome of the request, with the origin label.
+func ObserveAuthorization(ctx context.Context, outcome string) {
+	origin := ""
+	if l := labelsFrom(ctx); l != nil {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		origin = l.origin
+	}
+	Authorizations.WithLabelValues(origin, outcome).Inc()
+}
+
+// Middleware counts the requests and observes the latencies, with the labels set while serving.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		l := &labels{}
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		start := time.Now()
+		next.ServeHTTP(ww, r.WithContext(context.WithValue(r.Context(), labelsKey{}, l)))
+
+		status := ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		values := []string{l.origin, l.target, methodLabel(r.Method), strconv.Itoa(status)}
+		Requests.WithLabelValues(values...).Inc()
+		RequestDuration.WithLabelValues(values...).Observe(time.Since(start).Seconds())
+	})
+}
+
+// methodOther is the method label of non-standard methods, to bound the cardinality. (e.g. `PROPFIND`, random strings)
+const methodOther = "OTHER"
+
+func methodLabel(method string) string {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch,
+		http.MethodDelete, http.MethodConnect, http.MethodOptions, http.MethodTrace:
+		return method
+	}
+	return methodOther
+}
@@ -0,0 +1,90 @@
+// Package metrics is the Prometheus metrics of the proxy, exposed on the admin listener.
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "oauth2rbac"
+
+// Authorization outcomes of requests to the proxies.
+const (
+	OutcomePublic        = "public"
+	OutcomeAllowed       = "allowed"
+	OutcomeForbidden     = "forbidden"
+	OutcomeLoginRedirect = "login_redirect"
+)
+
+// Login results.
+const (
+	ResultSuccess = "success"
+	ResultFailure = "failure"
+)
+
+var (
+	Requests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "Number of HTTP requests by origin, proxy target, method and status. (origin and target are empty if not matched)",
+	}, []string{"origin", "target", "method", "status"})
+	RequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "Latency of HTTP requests by origin, proxy target, method and status.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"origin", "target", "method", "status"})
+	Authorizations = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "authorizations_total",
+		Help:      "Number of authorization decisions of requests to the proxies by origin and outcome. (public, allowed, forbidden, login_redirect)",
+	}, []string{"origin", "outcome"})
+	Logins = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "logins_total",
+		Help:      "Number of OAuth2 logins by provider and result. (success, failure)",
+	}, []string{"provider", "result"})
+	JWTRenewals = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "jwt_renewals_total",
+		Help:      "Number of JWTs renewed on authorized requests.",
+	})
+	UpstreamErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "upstream_errors_total",
+		Help:      "Number of requests failed to be proxied to the target.",
+	}, []string{"target"})
+	ACLCacheLookups = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "acl_cache_lookups_total",
+		Help:      "Number of ACL cache lookups by lookup (allowed_scopes, login_required, roles) and result. (hit, miss)",
+	}, []string{"lookup", "result"})
+)
+
+// Registry is the registry of the metrics, with the Go runtime and process metrics.
+var Registry = prometheus.NewRegistry()
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		Requests, RequestDuration, Authorizations, Logins, JWTRenewals, UpstreamErrors, ACLCacheLookups,
+	)
+}
+
+// Handler serves the metrics in the Prometheus exposition format.
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{})
+}
+
+// ObserveACLCache counts the ACL cache lookup.
+func ObserveACLCache(lookup string, hit bool) {
+	result := "miss"
+	if hit {
+		result = "hit"
+	}
+	ACLCacheLookups.WithLabelValues(lookup, result).Inc()
+}
@@ -0,0 +1,59 @@
+package metrics
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// scrape returns the metrics in the Prometheus exposition format.
+func scrape(t *testing.T) string {
+	t.Helper()
+	rw := httptest.NewRecorder()
+	Handler().ServeHTTP(rw, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body, _ := io.ReadAll(rw.Body)
+	return string(body)
+}
+
+func TestMiddleware(t *testing.T) {
+	t.Parallel()
+
+	handler := Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		SetOrigin(r.Context(), "http://metrics-test.example.com")
+		SetTarget(r.Context(), "http://upstream.metrics-test.svc:8080")
+		ObserveAuthorization(r.Context(), OutcomeForbidden)
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "http://metrics-test.example.com/", nil))
+
+	metrics := scrape(t)
+	assert.Contains(t, metrics, `oauth2rbac_http_requests_total{method="POST",origin="http://metrics-test.example.com",status="403",target="http://upstream.metrics-test.svc:8080"} 1`)
+	assert.Contains(t, metrics, `oauth2rbac_http_request_duration_seconds_count{method="POST",origin="http://metrics-test.example.com",status="403",target="http://upstream.metrics-test.svc:8080"} 1`)
+	assert.Contains(t, metrics, `oauth2rbac_authorizations_total{origin="http://metrics-test.example.com",outcome="forbidden"} 1`)
+}
+
+func TestMiddleware_nonStandardMethod(t *testing.T) {
+	t.Parallel()
+
+	handler := Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		SetOrigin(r.Context(), "http://method-test.example.com")
+	}))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("PROPFIND", "http://method-test.example.com/", nil))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("X-RANDOM-1234", "http://method-test.example.com/", nil))
+
+	metrics := scrape(t)
+	assert.Contains(t, metrics, `oauth2rbac_http_requests_total{method="OTHER",origin="http://method-test.example.com",status="200",target=""} 2`)
+	assert.NotContains(t, metrics, `method="PROPFIND"`)
+}
+
+func TestObserveAuthorization(t *testing.T) {
+	t.Parallel()
+
+	// without Middleware (e.g. in tests of handlers), the origin is empty
+	ObserveAuthorization(httptest.NewRequest(http.MethodGet, "/", nil).Context(), "observe-test")
+
+	assert.Contains(t, scrape(t), `oauth2rbac_authorizations_total{origin="",outcome="observe-test"} 1`)
+}
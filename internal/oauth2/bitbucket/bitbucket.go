@@ -0,0 +1,82 @@
+package bitbucket
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/tingtt/oauth2rbac/internal/oauth2/userinfo"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/bitbucket"
+)
+
+var Endpoint = bitbucket.Endpoint
+
+// APIBaseURL is the base URL of Bitbucket Cloud REST API. (replaced in tests)
+var APIBaseURL = "https://api.bitbucket.org/2.0"
+
+var ErrEmailNotVerified = errors.New("primary email is not verified")
+
+// GetUserInfoFunc gets the username and the confirmed primary email.
+func GetUserInfoFunc(ctx context.Context, config oauth2.Config, token *oauth2.Token) (userinfo.UserInfo, error) {
+	client := config.Client(ctx, token)
+
+	email, err := getPrimaryEmail(client)
+	if err != nil {
+		return userinfo.UserInfo{}, fmt.Errorf("failed to get email from bitbucket: %w", err)
+	}
+
+	var user struct {
+		Username string `json:"username"`
+	}
+	if err := get(client, APIBaseURL+"/user", &user); err != nil {
+		return userinfo.UserInfo{}, fmt.Errorf("failed to get username from bitbucket: %w", err)
+	}
+	return userinfo.UserInfo{Username: user.Username, Email: email}, nil
+}
+
+func getPrimaryEmail(client *http.Client) (string, error) {
+	next := APIBaseURL + "/user/emails"
+	for next != "" {
+		var page struct {
+			Values []struct {
+				Email       string `json:"email"`
+				IsPrimary   bool   `json:"is_primary"`
+				IsConfirmed bool   `json:"is_confirmed"`
+			} `json:"values"`
+			Next string `json:"next"`
+		}
+		if err := get(client, next, &page); err != nil {
+			return "", err
+		}
+		for _, email := range page.Values {
+			if !email.IsPrimary {
+				continue
+			}
+			if !email.IsConfirmed {
+				return "", ErrEmailNotVerified
+			}
+			return email.Email, nil
+		}
+		next = page.Next
+	}
+	return "", fmt.Errorf("primary email not found")
+}
+
+func get(client *http.Client, url string, v any) error {
+	resp, err := client.Get(url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return userinfo.StatusError{StatusCode: resp.StatusCode}
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("parse error: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+package bitbucket
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/tingtt/oauth2rbac/internal/oauth2/userinfo"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/oauth2"
+)
+
+func TestGetUserInfoFunc(t *testing.T) {
+	mux := http.NewServeMux()
+	writeJSON := func(rw http.ResponseWriter, v any) {
+		rw.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(rw).Encode(v)
+	}
+	var server *httptest.Server
+	mux.HandleFunc("GET /user", func(rw http.ResponseWriter, req *http.Request) {
+		writeJSON(rw, map[string]any{"username": "user"})
+	})
+	mux.HandleFunc("GET /user/emails", func(rw http.ResponseWriter, req *http.Request) {
+		if req.URL.Query().Get("page") != "2" {
+			writeJSON(rw, map[string]any{
+				"values": []map[string]any{{"email": "user@users.example.com", "is_primary": false, "is_confirmed": true}},
+				"next":   server.URL + "/user/emails?page=2",
+			})
+			return
+		}
+		writeJSON(rw, map[string]any{
+			"values": []map[string]any{{
+				"email":        "user@example.com",
+				"is_primary":   true,
+				"is_confirmed": req.Header.Get("Authorization") != "Bearer unconfirmed",
+			}},
+		})
+	})
+	server = httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	APIBaseURL = server.URL
+	t.Cleanup(func() { APIBaseURL = "https://api.bitbucket.org/2.0" })
+
+	t.Run("may get confirmed primary email of all pages", func(t *testing.T) {
+		got, err := GetUserInfoFunc(context.Background(), oauth2.Config{}, &oauth2.Token{AccessToken: "token"})
+
+		assert.NoError(t, err)
+		assert.Equal(t, userinfo.UserInfo{Username: "user", Email: "user@example.com"}, got)
+	})
+
+	t.Run("may reject unconfirmed primary email", func(t *testing.T) {
+		_, err := GetUserInfoFunc(context.Background(), oauth2.Config{}, &oauth2.Token{AccessToken: "unconfirmed"})
+
+		assert.ErrorIs(t, err, ErrEmailNotVerified)
+	})
+}
@@ -5,38 +5,75 @@ import (
 	"crypto/rand"
 	"encoding/base64"
 
+	"github.com/tingtt/oauth2rbac/internal/oauth2/userinfo"
+
 	"golang.org/x/oauth2"
 )
 
 type Config = oauth2.Config
+type UserInfo = userinfo.UserInfo
 
 type Service interface {
 	Config() Config
-	AuthCodeURL(redirectUrl string) string
+	// Type returns the provider type. (e.g. "github")
+	Type() string
+	DisplayName() string
+	// Icon returns the icon name of the provider types, or the image URL.
+	Icon() string
+	// AuthCodeURL returns the URL to the consent page.
+	// If reauthenticate is true, the provider is asked to authenticate the user again.
+	AuthCodeURL(redirectUrl string, reauthenticate bool) string
 	Exchange(ctx context.Context, code string, redirectURL string, opts ...oauth2.AuthCodeOption) (*oauth2.Token, error)
-	GetUserInfo(ctx context.Context, token *oauth2.Token) (username, email string, err error)
+	GetUserInfo(ctx context.Context, token *oauth2.Token) (UserInfo, error)
+	// DiscoveryURL returns the OpenID Connect discovery document URL. (empty if not OpenID Connect)
+	DiscoveryURL() string
+	// TokenSource returns the token source refreshing the token with its refresh token.
+	TokenSource(ctx context.Context, token *oauth2.Token) oauth2.TokenSource
 }
 
-func New(
-	c *oauth2.Config,
-	getUserInfoFunc func(ctx context.Context, config oauth2.Config, token *oauth2.Token) (username string, email string, err error),
-) Service {
-	return &config{value: c, getUserInfoFunc: getUserInfoFunc}
+// New returns the Service of the provider.
+// If approvalForce is true, the consent screen is shown on every login to always receive a refresh token.
+func New(c *oauth2.Config, providerType string, provider Provider, approvalForce bool) Service {
+	return &config{value: c, providerType: providerType, provider: provider, approvalForce: approvalForce}
 }
 
 type config struct {
-	value           *oauth2.Config
-	getUserInfoFunc func(ctx context.Context, config oauth2.Config, token *oauth2.Token) (username string, email string, err error)
+	value         *oauth2.Config
+	providerType  string
+	provider      Provider
+	approvalForce bool
 }
 
 func (c *config) Config() Config {
 	return *c.value
 }
 
-func (c *config) AuthCodeURL(redirectURL string) string {
+func (c *config) Type() string {
+	return c.providerType
+}
+
+func (c *config) DisplayName() string {
+	return c.provider.DisplayName
+}
+
+func (c *config) Icon() string {
+	if c.provider.Icon == "" {
+		return c.providerType
+	}
+	return c.provider.Icon
+}
+
+func (c *config) AuthCodeURL(redirectURL string, reauthenticate bool) string {
 	config := c.Config() /* copy as base config */
 	config.RedirectURL = redirectURL
-	return config.AuthCodeURL(state(), oauth2.AccessTypeOffline, oauth2.ApprovalForce)
+	opts := append([]oauth2.AuthCodeOption{oauth2.AccessTypeOffline}, c.provider.AuthCodeOptions...)
+	if c.approvalForce {
+		opts = append(opts, oauth2.ApprovalForce)
+	}
+	if reauthenticate {
+		opts = append(opts, c.provider.ReauthenticateOptions...)
+	}
+	return config.AuthCodeURL(state(), opts...)
 }
 
 func state() string {
@@ -51,6 +88,14 @@ func (c *config) Exchange(ctx context.Context, code string, redirectURL string,
 	return config.Exchange(ctx, code, opts...)
 }
 
-func (c *config) GetUserInfo(ctx context.Context, token *oauth2.Token) (string, string, error) {
-	return c.getUserInfoFunc(ctx, *c.value, token)
+func (c *config) GetUserInfo(ctx context.Context, token *oauth2.Token) (UserInfo, error) {
+	return c.provider.GetUserInfoFunc(ctx, *c.value, token)
+}
+
+func (c *config) DiscoveryURL() string {
+	return c.provider.DiscoveryURL
+}
+
+func (c *config) TokenSource(ctx context.Context, token *oauth2.Token) oauth2.TokenSource {
+	return c.value.TokenSource(ctx, token)
 }
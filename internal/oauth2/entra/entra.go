@@ -0,0 +1,102 @@
+package entra
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/tingtt/oauth2rbac/internal/oauth2/userinfo"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/microsoft"
+)
+
+// NewEndpoint returns the endpoint of the Microsoft Entra ID tenant.
+func NewEndpoint(tenantID string) oauth2.Endpoint {
+	return microsoft.AzureADEndpoint(tenantID)
+}
+
+// NewDiscoveryURL returns the OpenID Connect discovery document URL of the Microsoft Entra ID tenant.
+func NewDiscoveryURL(tenantID string) string {
+	return "https://login.microsoftonline.com/" + url.PathEscape(tenantID) + "/v2.0/.well-known/openid-configuration"
+}
+
+// MultiTenants is the tenant aliases allowing users of any tenant to sign in.
+// They are not allowed, because the emails of other tenants are not verified.
+var MultiTenants = []string{"common", "organizations", "consumers"}
+
+// GraphBaseURL is the base URL of Microsoft Graph API. (replaced in tests)
+var GraphBaseURL = "https://graph.microsoft.com/v1.0"
+
+// ScopeGroupMemberRead is the scope required to read group memberships.
+const ScopeGroupMemberRead = "GroupMember.Read.All"
+
+// GetUserInfoFunc gets the user principal name, the email and the group object IDs via Microsoft Graph.
+// Groups are read only if ScopeGroupMemberRead is granted.
+func GetUserInfoFunc(ctx context.Context, config oauth2.Config, token *oauth2.Token) (userinfo.UserInfo, error) {
+	client := config.Client(ctx, token)
+
+	var me struct {
+		UserPrincipalName string `json:"userPrincipalName"`
+		Mail              string `json:"mail"`
+	}
+	if err := get(client, GraphBaseURL+"/me?$select=userPrincipalName,mail", &me); err != nil {
+		return userinfo.UserInfo{}, fmt.Errorf("failed to get user from microsoft graph: %w", err)
+	}
+	email := me.Mail
+	if /* no mailbox */ email == "" {
+		email = me.UserPrincipalName
+	}
+
+	userInfo := userinfo.UserInfo{Username: me.UserPrincipalName, Email: email}
+	if /* group memberships readable */ slices.Contains(config.Scopes, ScopeGroupMemberRead) {
+		groups, err := getGroups(client)
+		if err != nil {
+			return userinfo.UserInfo{}, fmt.Errorf("failed to get groups from microsoft graph: %w", err)
+		}
+		userInfo.EntraGroups = groups
+	}
+	return userInfo, nil
+}
+
+// getGroups returns the object IDs of the groups the user belongs to, including nested groups.
+func getGroups(client *http.Client) ([]string, error) {
+	groups := []string{}
+	next := GraphBaseURL + "/me/transitiveMemberOf/microsoft.graph.group?$select=id"
+	for next != "" {
+		var page struct {
+			Value []struct {
+				ID string `json:"id"`
+			} `json:"value"`
+			NextLink string `json:"@odata.nextLink"`
+		}
+		if err := get(client, next, &page); err != nil {
+			return nil, err
+		}
+		for _, group := range page.Value {
+			groups = append(groups, strings.ToLower(group.ID))
+		}
+		next = page.NextLink
+	}
+	slices.Sort(groups)
+	return groups, nil
+}
+
+func get(client *http.Client, url string, v any) error {
+	resp, err := client.Get(url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return userinfo.StatusError{StatusCode: resp.StatusCode}
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("parse error: %w", err)
+	}
+	return nil
+}
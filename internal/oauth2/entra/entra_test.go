@@ -0,0 +1,67 @@
+package entra
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/tingtt/oauth2rbac/internal/oauth2/userinfo"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/oauth2"
+)
+
+func TestGetUserInfoFunc(t *testing.T) {
+	mux := http.NewServeMux()
+	writeJSON := func(rw http.ResponseWriter, v any) {
+		rw.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(rw).Encode(v)
+	}
+	var server *httptest.Server
+	mux.HandleFunc("GET /me", func(rw http.ResponseWriter, req *http.Request) {
+		if req.Header.Get("Authorization") == "Bearer nomailbox" {
+			writeJSON(rw, map[string]any{"userPrincipalName": "user@example.onmicrosoft.com", "mail": nil})
+			return
+		}
+		writeJSON(rw, map[string]any{"userPrincipalName": "user@example.onmicrosoft.com", "mail": "user@example.com"})
+	})
+	mux.HandleFunc("GET /me/transitiveMemberOf/microsoft.graph.group", func(rw http.ResponseWriter, req *http.Request) {
+		if req.URL.Query().Get("$skiptoken") == "" {
+			writeJSON(rw, map[string]any{
+				"value":           []map[string]any{{"id": "BBBBBBBB-0000-0000-0000-000000000000"}},
+				"@odata.nextLink": server.URL + "/me/transitiveMemberOf/microsoft.graph.group?$select=id&$skiptoken=2",
+			})
+			return
+		}
+		writeJSON(rw, map[string]any{
+			"value": []map[string]any{{"id": "aaaaaaaa-0000-0000-0000-000000000000"}},
+		})
+	})
+	server = httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	GraphBaseURL = server.URL
+	t.Cleanup(func() { GraphBaseURL = "https://graph.microsoft.com/v1.0" })
+
+	t.Run("may not read groups without GroupMember.Read.All scope", func(t *testing.T) {
+		got, err := GetUserInfoFunc(context.Background(), oauth2.Config{Scopes: []string{"User.Read"}}, &oauth2.Token{AccessToken: "token"})
+
+		assert.NoError(t, err)
+		assert.Equal(t, userinfo.UserInfo{Username: "user@example.onmicrosoft.com", Email: "user@example.com"}, got)
+	})
+
+	t.Run("may use user principal name without mailbox", func(t *testing.T) {
+		got, err := GetUserInfoFunc(context.Background(), oauth2.Config{}, &oauth2.Token{AccessToken: "nomailbox"})
+
+		assert.NoError(t, err)
+		assert.Equal(t, "user@example.onmicrosoft.com", got.Email)
+	})
+
+	t.Run("may read groups of all pages with GroupMember.Read.All scope", func(t *testing.T) {
+		got, err := GetUserInfoFunc(context.Background(), oauth2.Config{Scopes: []string{"User.Read", ScopeGroupMemberRead}}, &oauth2.Token{AccessToken: "token"})
+
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"aaaaaaaa-0000-0000-0000-000000000000", "bbbbbbbb-0000-0000-0000-000000000000"}, got.EntraGroups)
+	})
+}
@@ -3,8 +3,13 @@ package github
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/tingtt/oauth2rbac/internal/oauth2/userinfo"
 
 	"golang.org/x/oauth2"
 	"golang.org/x/oauth2/github"
@@ -12,56 +17,108 @@ import (
 
 var Endpoint = github.Endpoint
 
-func GetUserInfoFunc(ctx context.Context, config oauth2.Config, token *oauth2.Token) (string /* id */, string /* email */, error) {
-	client := config.Client(ctx, token)
+// APIBaseURL is the base URL of GitHub REST API. (replaced in tests)
+var APIBaseURL = "https://api.github.com"
 
-	email, err := getPrimaryEmail(client)
-	if err != nil {
-		return "", "", fmt.Errorf("failed to get emails from github: %w", err)
-	}
+// ScopeReadOrg is the scope required to read organization and team memberships.
+const ScopeReadOrg = "read:org"
 
-	id, err := getID(client)
-	if err != nil {
-		return "", "", fmt.Errorf("failed to get id from github: %w", err)
-	}
+var ErrEmailNotVerified = errors.New("primary email is not verified")
+
+// Option is the settings for GitHub.
+type Option struct {
+	// UseSecondaryEmails allows verified secondary emails to be matched against ACL, instead of the primary email.
+	UseSecondaryEmails bool
+}
+
+var GetUserInfoFunc = NewGetUserInfoFunc(Option{})
+
+// NewGetUserInfoFunc returns the function to get the user info.
+// Unverified emails are never used.
+func NewGetUserInfoFunc(o Option) func(ctx context.Context, config oauth2.Config, token *oauth2.Token) (userinfo.UserInfo, error) {
+	return func(ctx context.Context, config oauth2.Config, token *oauth2.Token) (userinfo.UserInfo, error) {
+		client := config.Client(ctx, token)
+
+		email, secondaryEmails, err := getVerifiedEmails(client, o.UseSecondaryEmails)
+		if err != nil {
+			return userinfo.UserInfo{}, fmt.Errorf("failed to get emails from github: %w", err)
+		}
+
+		id, err := getID(client)
+		if err != nil {
+			return userinfo.UserInfo{}, fmt.Errorf("failed to get id from github: %w", err)
+		}
 
-	return id, email, nil
+		userInfo := userinfo.UserInfo{Username: id, Email: email, SecondaryEmails: secondaryEmails}
+		if /* org memberships readable */ slices.Contains(config.Scopes, ScopeReadOrg) {
+			userInfo.GitHubOrgs, err = getOrgs(client)
+			if err != nil {
+				return userinfo.UserInfo{}, fmt.Errorf("failed to get orgs from github: %w", err)
+			}
+			userInfo.GitHubTeams, err = getTeams(client)
+			if err != nil {
+				return userinfo.UserInfo{}, fmt.Errorf("failed to get teams from github: %w", err)
+			}
+		}
+		return userInfo, nil
+	}
 }
 
-func getPrimaryEmail(client *http.Client) (string, error) {
-	resp, err := client.Get("https://api.github.com/user/emails")
+// getVerifiedEmails returns the verified primary email, and the other verified emails if withSecondary is true.
+// If the primary email is not verified, the first verified secondary email is returned as the primary email.
+func getVerifiedEmails(client *http.Client, withSecondary bool) (string, []string, error) {
+	resp, err := client.Get(APIBaseURL + "/user/emails")
 	if err != nil {
-		return "", err
+		return "", nil, err
 	}
 	defer resp.Body.Close()
 	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("received status code %d", resp.StatusCode)
+		return "", nil, userinfo.StatusError{StatusCode: resp.StatusCode}
 	}
 
-	var bodyEmails []struct {
-		Email   string `json:"email"`
-		Primary bool   `json:"primary"`
+	type bodyEmail struct {
+		Email    string `json:"email"`
+		Primary  bool   `json:"primary"`
+		Verified bool   `json:"verified"`
 	}
+	var bodyEmails []bodyEmail
 	if err := json.NewDecoder(resp.Body).Decode(&bodyEmails); err != nil {
-		return "", fmt.Errorf("parse error: %w", err)
+		return "", nil, fmt.Errorf("parse error: %w", err)
 	}
 
-	for _, email := range bodyEmails {
-		if email.Primary {
-			return email.Email, nil
+	primaryIdx := slices.IndexFunc(bodyEmails, func(email bodyEmail) bool { return email.Primary })
+	if primaryIdx == -1 {
+		return "", nil, fmt.Errorf("primary email not found")
+	}
+
+	emails := []string{}
+	if bodyEmails[primaryIdx].Verified {
+		emails = append(emails, bodyEmails[primaryIdx].Email)
+	}
+	if withSecondary {
+		for i, email := range bodyEmails {
+			if i != primaryIdx && email.Verified {
+				emails = append(emails, email.Email)
+			}
 		}
 	}
-	return "", fmt.Errorf("primary email not found")
+	if len(emails) == 0 {
+		return "", nil, ErrEmailNotVerified
+	}
+	if len(emails) == 1 {
+		return emails[0], nil, nil
+	}
+	return emails[0], emails[1:], nil
 }
 
 func getID(client *http.Client) (string, error) {
-	resp, err := client.Get("https://api.github.com/user")
+	resp, err := client.Get(APIBaseURL + "/user")
 	if err != nil {
 		return "", err
 	}
 	defer resp.Body.Close()
 	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("received status code %d", resp.StatusCode)
+		return "", userinfo.StatusError{StatusCode: resp.StatusCode}
 	}
 
 	var responseBody struct {
@@ -72,3 +129,67 @@ func getID(client *http.Client) (string, error) {
 	}
 	return responseBody.ID, nil
 }
+
+func getOrgs(client *http.Client) ([]string, error) {
+	var bodyOrgs []struct {
+		Login string `json:"login"`
+	}
+	if err := getAllPages(client, "/user/orgs", &bodyOrgs); err != nil {
+		return nil, err
+	}
+
+	orgs := make([]string, 0, len(bodyOrgs))
+	for _, org := range bodyOrgs {
+		orgs = append(orgs, strings.ToLower(org.Login))
+	}
+	slices.Sort(orgs)
+	return orgs, nil
+}
+
+func getTeams(client *http.Client) ([]string, error) {
+	var bodyTeams []struct {
+		Slug         string `json:"slug"`
+		Organization struct {
+			Login string `json:"login"`
+		} `json:"organization"`
+	}
+	if err := getAllPages(client, "/user/teams", &bodyTeams); err != nil {
+		return nil, err
+	}
+
+	teams := make([]string, 0, len(bodyTeams))
+	for _, team := range bodyTeams {
+		teams = append(teams, strings.ToLower(team.Organization.Login+"/"+team.Slug))
+	}
+	slices.Sort(teams)
+	return teams, nil
+}
+
+// getAllPages appends items of all pages of the list API to v.
+func getAllPages[T any](client *http.Client, path string, v *[]T) error {
+	const perPage = 100
+	for page := 1; ; page++ {
+		resp, err := client.Get(fmt.Sprintf("%s%s?per_page=%d&page=%d", APIBaseURL, path, perPage, page))
+		if err != nil {
+			return err
+		}
+		var items []T
+		err = func() error {
+			defer resp.Body.Close()
+			if resp.StatusCode != http.StatusOK {
+				return userinfo.StatusError{StatusCode: resp.StatusCode}
+			}
+			if err := json.NewDecoder(resp.Body).Decode(&items); err != nil {
+				return fmt.Errorf("parse error: %w", err)
+			}
+			return nil
+		}()
+		if err != nil {
+			return err
+		}
+		*v = append(*v, items...)
+		if len(items) < perPage {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package github
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/tingtt/oauth2rbac/internal/oauth2/userinfo"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/oauth2"
+)
+
+func newGitHubAPIStub(t *testing.T, orgCount int) *httptest.Server {
+	t.Helper()
+	mux := http.NewServeMux()
+	writeJSON := func(rw http.ResponseWriter, v any) {
+		rw.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(rw).Encode(v)
+	}
+	mux.HandleFunc("GET /user", func(rw http.ResponseWriter, req *http.Request) {
+		writeJSON(rw, map[string]any{"login": "octocat"})
+	})
+	mux.HandleFunc("GET /user/emails", func(rw http.ResponseWriter, req *http.Request) {
+		if req.Header.Get("Authorization") == "Bearer unverified" {
+			writeJSON(rw, []map[string]any{
+				{"email": "octocat@users.noreply.github.com", "primary": false, "verified": true},
+				{"email": "octocat@corp.example.com", "primary": false, "verified": false},
+				{"email": "octocat@example.com", "primary": true, "verified": false},
+			})
+			return
+		}
+		writeJSON(rw, []map[string]any{
+			{"email": "octocat@users.noreply.github.com", "primary": false, "verified": true},
+			{"email": "octocat@corp.example.com", "primary": false, "verified": false},
+			{"email": "octocat@example.com", "primary": true, "verified": true},
+		})
+	})
+	mux.HandleFunc("GET /user/orgs", func(rw http.ResponseWriter, req *http.Request) {
+		page, _ := strconv.Atoi(req.URL.Query().Get("page"))
+		perPage, _ := strconv.Atoi(req.URL.Query().Get("per_page"))
+		orgs := []map[string]any{}
+		for i := (page - 1) * perPage; i < min(page*perPage, orgCount); i++ {
+			orgs = append(orgs, map[string]any{"login": fmt.Sprintf("Org%03d", i)})
+		}
+		writeJSON(rw, orgs)
+	})
+	mux.HandleFunc("GET /user/teams", func(rw http.ResponseWriter, req *http.Request) {
+		writeJSON(rw, []map[string]any{
+			{"slug": "sre", "organization": map[string]any{"login": "Acme"}},
+			{"slug": "dev", "organization": map[string]any{"login": "Acme"}},
+		})
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestGetUserInfoFunc(t *testing.T) {
+	server := newGitHubAPIStub(t, 101)
+	APIBaseURL = server.URL
+	t.Cleanup(func() { APIBaseURL = "https://api.github.com" })
+
+	token := &oauth2.Token{AccessToken: "token"}
+
+	t.Run("may not read memberships without read:org scope", func(t *testing.T) {
+		got, err := GetUserInfoFunc(context.Background(), oauth2.Config{Scopes: []string{"user:email", "read:user"}}, token)
+
+		assert.NoError(t, err)
+		assert.Equal(t, userinfo.UserInfo{Username: "octocat", Email: "octocat@example.com"}, got)
+	})
+
+	t.Run("may reject unverified primary email", func(t *testing.T) {
+		_, err := GetUserInfoFunc(context.Background(), oauth2.Config{}, &oauth2.Token{AccessToken: "unverified"})
+
+		assert.ErrorIs(t, err, ErrEmailNotVerified)
+	})
+
+	t.Run("may use verified secondary emails", func(t *testing.T) {
+		getUserInfo := NewGetUserInfoFunc(Option{UseSecondaryEmails: true})
+
+		got, err := getUserInfo(context.Background(), oauth2.Config{}, token)
+		assert.NoError(t, err)
+		assert.Equal(t, "octocat@example.com", got.Email)
+		assert.Equal(t, []string{"octocat@users.noreply.github.com"}, got.SecondaryEmails)
+
+		got, err = getUserInfo(context.Background(), oauth2.Config{}, &oauth2.Token{AccessToken: "unverified"})
+		assert.NoError(t, err)
+		assert.Equal(t, "octocat@users.noreply.github.com", got.Email)
+		assert.Empty(t, got.SecondaryEmails)
+	})
+
+	t.Run("may read memberships with read:org scope", func(t *testing.T) {
+		got, err := GetUserInfoFunc(context.Background(), oauth2.Config{Scopes: []string{"user:email", "read:user", ScopeReadOrg}}, token)
+
+		assert.NoError(t, err)
+		assert.Equal(t, "octocat@example.com", got.Email)
+		assert.Len(t, got.GitHubOrgs, 101)
+		assert.Equal(t, "org000", got.GitHubOrgs[0])
+		assert.Equal(t, []string{"acme/dev", "acme/sre"}, got.GitHubTeams)
+	})
+}
@@ -0,0 +1,80 @@
+package gitlab
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/tingtt/oauth2rbac/internal/oauth2/userinfo"
+
+	"golang.org/x/oauth2"
+)
+
+// DefaultBaseURL is the base URL of GitLab.com.
+const DefaultBaseURL = "https://gitlab.com"
+
+var Endpoint = NewEndpoint(DefaultBaseURL)
+
+// NewEndpoint returns the endpoint of the GitLab instance. (e.g. "https://gitlab.example.com")
+func NewEndpoint(baseURL string) oauth2.Endpoint {
+	baseURL = strings.TrimSuffix(baseURL, "/")
+	return oauth2.Endpoint{
+		AuthURL:  baseURL + "/oauth/authorize",
+		TokenURL: baseURL + "/oauth/token",
+	}
+}
+
+// NewDiscoveryURL returns the OpenID Connect discovery document URL of the GitLab instance.
+func NewDiscoveryURL(baseURL string) string {
+	return strings.TrimSuffix(baseURL, "/") + "/.well-known/openid-configuration"
+}
+
+var ErrEmailNotVerified = errors.New("email is not verified")
+
+// Option is the settings for GitLab.
+type Option struct {
+	// BaseURL is the base URL of the self-hosted GitLab instance. (default: DefaultBaseURL)
+	BaseURL string
+}
+
+func (o Option) baseURL() string {
+	if o.BaseURL == "" {
+		return DefaultBaseURL
+	}
+	return strings.TrimSuffix(o.BaseURL, "/")
+}
+
+var GetUserInfoFunc = NewGetUserInfoFunc(Option{})
+
+// NewGetUserInfoFunc returns the function to get the user info from the OpenID Connect userinfo endpoint.
+// Users with unverified emails are rejected.
+func NewGetUserInfoFunc(o Option) func(ctx context.Context, config oauth2.Config, token *oauth2.Token) (userinfo.UserInfo, error) {
+	return func(ctx context.Context, config oauth2.Config, token *oauth2.Token) (userinfo.UserInfo, error) {
+		client := config.Client(ctx, token)
+
+		resp, err := client.Get(o.baseURL() + "/oauth/userinfo")
+		if err != nil {
+			return userinfo.UserInfo{}, fmt.Errorf("failed to get userinfo from gitlab: %w", err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return userinfo.UserInfo{}, fmt.Errorf("failed to get userinfo from gitlab: %w", userinfo.StatusError{StatusCode: resp.StatusCode})
+		}
+
+		var responseBody struct {
+			Nickname      string `json:"nickname"`
+			Email         string `json:"email"`
+			EmailVerified bool   `json:"email_verified"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&responseBody); err != nil {
+			return userinfo.UserInfo{}, fmt.Errorf("failed to get userinfo from gitlab: parse error: %w", err)
+		}
+		if responseBody.Email == "" || !responseBody.EmailVerified {
+			return userinfo.UserInfo{}, ErrEmailNotVerified
+		}
+		return userinfo.UserInfo{Username: responseBody.Nickname, Email: responseBody.Email}, nil
+	}
+}
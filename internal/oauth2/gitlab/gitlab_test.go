@@ -0,0 +1,51 @@
+package gitlab
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/tingtt/oauth2rbac/internal/oauth2/userinfo"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/oauth2"
+)
+
+func TestNewGetUserInfoFunc(t *testing.T) {
+	userInfos := map[ /* access token */ string]map[string]any{
+		"verified":   {"nickname": "user", "email": "user@example.com", "email_verified": true},
+		"unverified": {"nickname": "user", "email": "user@example.com", "email_verified": false},
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /gitlab/oauth/userinfo", func(rw http.ResponseWriter, req *http.Request) {
+		accessToken := req.Header.Get("Authorization")[len("Bearer "):]
+		rw.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(rw).Encode(userInfos[accessToken])
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	getUserInfo := NewGetUserInfoFunc(Option{BaseURL: server.URL + "/gitlab/"})
+
+	t.Run("may get verified email from self-hosted GitLab", func(t *testing.T) {
+		got, err := getUserInfo(context.Background(), oauth2.Config{}, &oauth2.Token{AccessToken: "verified"})
+
+		assert.NoError(t, err)
+		assert.Equal(t, userinfo.UserInfo{Username: "user", Email: "user@example.com"}, got)
+	})
+
+	t.Run("may reject unverified email", func(t *testing.T) {
+		_, err := getUserInfo(context.Background(), oauth2.Config{}, &oauth2.Token{AccessToken: "unverified"})
+
+		assert.ErrorIs(t, err, ErrEmailNotVerified)
+	})
+}
+
+func TestNewEndpoint(t *testing.T) {
+	assert.Equal(t, oauth2.Endpoint{
+		AuthURL:  "https://gitlab.example.com/oauth/authorize",
+		TokenURL: "https://gitlab.example.com/oauth/token",
+	}, NewEndpoint("https://gitlab.example.com/"))
+}
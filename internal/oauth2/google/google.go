@@ -2,28 +2,99 @@ package google
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/tingtt/oauth2rbac/internal/oauth2/userinfo"
 
 	"golang.org/x/oauth2"
 	"golang.org/x/oauth2/google"
+	"google.golang.org/api/googleapi"
 	apiv2google "google.golang.org/api/oauth2/v2"
 	"google.golang.org/api/option"
 )
 
 var Endpoint = google.Endpoint
 
-func GetUserInfoFunc(ctx context.Context, config oauth2.Config, token *oauth2.Token) (username, email string, err error) {
-	client := config.Client(ctx, token)
+// DiscoveryURL is the OpenID Connect discovery document URL of Google.
+const DiscoveryURL = "https://accounts.google.com/.well-known/openid-configuration"
 
-	service, err := apiv2google.NewService(ctx, option.WithHTTPClient(client))
-	if err != nil {
-		return "", "", fmt.Errorf("failed to instanciate OAuth2 service: %w", err)
-	}
+// APIEndpoint overrides the endpoint of Google OAuth2 API. (replaced in tests)
+var APIEndpoint = ""
+
+var ErrEmailNotVerified = errors.New("email is not verified")
 
-	userInfo, err := service.Userinfo.Get().Do()
-	if err != nil {
-		return "", "", fmt.Errorf("failed to get email: %w", err)
+// Option is the settings for Google Workspace.
+type Option struct {
+	// HostedDomains restricts users to the Google Workspace domains. (not restricted if empty)
+	HostedDomains []string
+	// GroupResolver resolves Google Groups of users. (groups are not resolved if nil)
+	GroupResolver GroupResolver
+}
+
+// AuthCodeOptions returns the `hd` parameter hinting the hosted domain on the account chooser.
+func (o Option) AuthCodeOptions() []oauth2.AuthCodeOption {
+	switch len(o.HostedDomains) {
+	case 0:
+		return nil
+	case 1:
+		return []oauth2.AuthCodeOption{oauth2.SetAuthURLParam("hd", o.HostedDomains[0])}
+	default:
+		return []oauth2.AuthCodeOption{oauth2.SetAuthURLParam("hd", "*")}
 	}
+}
 
-	return userInfo.Name, userInfo.Email, nil
+var GetUserInfoFunc = NewGetUserInfoFunc(Option{})
+
+// NewGetUserInfoFunc returns the function to get the user info.
+// Users with unverified emails, or outside of the hosted domains, are rejected.
+func NewGetUserInfoFunc(o Option) func(ctx context.Context, config oauth2.Config, token *oauth2.Token) (userinfo.UserInfo, error) {
+	return func(ctx context.Context, config oauth2.Config, token *oauth2.Token) (userinfo.UserInfo, error) {
+		client := config.Client(ctx, token)
+
+		opts := []option.ClientOption{option.WithHTTPClient(client)}
+		if APIEndpoint != "" {
+			opts = append(opts, option.WithEndpoint(APIEndpoint))
+		}
+		service, err := apiv2google.NewService(ctx, opts...)
+		if err != nil {
+			return userinfo.UserInfo{}, fmt.Errorf("failed to instanciate OAuth2 service: %w", err)
+		}
+
+		userInfo, err := service.Userinfo.Get().Do()
+		if err != nil {
+			var apiErr *googleapi.Error
+			if errors.As(err, &apiErr) {
+				return userinfo.UserInfo{}, fmt.Errorf("failed to get email: %w: %w", userinfo.StatusError{StatusCode: apiErr.Code}, err)
+			}
+			return userinfo.UserInfo{}, fmt.Errorf("failed to get email: %w", err)
+		}
+		if userInfo.VerifiedEmail == nil || !*userInfo.VerifiedEmail {
+			return userinfo.UserInfo{}, ErrEmailNotVerified
+		}
+		if len(o.HostedDomains) != 0 && !slices.Contains(o.HostedDomains, userInfo.Hd) {
+			return userinfo.UserInfo{}, fmt.Errorf("hosted domain `%s` is not allowed", userInfo.Hd)
+		}
+
+		var groups []string
+		if o.GroupResolver != nil && userInfo.Hd != "" /* Google Workspace account */ {
+			groups, err = o.GroupResolver.Groups(ctx, userInfo.Email)
+			if err != nil {
+				return userinfo.UserInfo{}, fmt.Errorf("failed to get groups: %w", err)
+			}
+			for i := range groups {
+				groups[i] = strings.ToLower(groups[i])
+			}
+			slices.Sort(groups)
+		}
+
+		return userinfo.UserInfo{
+			Username:           userInfo.Name,
+			Email:              userInfo.Email,
+			GoogleHostedDomain: userInfo.Hd,
+			GoogleGroups:       groups,
+		}, nil
+	}
 }
@@ -0,0 +1,102 @@
+package google
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/tingtt/oauth2rbac/internal/oauth2/userinfo"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/oauth2"
+)
+
+type fakeGroupResolver map[ /* email */ string][]string
+
+func (r fakeGroupResolver) Groups(ctx context.Context, email string) ([]string, error) {
+	return r[email], nil
+}
+
+func TestNewGetUserInfoFunc(t *testing.T) {
+	userInfos := map[ /* access token */ string]map[string]any{
+		"workspace":  {"name": "User", "email": "user@example.com", "verified_email": true, "hd": "example.com"},
+		"consumer":   {"name": "User", "email": "user@gmail.com", "verified_email": true},
+		"other":      {"name": "User", "email": "user@other.example.com", "verified_email": true, "hd": "other.example.com"},
+		"unverified": {"name": "User", "email": "user@example.com", "verified_email": false, "hd": "example.com"},
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		accessToken := req.Header.Get("Authorization")[len("Bearer "):]
+		rw.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(rw).Encode(userInfos[accessToken])
+	}))
+	t.Cleanup(server.Close)
+	APIEndpoint = server.URL + "/"
+	t.Cleanup(func() { APIEndpoint = "" })
+
+	getUserInfo := NewGetUserInfoFunc(Option{
+		HostedDomains: []string{"example.com"},
+		GroupResolver: fakeGroupResolver{"user@example.com": {"SRE@example.com", "all@example.com"}},
+	})
+
+	tests := []struct {
+		name        string
+		getUserInfo func(ctx context.Context, config oauth2.Config, token *oauth2.Token) (userinfo.UserInfo, error)
+		accessToken string
+		want        userinfo.UserInfo
+		wantErr     bool
+	}{
+		{
+			name:        "workspace user may have hosted domain and groups",
+			getUserInfo: getUserInfo,
+			accessToken: "workspace",
+			want: userinfo.UserInfo{
+				Username:           "User",
+				Email:              "user@example.com",
+				GoogleHostedDomain: "example.com",
+				GoogleGroups:       []string{"all@example.com", "sre@example.com"},
+			},
+		},
+		{
+			name:        "other hosted domain may be rejected",
+			getUserInfo: getUserInfo,
+			accessToken: "other",
+			wantErr:     true,
+		},
+		{
+			name:        "consumer account may be rejected with hosted domains",
+			getUserInfo: getUserInfo,
+			accessToken: "consumer",
+			wantErr:     true,
+		},
+		{
+			name:        "consumer account may be allowed without hosted domains",
+			getUserInfo: GetUserInfoFunc,
+			accessToken: "consumer",
+			want:        userinfo.UserInfo{Username: "User", Email: "user@gmail.com"},
+		},
+		{
+			name:        "unverified email may be rejected",
+			getUserInfo: GetUserInfoFunc,
+			accessToken: "unverified",
+			wantErr:     true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.getUserInfo(context.Background(), oauth2.Config{}, &oauth2.Token{AccessToken: tt.accessToken})
+
+			assert.Equal(t, tt.wantErr, err != nil, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestOption_AuthCodeOptions(t *testing.T) {
+	config := oauth2.Config{Endpoint: Endpoint}
+
+	assert.NotContains(t, config.AuthCodeURL("state", Option{}.AuthCodeOptions()...), "hd=")
+	assert.Contains(t, config.AuthCodeURL("state", Option{HostedDomains: []string{"example.com"}}.AuthCodeOptions()...), "hd=example.com")
+	assert.Contains(t, config.AuthCodeURL("state", Option{HostedDomains: []string{"a.example.com", "b.example.com"}}.AuthCodeOptions()...), "hd=%2A")
+}
@@ -0,0 +1,49 @@
+package google
+
+import (
+	"context"
+	"fmt"
+
+	"golang.org/x/oauth2/google"
+	admin "google.golang.org/api/admin/directory/v1"
+	"google.golang.org/api/option"
+)
+
+// GroupResolver resolves the emails of Google Groups the user belongs to.
+type GroupResolver interface {
+	Groups(ctx context.Context, email string) ([]string, error)
+}
+
+// NewDirectoryGroupResolver returns the GroupResolver using the Admin SDK Directory API,
+// with the service account key impersonating the Workspace admin (domain-wide delegation).
+func NewDirectoryGroupResolver(ctx context.Context, serviceAccountKeyJSON []byte, adminEmail string) (GroupResolver, error) {
+	config, err := google.JWTConfigFromJSON(serviceAccountKeyJSON, admin.AdminDirectoryGroupReadonlyScope)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load service account key: %w", err)
+	}
+	config.Subject = adminEmail
+	service, err := admin.NewService(ctx, option.WithHTTPClient(config.Client(ctx)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to instanciate directory service: %w", err)
+	}
+	return &directoryGroupResolver{service}, nil
+}
+
+type directoryGroupResolver struct {
+	service *admin.Service
+}
+
+// Groups implements GroupResolver.
+func (r *directoryGroupResolver) Groups(ctx context.Context, email string) ([]string, error) {
+	groups := []string{}
+	err := r.service.Groups.List().UserKey(email).Pages(ctx, func(page *admin.Groups) error {
+		for _, group := range page.Groups {
+			groups = append(groups, group.Email)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return groups, nil
+}
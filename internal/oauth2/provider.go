@@ -3,7 +3,10 @@ package oauth2
 import (
 	"context"
 
+	"github.com/tingtt/oauth2rbac/internal/oauth2/bitbucket"
+	"github.com/tingtt/oauth2rbac/internal/oauth2/entra"
 	"github.com/tingtt/oauth2rbac/internal/oauth2/github"
+	"github.com/tingtt/oauth2rbac/internal/oauth2/gitlab"
 	"github.com/tingtt/oauth2rbac/internal/oauth2/google"
 
 	"golang.org/x/oauth2"
@@ -12,8 +15,16 @@ import (
 type Provider struct {
 	Endpoint        oauth2.Endpoint
 	Scopes          []string
-	GetUserInfoFunc func(ctx context.Context, config Config, token *oauth2.Token) (username, email string, err error)
+	GetUserInfoFunc func(ctx context.Context, config Config, token *oauth2.Token) (UserInfo, error)
 	DisplayName     string
+	// Icon is the icon name of the provider types, or the image URL. (default: the provider type)
+	Icon string
+	// AuthCodeOptions is the auth URL params always added. (e.g. `hd` of Google)
+	AuthCodeOptions []oauth2.AuthCodeOption
+	// ReauthenticateOptions is the auth URL params to force users to sign in again. (e.g. `prompt=login` of OpenID Connect)
+	ReauthenticateOptions []oauth2.AuthCodeOption
+	// DiscoveryURL is the OpenID Connect discovery document URL, checked for readiness. (empty if not OpenID Connect)
+	DiscoveryURL string
 }
 
 var Providers = map[string]Provider{
@@ -25,6 +36,11 @@ var Providers = map[string]Provider{
 		},
 		GetUserInfoFunc: google.GetUserInfoFunc,
 		DisplayName:     "Google",
+		DiscoveryURL:    google.DiscoveryURL,
+		ReauthenticateOptions: []oauth2.AuthCodeOption{
+			oauth2.SetAuthURLParam("prompt", "consent select_account"),
+			oauth2.SetAuthURLParam("max_age", "0"),
+		},
 	},
 	"github": {
 		Endpoint: github.Endpoint,
@@ -34,6 +50,49 @@ var Providers = map[string]Provider{
 		},
 		GetUserInfoFunc: github.GetUserInfoFunc,
 		DisplayName:     "GitHub",
+		ReauthenticateOptions: []oauth2.AuthCodeOption{
+			oauth2.SetAuthURLParam("prompt", "select_account"),
+		},
+	},
+	"gitlab": {
+		Endpoint: gitlab.Endpoint,
+		Scopes: []string{
+			"openid",
+			"profile",
+			"email",
+		},
+		GetUserInfoFunc: gitlab.GetUserInfoFunc,
+		DisplayName:     "GitLab",
+		DiscoveryURL:    gitlab.NewDiscoveryURL(gitlab.DefaultBaseURL),
+		ReauthenticateOptions: []oauth2.AuthCodeOption{
+			oauth2.SetAuthURLParam("prompt", "login"),
+			oauth2.SetAuthURLParam("max_age", "0"),
+		},
+	},
+	"entra": {
+		// Endpoint and DiscoveryURL depend on the tenant. (see entra.NewEndpoint)
+		Scopes: []string{
+			"openid",
+			"profile",
+			"email",
+			"offline_access",
+			"User.Read",
+		},
+		GetUserInfoFunc: entra.GetUserInfoFunc,
+		DisplayName:     "Microsoft",
+		ReauthenticateOptions: []oauth2.AuthCodeOption{
+			oauth2.SetAuthURLParam("prompt", "login"),
+			oauth2.SetAuthURLParam("max_age", "0"),
+		},
+	},
+	"bitbucket": {
+		Endpoint: bitbucket.Endpoint,
+		Scopes: []string{
+			"account",
+			"email",
+		},
+		GetUserInfoFunc: bitbucket.GetUserInfoFunc,
+		DisplayName:     "Bitbucket",
 	},
 }
 
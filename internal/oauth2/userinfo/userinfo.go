@@ -0,0 +1,33 @@
+package userinfo
+
+import "fmt"
+
+// UserInfo is the identity of the user given by the OAuth2 provider.
+type UserInfo struct {
+	Username string
+	Email    string
+	// SecondaryEmails is the other verified emails, which may be used instead of Email to match ACL.
+	SecondaryEmails []string
+
+	// GitHubOrgs is the GitHub organizations the user belongs to.
+	GitHubOrgs []string
+	// GitHubTeams is the GitHub teams the user belongs to, as `<org>/<team slug>`.
+	GitHubTeams []string
+
+	// GoogleHostedDomain is the Google Workspace domain of the user. (empty for consumer accounts)
+	GoogleHostedDomain string
+	// GoogleGroups is the emails of Google Groups the user belongs to.
+	GoogleGroups []string
+
+	// EntraGroups is the object IDs of Microsoft Entra ID groups the user belongs to.
+	EntraGroups []string
+}
+
+// StatusError is the unexpected status code responded by the provider API.
+type StatusError struct {
+	StatusCode int
+}
+
+func (e StatusError) Error() string {
+	return fmt.Sprintf("received status code %d", e.StatusCode)
+}
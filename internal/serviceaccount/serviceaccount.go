@@ -0,0 +1,70 @@
+package serviceaccount
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/tingtt/oauth2rbac/internal/acl"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Pool is the service accounts for machine-to-machine callers.
+type Pool map[ /* client id */ string]ServiceAccount
+
+type ServiceAccount struct {
+	ClientSecretHash SecretHash `yaml:"client_secret_hash"`
+}
+
+// SecretHash is a bcrypt hash (e.g. "$2a$10$..."), or a hex encoded SHA-256 hash with "sha256:" prefix.
+type SecretHash string
+
+func (h SecretHash) Validate() error {
+	if hexHash, isSHA256 := strings.CutPrefix(string(h), "sha256:"); isSHA256 {
+		if b, err := hex.DecodeString(hexHash); err != nil || len(b) != sha256.Size {
+			return fmt.Errorf("invalid sha256 hash")
+		}
+		return nil
+	}
+	if _, err := bcrypt.Cost([]byte(h)); err != nil {
+		return fmt.Errorf("invalid bcrypt hash: %w", err)
+	}
+	return nil
+}
+
+func (h SecretHash) Match(secret string) bool {
+	if hexHash, isSHA256 := strings.CutPrefix(string(h), "sha256:"); isSHA256 {
+		sum := sha256.Sum256([]byte(secret))
+		return subtle.ConstantTimeCompare([]byte(hex.EncodeToString(sum[:])), []byte(strings.ToLower(hexHash))) == 1
+	}
+	return bcrypt.CompareHashAndPassword([]byte(h), []byte(secret)) == nil
+}
+
+func (p Pool) Validate() error {
+	for clientID, serviceAccount := range p {
+		if clientID == "" {
+			return fmt.Errorf("service account client id cannot be empty")
+		}
+		if err := serviceAccount.ClientSecretHash.Validate(); err != nil {
+			return fmt.Errorf("service account `%s`: %w", clientID, err)
+		}
+	}
+	return nil
+}
+
+func (p Pool) Authenticate(clientID, clientSecret string) bool {
+	serviceAccount, ok := p[clientID]
+	if !ok {
+		return false
+	}
+	return serviceAccount.ClientSecretHash.Match(clientSecret)
+}
+
+// Subject returns the identity of the service account used in ACL.
+// (e.g. "serviceaccount:ci-deployer")
+func Subject(clientID string) string {
+	return acl.ServiceAccountPrefix + clientID
+}
@@ -0,0 +1,45 @@
+package serviceaccount
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestPool_Authenticate(t *testing.T) {
+	t.Parallel()
+
+	bcryptHash, _ := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+	pool := Pool{
+		"sha256-client": {ClientSecretHash: "sha256:2bb80d537b1da3e38bd30361aa855686bde0eacd7162fef6a25fe97bf527a25b"},
+		"bcrypt-client": {ClientSecretHash: SecretHash(bcryptHash)},
+	}
+	assert.NoError(t, pool.Validate())
+
+	tests := []struct {
+		name         string
+		clientID     string
+		clientSecret string
+		want         bool
+	}{
+		{"sha256 hash", "sha256-client", "secret", true},
+		{"sha256 hash with wrong secret", "sha256-client", "wrong", false},
+		{"bcrypt hash", "bcrypt-client", "secret", true},
+		{"bcrypt hash with wrong secret", "bcrypt-client", "wrong", false},
+		{"unknown client", "unknown", "secret", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, tt.want, pool.Authenticate(tt.clientID, tt.clientSecret))
+		})
+	}
+}
+
+func TestPool_Validate(t *testing.T) {
+	t.Parallel()
+
+	assert.Error(t, Pool{"client": {ClientSecretHash: "plaintext"}}.Validate())
+	assert.Error(t, Pool{"client": {ClientSecretHash: "sha256:abc"}}.Validate())
+}
@@ -0,0 +1,56 @@
+package session
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// NewFileStore returns a Store persisting sessions to the JSON file, to keep revocations across restarts.
+// Sessions are kept in memory, and the file is rewritten when sessions are created, extended, verified or revoked.
+// (Extensions on JWT renewal are persisted once the expiry gets ahead of the persisted one by more than a minute.)
+// The file contains provider tokens, so it is written with mode 0600.
+func NewFileStore(filePath string) (Store, error) {
+	sessions := map[string]Session{}
+	data, err := os.ReadFile(filePath)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("failed to load sessions: %w", err)
+	}
+	if err == nil {
+		if err := json.Unmarshal(data, &sessions); err != nil {
+			return nil, fmt.Errorf("failed to load sessions: %w", err)
+		}
+	}
+	return &memoryStore{
+		sessions: sessions,
+		onChange: func(sessions map[string]Session) error {
+			return writeFileAtomic(filePath, sessions)
+		},
+		persistedExpiresAt: sessionExpiries(sessions),
+	}, nil
+}
+
+func writeFileAtomic(filePath string, sessions map[string]Session) error {
+	data, err := json.Marshal(sessions)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(filePath), filepath.Base(filePath)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), filePath)
+}
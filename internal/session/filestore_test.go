@@ -0,0 +1,96 @@
+package session
+
+import (
+	"testing"
+	"time"
+
+	"github.com/tingtt/oauth2rbac/pkg/jwtclaims"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFileStore(t *testing.T) {
+	t.Parallel()
+
+	filePath := t.TempDir() + "/sessions.json"
+	store, err := NewFileStore(filePath)
+	assert.NoError(t, err)
+
+	now := time.Now().UTC().Truncate(time.Second)
+	session := Session{
+		ID:         NewID(),
+		Email:      "user@example.com",
+		Provider:   "github",
+		CreatedAt:  now,
+		LastSeenAt: now,
+		ExpiresAt:  now.Add(time.Hour),
+	}
+	assert.NoError(t, store.Create(session))
+	assert.NoError(t, store.Create(Session{ID: NewID(), Email: "other@example.com", ExpiresAt: now.Add(time.Hour)}))
+	assert.NoError(t, store.Create(Session{ID: NewID(), Email: "user@example.com", ExpiresAt: now.Add(-time.Minute)}))
+
+	t.Run("may persist sessions to the file", func(t *testing.T) {
+		reloaded, err := NewFileStore(filePath)
+		assert.NoError(t, err)
+		got, err := reloaded.Get(session.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, session, *got)
+	})
+
+	t.Run("may list sessions except expired ones", func(t *testing.T) {
+		got, err := store.List("user@example.com")
+		assert.NoError(t, err)
+		assert.Equal(t, []Session{session}, got)
+		got, err = store.List("")
+		assert.NoError(t, err)
+		assert.Len(t, got, 2)
+	})
+
+	t.Run("may check sessions of claims", func(t *testing.T) {
+		assert.NoError(t, Check(store, jwtclaims.Claims{Email: session.Email, SessionID: session.ID}))
+		assert.ErrorIs(t, Check(store, jwtclaims.Claims{Email: session.Email}), ErrNotFound)
+		assert.ErrorIs(t, Check(store, jwtclaims.Claims{Email: session.Email, SessionID: "unknown"}), ErrNotFound)
+		assert.NoError(t, Check(store, jwtclaims.Claims{ServiceAccount: &jwtclaims.ClaimsServiceAccount{ClientID: "ci"}}))
+		assert.NoError(t, Check(nil, jwtclaims.Claims{Email: session.Email}))
+	})
+
+	t.Run("may revoke sessions", func(t *testing.T) {
+		revoked, err := store.RevokeUser("other@example.com")
+		assert.NoError(t, err)
+		assert.Equal(t, 1, revoked)
+
+		assert.NoError(t, store.Revoke(session.ID))
+		assert.ErrorIs(t, store.Revoke(session.ID), ErrNotFound)
+
+		reloaded, err := NewFileStore(filePath)
+		assert.NoError(t, err)
+		_, err = reloaded.Get(session.ID)
+		assert.ErrorIs(t, err, ErrNotFound)
+	})
+}
+
+func TestFileStore_touch(t *testing.T) {
+	t.Parallel()
+
+	filePath := t.TempDir() + "/sessions.json"
+	store, err := NewFileStore(filePath)
+	assert.NoError(t, err)
+
+	now := time.Now()
+	session := Session{ID: NewID(), Email: "user@example.com", ExpiresAt: now.Add(500 * time.Millisecond)}
+	assert.NoError(t, store.Create(session))
+
+	extendedExpiresAt := now.Add(time.Hour)
+	assert.NoError(t, store.Touch(session.ID, now, extendedExpiresAt))
+	// not persisted until the expiry gets ahead of the persisted one by more than touchPersistThreshold
+	assert.NoError(t, store.Touch(session.ID, now, extendedExpiresAt.Add(30*time.Second)))
+
+	// restart after the original expiry
+	time.Sleep(time.Until(session.ExpiresAt))
+	reloaded, err := NewFileStore(filePath)
+	assert.NoError(t, err)
+	got, err := reloaded.Get(session.ID)
+	if assert.NoError(t, err, "session extended past the original expiry may be kept across restarts") {
+		assert.True(t, extendedExpiresAt.Equal(got.ExpiresAt))
+	}
+}
@@ -0,0 +1,150 @@
+package session
+
+import (
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+func NewMemoryStore() Store {
+	return &memoryStore{sessions: map[string]Session{}}
+}
+
+// touchPersistThreshold is how far the expiry extended on JWT renewal may get ahead of the persisted one.
+// It throttles rewriting the file on every request, at the cost of losing up to a minute of the extensions on restart.
+const touchPersistThreshold = time.Minute
+
+type memoryStore struct {
+	mu       sync.RWMutex
+	sessions map[ /* id */ string]Session
+	// onChange is called with the lock held after sessions are created, extended, verified or revoked.
+	onChange func(sessions map[string]Session) error
+	// persistedExpiresAt is the expiry of sessions when onChange succeeded last.
+	persistedExpiresAt map[ /* id */ string]time.Time
+}
+
+// Create implements Store.
+func (s *memoryStore) Create(session Session) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pruneExpired(time.Now())
+	s.sessions[session.ID] = session
+	return s.changed()
+}
+
+// Get implements Store.
+func (s *memoryStore) Get(id string) (*Session, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	session, ok := s.sessions[id]
+	if !ok || !time.Now().Before(session.ExpiresAt) {
+		return nil, ErrNotFound
+	}
+	return &session, nil
+}
+
+// Touch implements Store.
+func (s *memoryStore) Touch(id string, lastSeenAt, expiresAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	session, ok := s.sessions[id]
+	if !ok {
+		return ErrNotFound
+	}
+	session.LastSeenAt = lastSeenAt
+	session.ExpiresAt = expiresAt
+	s.sessions[id] = session
+	if /* extended not so much */ expiresAt.Sub(s.persistedExpiresAt[id]) <= touchPersistThreshold {
+		return nil
+	}
+	return s.changed()
+}
+
+// Verify implements Store.
+func (s *memoryStore) Verify(id string, providerToken *oauth2.Token, verifiedAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	session, ok := s.sessions[id]
+	if !ok {
+		return ErrNotFound
+	}
+	session.ProviderToken = providerToken
+	session.VerifiedAt = verifiedAt
+	s.sessions[id] = session
+	return s.changed()
+}
+
+// List implements Store.
+func (s *memoryStore) List(email string) ([]Session, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	sessions := []Session{}
+	now := time.Now()
+	for _, session := range s.sessions {
+		if (email == "" || session.Email == email) && now.Before(session.ExpiresAt) {
+			sessions = append(sessions, session)
+		}
+	}
+	slices.SortFunc(sessions, func(a, b Session) int {
+		return strings.Compare(a.ID, b.ID)
+	})
+	return sessions, nil
+}
+
+// Revoke implements Store.
+func (s *memoryStore) Revoke(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.sessions[id]; !ok {
+		return ErrNotFound
+	}
+	delete(s.sessions, id)
+	return s.changed()
+}
+
+// RevokeUser implements Store.
+func (s *memoryStore) RevokeUser(email string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	revoked := 0
+	for id, session := range s.sessions {
+		if email == "" || session.Email == email {
+			delete(s.sessions, id)
+			revoked++
+		}
+	}
+	if revoked == 0 {
+		return 0, nil
+	}
+	return revoked, s.changed()
+}
+
+func (s *memoryStore) pruneExpired(now time.Time) {
+	for id, session := range s.sessions {
+		if !now.Before(session.ExpiresAt) {
+			delete(s.sessions, id)
+		}
+	}
+}
+
+func (s *memoryStore) changed() error {
+	if s.onChange == nil {
+		return nil
+	}
+	if err := s.onChange(s.sessions); err != nil {
+		return err
+	}
+	s.persistedExpiresAt = sessionExpiries(s.sessions)
+	return nil
+}
+
+func sessionExpiries(sessions map[string]Session) map[string]time.Time {
+	expiries := make(map[string]time.Time, len(sessions))
+	for id, session := range sessions {
+		expiries[id] = session.ExpiresAt
+	}
+	return expiries
+}
@@ -0,0 +1,64 @@
+package session
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/tingtt/oauth2rbac/pkg/jwtclaims"
+
+	"golang.org/x/oauth2"
+)
+
+var ErrNotFound = errors.New("session not found")
+
+// Session is a signed-in session, referenced by the `sid` claim of JWT.
+type Session struct {
+	ID         string    `json:"id"`
+	Email      string    `json:"email"`
+	Provider   string    `json:"provider"`
+	RemoteAddr string    `json:"remote_addr"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastSeenAt time.Time `json:"last_seen_at"`
+	// ExpiresAt is the expiry of the latest JWT issued for the session.
+	ExpiresAt time.Time `json:"expires_at"`
+	// ProviderToken is the OAuth2 token of the provider, to re-validate the identity.
+	ProviderToken *oauth2.Token `json:"provider_token,omitempty"`
+	// VerifiedAt is the time the identity was last validated with the provider.
+	VerifiedAt time.Time `json:"verified_at"`
+}
+
+// Store keeps sessions server-side so that they can be revoked before JWT expiry.
+type Store interface {
+	Create(session Session) error
+	Get(id string) (*Session, error)
+	// Touch extends the session on JWT renewal.
+	Touch(id string, lastSeenAt, expiresAt time.Time) error
+	// Verify records the re-validation of the identity with the (refreshed) provider token.
+	Verify(id string, providerToken *oauth2.Token, verifiedAt time.Time) error
+	// List returns sessions of the user. If email is empty, it returns all sessions.
+	List(email string) ([]Session, error)
+	Revoke(id string) error
+	// RevokeUser revokes sessions of the user. If email is empty, it revokes all sessions.
+	RevokeUser(email string) (revoked int, err error)
+}
+
+func NewID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// Check returns an error if the session of the claims is revoked or unknown.
+// It always passes if store is nil (sessions disabled), and for service accounts.
+func Check(store Store, claims jwtclaims.Claims) error {
+	if store == nil || claims.ServiceAccount != nil {
+		return nil
+	}
+	if claims.SessionID == "" {
+		return ErrNotFound
+	}
+	_, err := store.Get(claims.SessionID)
+	return err
+}
@@ -0,0 +1,87 @@
+// Package tracing is the OpenTelemetry tracing of the proxy, exported via OTLP.
+package tracing
+
+import (
+	"context"
+	"sync/atomic"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	serviceName = "oauth2rbac"
+	tracerName  = "github.com/tingtt/oauth2rbac"
+)
+
+// Option is the settings of tracing.
+type Option struct {
+	// RecordUserEmail records the emails of signed-in users as `enduser.id` of spans.
+	RecordUserEmail bool
+}
+
+var recordUserEmail atomic.Bool
+
+// Setup exports the spans via OTLP/HTTP, and propagates W3C trace context to upstreams.
+// The exporter is configured with `OTEL_EXPORTER_OTLP_*` and the sampler with `OTEL_TRACES_SAMPLER` environment variables.
+// The returned function flushes the spans and stops exporting.
+func Setup(ctx context.Context, option Option) (shutdown func(context.Context) error, err error) {
+	exporter, err := otlptracehttp.New(ctx)
+	if err != nil {
+		return nil, err
+	}
+	provider, err := NewTracerProvider(ctx, exporter)
+	if err != nil {
+		return nil, err
+	}
+	Register(provider, option)
+	return provider.Shutdown, nil
+}
+
+// NewTracerProvider returns the provider exporting the spans in batches. (e.g. tracetest.InMemoryExporter in tests)
+// The service name is `oauth2rbac`, unless `OTEL_SERVICE_NAME` is set.
+func NewTracerProvider(ctx context.Context, exporter sdktrace.SpanExporter) (*sdktrace.TracerProvider, error) {
+	res, err := resource.New(ctx,
+		resource.WithAttributes(semconv.ServiceName(serviceName)),
+		resource.WithFromEnv(),
+		resource.WithTelemetrySDK(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return sdktrace.NewTracerProvider(sdktrace.WithBatcher(exporter), sdktrace.WithResource(res)), nil
+}
+
+// Register sets the provider and the W3C trace context propagator globally.
+func Register(provider trace.TracerProvider, option Option) {
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	recordUserEmail.Store(option.RecordUserEmail)
+}
+
+// Start starts the span of the global provider. Spans are not recorded unless Register is called.
+func Start(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	return otel.Tracer(tracerName).Start(ctx, name, opts...)
+}
+
+// End ends the span, with the error status if err is not nil.
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// SetUserEmail records the email of the signed-in user on the span of the request, only if opted in.
+func SetUserEmail(ctx context.Context, email string) {
+	if recordUserEmail.Load() {
+		trace.SpanFromContext(ctx).SetAttributes(semconv.EnduserID(email))
+	}
+}
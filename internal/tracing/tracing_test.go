@@ -0,0 +1,46 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace/noop"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// not parallel, as the provider is registered globally
+func TestSpans(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	t.Cleanup(func() { Register(noop.NewTracerProvider(), Option{}) })
+
+	for _, recordUserEmail := range []bool{false, true} {
+		exporter.Reset()
+		Register(sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)), Option{RecordUserEmail: recordUserEmail})
+
+		ctx, span := Start(context.Background(), "request")
+		SetUserEmail(ctx, "user@example.com")
+		_, child := Start(ctx, "jwt.Decode")
+		End(child, errors.New("token is expired"))
+		End(span, nil)
+
+		spans := exporter.GetSpans()
+		if !assert.Len(t, spans, 2) {
+			return
+		}
+		assert.Equal(t, "jwt.Decode", spans[0].Name)
+		assert.Equal(t, codes.Error, spans[0].Status.Code)
+		assert.Equal(t, spans[1].SpanContext.SpanID(), spans[0].Parent.SpanID())
+		assert.Equal(t, codes.Unset, spans[1].Status.Code)
+		if recordUserEmail {
+			assert.Contains(t, spans[1].Attributes, semconv.EnduserID("user@example.com"))
+		} else {
+			assert.Empty(t, spans[1].Attributes, "email is recorded only if opted in")
+		}
+	}
+}
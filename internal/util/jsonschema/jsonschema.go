@@ -0,0 +1,128 @@
+package jsonschema
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+)
+
+const Draft = "https://json-schema.org/draft/2020-12/schema"
+
+// Schema is a subset of JSON Schema (draft 2020-12) to describe YAML documents.
+type Schema struct {
+	Schema      string `json:"$schema,omitempty"`
+	ID          string `json:"$id,omitempty"`
+	Title       string `json:"title,omitempty"`
+	Description string `json:"description,omitempty"`
+
+	Type    string    `json:"type,omitempty"`
+	Enum    []any     `json:"enum,omitempty"`
+	Pattern string    `json:"pattern,omitempty"`
+	OneOf   []*Schema `json:"oneOf,omitempty"`
+
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	PropertyNames        *Schema            `json:"propertyNames,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+
+	Items *Schema `json:"items,omitempty"`
+
+	// never makes the schema `false`, which no values are valid against.
+	never bool
+}
+
+// Never is the schema `false`, to disallow additional properties.
+var Never = &Schema{never: true}
+
+func (s *Schema) MarshalJSON() ([]byte, error) {
+	if s.never {
+		return []byte("false"), nil
+	}
+	type schema Schema
+	return json.Marshal((*schema)(s))
+}
+
+func (s *Schema) UnmarshalJSON(data []byte) error {
+	if string(bytes.TrimSpace(data)) == "false" {
+		*s = Schema{never: true}
+		return nil
+	}
+	type schema Schema
+	return json.Unmarshal(data, (*schema)(s))
+}
+
+// Schemaer is implemented by types decoded from values other than their kinds. (e.g. durations from strings or numbers)
+type Schemaer interface {
+	JSONSchema() *Schema
+}
+
+var schemaerType = reflect.TypeFor[Schemaer]()
+
+// For returns the schema of the type, with the property names of yaml struct tags.
+// Structs do not allow unknown properties, as decoded with yaml.Decoder.KnownFields. Pointers may be null.
+func For(t reflect.Type) *Schema {
+	if /* nullable */ t.Kind() == reflect.Pointer {
+		return &Schema{OneOf: []*Schema{For(t.Elem()), {Type: "null"}}}
+	}
+	if t.Implements(schemaerType) {
+		return reflect.Zero(t).Interface().(Schemaer).JSONSchema()
+	}
+	if reflect.PointerTo(t).Implements(schemaerType) {
+		return reflect.New(t).Interface().(Schemaer).JSONSchema()
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.Slice, reflect.Array:
+		return &Schema{Type: "array", Items: For(t.Elem())}
+	case reflect.Map:
+		schema := &Schema{Type: "object", AdditionalProperties: For(t.Elem())}
+		switch t.Key().Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			schema.PropertyNames = &Schema{Pattern: `^-?[0-9]+$`}
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			schema.PropertyNames = &Schema{Pattern: `^[0-9]+$`}
+		}
+		return schema
+	case reflect.Struct:
+		schema := &Schema{Type: "object", Properties: map[string]*Schema{}, AdditionalProperties: Never}
+		addProperties(schema, t)
+		return schema
+	default:
+		return &Schema{}
+	}
+}
+
+func addProperties(schema *Schema, t reflect.Type) {
+	for i := range t.NumField() {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name, opts, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+		if name == "-" {
+			continue
+		}
+		if opts == "inline" || strings.HasPrefix(opts, "inline,") || strings.HasSuffix(opts, ",inline") {
+			fieldType := field.Type
+			if fieldType.Kind() == reflect.Pointer {
+				fieldType = fieldType.Elem()
+			}
+			addProperties(schema, fieldType)
+			continue
+		}
+		if name == "" {
+			name = strings.ToLower(field.Name)
+		}
+		schema.Properties[name] = For(field.Type)
+	}
+}
@@ -0,0 +1,79 @@
+package jsonschema
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testDuration time.Duration
+
+func (testDuration) JSONSchema() *Schema {
+	return &Schema{Type: "string", Pattern: `^[0-9]+s$`}
+}
+
+type InlineFields struct {
+	Inlined string `yaml:"inlined"`
+}
+
+type testStruct struct {
+	Name         string            `yaml:"name"`
+	Count        *int              `yaml:"count"`
+	Ratio        float64           `yaml:"ratio"`
+	Enabled      bool              `yaml:"enabled"`
+	Tags         []string          `yaml:"tags"`
+	Pages        map[int]string    `yaml:"pages"`
+	Labels       map[string]string `yaml:"labels"`
+	Timeout      testDuration      `yaml:"timeout"`
+	Default      string
+	Ignored      string `yaml:"-"`
+	unexported   string
+	InlineFields `yaml:",inline"`
+}
+
+func TestFor(t *testing.T) {
+	t.Parallel()
+
+	got := For(reflect.TypeFor[testStruct]())
+
+	assert.Equal(t, &Schema{
+		Type: "object",
+		Properties: map[string]*Schema{
+			"name":    {Type: "string"},
+			"count":   {OneOf: []*Schema{{Type: "integer"}, {Type: "null"}}},
+			"ratio":   {Type: "number"},
+			"enabled": {Type: "boolean"},
+			"tags":    {Type: "array", Items: &Schema{Type: "string"}},
+			"pages":   {Type: "object", PropertyNames: &Schema{Pattern: `^-?[0-9]+$`}, AdditionalProperties: &Schema{Type: "string"}},
+			"labels":  {Type: "object", AdditionalProperties: &Schema{Type: "string"}},
+			"timeout": {Type: "string", Pattern: `^[0-9]+s$`},
+			"default": {Type: "string"},
+			"inlined": {Type: "string"},
+		},
+		AdditionalProperties: Never,
+	}, got)
+
+	rawJSON, err := json.Marshal(got)
+	assert.NoError(t, err)
+	assert.Contains(t, string(rawJSON), `"additionalProperties":false`)
+}
+
+func TestSchema_roundTrip(t *testing.T) {
+	t.Parallel()
+
+	want := For(reflect.TypeFor[testStruct]())
+	want.Schema = Draft
+	want.Required = []string{"name"}
+	want.Properties["name"].Enum = []any{"a", "b"}
+
+	rawJSON, err := json.Marshal(want)
+	if !assert.NoError(t, err) {
+		return
+	}
+	var got Schema
+	assert.NoError(t, json.Unmarshal(rawJSON, &got))
+	assert.Equal(t, want, &got)
+}
@@ -10,17 +10,79 @@ type Claims struct {
 	AllowedScopes acl.AllowedScopes `json:"allowed_scopes"`
 	Email         string            `json:"email"`
 	Roles         []string          `json:"roles"`
+	SessionID     string            `json:"sid,omitempty"`
+	// AuthTime is the time of sign-in in unix seconds. It is preserved across JWT renewals.
+	AuthTime int64 `json:"auth_time,omitempty"`
 
-	GitHub *ClaimsGitHub `json:"github,omitempty"`
-	Google *ClaimsGoogle `json:"google,omitempty"`
+	GitHub         *ClaimsGitHub         `json:"github,omitempty"`
+	Google         *ClaimsGoogle         `json:"google,omitempty"`
+	GitLab         *ClaimsGitLab         `json:"gitlab,omitempty"`
+	Entra          *ClaimsEntra          `json:"entra,omitempty"`
+	Bitbucket      *ClaimsBitbucket      `json:"bitbucket,omitempty"`
+	ServiceAccount *ClaimsServiceAccount `json:"service_account,omitempty"`
 }
 
 type ClaimsGitHub struct {
 	ID string `json:"id"`
+	// Orgs is the organizations the user belongs to. (requires `read:org` scope)
+	Orgs []string `json:"orgs,omitempty"`
+	// Teams is the teams the user belongs to, as `<org>/<team slug>`. (requires `read:org` scope)
+	Teams []string `json:"teams,omitempty"`
 }
 
 type ClaimsGoogle struct {
 	Username string `json:"username"`
+	// HostedDomain is the Google Workspace domain of the user.
+	HostedDomain string `json:"hd,omitempty"`
+	// Groups is the emails of Google Groups the user belongs to.
+	Groups []string `json:"groups,omitempty"`
+}
+
+type ClaimsGitLab struct {
+	Username string `json:"username"`
+}
+
+type ClaimsEntra struct {
+	// Username is the user principal name.
+	Username string `json:"username"`
+	// Groups is the object IDs of groups the user belongs to. (requires `GroupMember.Read.All` scope)
+	Groups []string `json:"groups,omitempty"`
+}
+
+type ClaimsBitbucket struct {
+	Username string `json:"username"`
+}
+
+type ClaimsServiceAccount struct {
+	ClientID string `json:"client_id"`
+}
+
+// Groups returns the groups of OAuth2 providers to match ACL.
+// (e.g. "github:acme", "github:acme/sre", "google:example.com", "google:sre@example.com", "entra:<group object id>")
+func (c Claims) Groups() []string {
+	groups := []string{}
+	if c.GitHub != nil {
+		for _, org := range c.GitHub.Orgs {
+			groups = append(groups, acl.GitHubGroupPrefix+org)
+		}
+		for _, team := range c.GitHub.Teams {
+			groups = append(groups, acl.GitHubGroupPrefix+team)
+		}
+	}
+	if c.Google != nil {
+		if c.Google.HostedDomain != "" {
+			groups = append(groups, acl.GoogleGroupPrefix+c.Google.HostedDomain)
+		}
+		for _, group := range c.Google.Groups {
+			groups = append(groups, acl.GoogleGroupPrefix+group)
+		}
+	}
+	if c.Entra != nil {
+		for _, group := range c.Entra.Groups {
+			groups = append(groups, acl.EntraGroupPrefix+group)
+		}
+	}
+	return groups
 }
 
 func Unmarshal(dataJSON []byte) (Claims, error) {
@@ -39,7 +39,7 @@ func ExampleUnmarshal() {
 		// Output:
 		// map[/:[*] /admin:[*]]
 		// admin@example.test
-		// &{Username:admin example}
-		// &{ID:example}
+		// &{Username:admin example HostedDomain: Groups:[]}
+		// &{ID:example Orgs:[] Teams:[]}
 	}
 }